@@ -0,0 +1,138 @@
+package candidate
+
+import "math"
+
+// Iterator 依次产生候选密码，没有更多密码时 Next 返回 false
+// 迭代器不需要支持并发调用，每次查找使用一个独立的迭代器
+type Iterator interface {
+	Next() (string, bool)
+}
+
+// Source 是可以反复遍历的候选密码来源，每个压缩包从头开始使用一个新的迭代器
+type Source interface {
+	Iter() Iterator
+	// Size 返回候选密码数量的上限 (不去除重复)，用于显示任务规模
+	Size() int64
+}
+
+// skipper 是可以直接跳过若干个候选密码的迭代器，例如掩码不需要逐个生成被跳过的密码
+type skipper interface {
+	skip(n int64) int64
+}
+
+// Skip 跳过迭代器接下来的 n 个候选密码，返回实际跳过的数量，候选密码不足时小于 n
+// 用于恢复中断的任务
+func Skip(it Iterator, n int64) int64 {
+	if s, ok := it.(skipper); ok {
+		return s.skip(n)
+	}
+	var skipped int64
+	for ; skipped < n; skipped++ {
+		if _, ok := it.Next(); !ok {
+			break
+		}
+	}
+	return skipped
+}
+
+// List 直接把密码列表作为候选来源
+type List []string
+
+func (l List) Iter() Iterator { return &listIterator{list: l} }
+func (l List) Size() int64    { return int64(len(l)) }
+
+type listIterator struct {
+	list []string
+	pos  int
+}
+
+func (it *listIterator) Next() (string, bool) {
+	if it.pos >= len(it.list) {
+		return "", false
+	}
+	it.pos++
+	return it.list[it.pos-1], true
+}
+
+func (it *listIterator) skip(n int64) int64 {
+	n = min(n, int64(len(it.list)-it.pos))
+	it.pos += int(n)
+	return n
+}
+
+// Chain 依次使用多个候选来源，例如先尝试密码本，失败后再尝试掩码
+func Chain(sources ...Source) Source {
+	if len(sources) == 1 {
+		return sources[0]
+	}
+	return chainSource(sources)
+}
+
+type chainSource []Source
+
+func (c chainSource) Iter() Iterator {
+	return &chainIterator{sources: c}
+}
+
+func (c chainSource) Size() int64 {
+	var total int64
+	for _, s := range c {
+		total = addSaturating(total, s.Size())
+	}
+	return total
+}
+
+type chainIterator struct {
+	sources []Source
+	current Iterator
+}
+
+func (it *chainIterator) Next() (string, bool) {
+	for {
+		if it.current == nil {
+			if len(it.sources) == 0 {
+				return "", false
+			}
+			it.current = it.sources[0].Iter()
+			it.sources = it.sources[1:]
+		}
+		if password, ok := it.current.Next(); ok {
+			return password, true
+		}
+		it.current = nil
+	}
+}
+
+func (it *chainIterator) skip(n int64) int64 {
+	var skipped int64
+	for skipped < n {
+		if it.current == nil {
+			if len(it.sources) == 0 {
+				break
+			}
+			it.current = it.sources[0].Iter()
+			it.sources = it.sources[1:]
+		}
+		m := Skip(it.current, n-skipped)
+		skipped += m
+		if skipped < n {
+			// 当前来源已经用完
+			it.current = nil
+		}
+	}
+	return skipped
+}
+
+func mulSaturating(a, b int64) int64 {
+	if a != 0 && b > math.MaxInt64/a {
+		return math.MaxInt64
+	}
+	return a * b
+}
+
+func addSaturating(a, b int64) int64 {
+	if a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
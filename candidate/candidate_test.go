@@ -0,0 +1,56 @@
+package candidate
+
+import (
+	"slices"
+	"testing"
+)
+
+// 恢复任务时先 Skip 再继续遍历，结果必须与从头遍历时剩下的部分完全一致
+func TestSkip(t *testing.T) {
+	mask := mustNewMask(t, MaskOptions{Mask: "?1?2?1", Charsets: [4]string{"ab", "012"}, Increment: true})
+	brute, err := NewBruteForce("xy", 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := []Rule{mustParseRule(t, "u"), mustParseRule(t, ">3"), mustParseRule(t, "$!")}
+
+	sources := map[string]Source{
+		"列表":   List{"a", "b", "c", "d"},
+		"掩码":   mask,
+		"暴力破解": brute,
+		"规则":   WithRules(List{"ab", "CD", "efgh"}, rules),
+		"连接":   Chain(List{"p", "q"}, List{}, WithRules(List{"ab", "efgh"}, rules), mask, brute),
+	}
+	for name, source := range sources {
+		t.Run(name, func(t *testing.T) {
+			all := collect(source.Iter())
+			// 先取出 read 个，再跳过 n 个，覆盖从中间位置跳过和跳过超出末尾的情况
+			for read := 0; read <= len(all); read++ {
+				for n := 0; n <= len(all)-read+2; n++ {
+					it := source.Iter()
+					for range read {
+						it.Next()
+					}
+					skipped := Skip(it, int64(n))
+					want := all[min(read+n, len(all)):]
+					if skipped != int64(min(n, len(all)-read)) {
+						t.Fatalf("读取 %d 个后 Skip(%d) = %d", read, n, skipped)
+					}
+					if got := collect(it); !slices.Equal(got, want) {
+						t.Fatalf("读取 %d 个后 Skip(%d)，剩下 %q，应为 %q", read, n, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	source := Chain(List{"a", "b"}, List{}, List{"c"})
+	if got, want := collect(source.Iter()), []string{"a", "b", "c"}; !slices.Equal(got, want) {
+		t.Errorf("候选密码为 %q，应为 %q", got, want)
+	}
+	if source.Size() != 3 {
+		t.Errorf("Size() = %d，应为 3", source.Size())
+	}
+}
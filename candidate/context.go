@@ -0,0 +1,172 @@
+package candidate
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// --- 上下文密码 ---
+//
+// 很多压缩包的密码就写在文件名 (例如 "资料_密码abc123.rar")、上级文件夹名、
+// 同目录的说明文件 (解压密码.txt、readme.txt 等) 或压缩包注释中。
+// 这些候选密码只属于当前压缩包，在密码本之前尝试。
+
+const (
+	noteFileLimit  = 64 << 10 // 说明文件只读取开头的部分，避免误读大文件
+	noteLineLimit  = 20       // 说明文件和注释中直接作为候选密码的行数
+	noteLineLength = 64       // 超过该长度 (字符数) 的行不直接作为候选密码
+)
+
+// ContextOptions 控制上下文候选密码的提取方式
+type ContextOptions struct {
+	Patterns    []*regexp.Regexp // 从文本中提取密码，第一个捕获组 (没有捕获组时为整个匹配) 作为密码
+	NoteFiles   []*regexp.Regexp // 与压缩包同目录、需要读取的说明文件名
+	ParentDepth int              // 参与提取的上级文件夹层数
+}
+
+// FromContext 从压缩包名称、上级文件夹名、说明文件和注释中提取候选密码，按可能性从高到低排列并去除重复：
+// 关键词之后的内容 (例如 "密码: abc123")、注释和说明文件中较短的行、名称本身及其中以分隔符隔开的片段
+func FromContext(path, name, comment string, opts ContextOptions) List {
+	texts := []string{comment}
+	texts = append(texts, readNoteFiles(filepath.Dir(path), name, opts.NoteFiles)...)
+	names := append([]string{name}, parentNames(path, opts.ParentDepth)...)
+
+	c := &contextCollector{seen: make(map[string]bool)}
+	for _, s := range append(texts, names...) {
+		for _, p := range opts.Patterns {
+			for _, m := range p.FindAllStringSubmatch(s, -1) {
+				c.add(m[min(len(m)-1, 1)])
+			}
+		}
+	}
+	for _, s := range texts {
+		lines := strings.Split(s, "\n")
+		for _, line := range lines[:min(len(lines), noteLineLimit)] {
+			if utf8.RuneCountInString(line) <= noteLineLength {
+				c.add(line)
+			}
+		}
+	}
+	for _, s := range names {
+		c.add(s)
+		for _, token := range strings.FieldsFunc(s, isTokenSeparator) {
+			if utf8.RuneCountInString(token) >= 2 {
+				c.add(token)
+			}
+		}
+	}
+	return List(c.words)
+}
+
+type contextCollector struct {
+	words []string
+	seen  map[string]bool
+}
+
+func (c *contextCollector) add(word string) {
+	word = strings.TrimSpace(word)
+	if word == "" || c.seen[word] {
+		return
+	}
+	c.seen[word] = true
+	c.words = append(c.words, word)
+}
+
+// isTokenSeparator 判断名称中用来分隔片段的字符
+func isTokenSeparator(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune("_-.,;:!?@#+=~、，。；：！？·【】[]()（）{}「」《》<>", r)
+}
+
+// readNoteFiles 读取 dir 中匹配 patterns 或与压缩包同名的 .txt 文件，读取失败的文件直接跳过
+func readNoteFiles(dir, name string, patterns []*regexp.Regexp) []string {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var texts []string
+	for _, f := range files {
+		if f.IsDir() || !isNoteFile(f.Name(), name, patterns) {
+			continue
+		}
+		text, err := readNoteFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			continue
+		}
+		texts = append(texts, text)
+	}
+	return texts
+}
+
+func isNoteFile(fileName, name string, patterns []*regexp.Regexp) bool {
+	if strings.EqualFold(fileName, name+".txt") {
+		return true
+	}
+	for _, p := range patterns {
+		if p.MatchString(fileName) {
+			return true
+		}
+	}
+	return false
+}
+
+// readNoteFile 读取说明文件的开头部分，去掉 BOM 并统一换行符
+func readNoteFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, noteFileLimit))
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimPrefix(decodeNote(data, len(data) == noteFileLimit), "\ufeff")
+	return strings.ReplaceAll(text, "\r\n", "\n"), nil
+}
+
+// decodeNote 把说明文件转为 UTF-8，中文 Windows 下保存的说明文件多为 GBK 编码，不是合法的 UTF-8 时按 GB18030 解码
+// truncated 表示只读取了文件的开头，末尾可能截断了一个 UTF-8 字符
+func decodeNote(data []byte, truncated bool) string {
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	if truncated {
+		for i := 1; i < utf8.UTFMax && i < len(data); i++ {
+			if utf8.Valid(data[:len(data)-i]) {
+				return string(data[:len(data)-i])
+			}
+		}
+	}
+	// GB18030 兼容 GBK 和 GB2312，无法解码的字节会被替换为 U+FFFD
+	text, err := simplifiedchinese.GB18030.NewDecoder().Bytes(data)
+	if err != nil {
+		return string(data)
+	}
+	return string(text)
+}
+
+// parentNames 返回压缩包所在文件夹及其上级文件夹的名称，由近到远最多 depth 个
+func parentNames(path string, depth int) []string {
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for range depth {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			// 已经到达根目录
+			break
+		}
+		names = append(names, filepath.Base(dir))
+		dir = parent
+	}
+	return names
+}
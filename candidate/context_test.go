@@ -0,0 +1,171 @@
+package candidate
+
+import (
+	"ArchiveTools/config"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// defaultContextOptions 返回默认配置中提取上下文密码的选项
+func defaultContextOptions(t *testing.T) ContextOptions {
+	t.Helper()
+	cfg := config.Cfg.Context
+	opts := ContextOptions{ParentDepth: cfg.ParentDepth}
+	for _, p := range cfg.Patterns {
+		opts.Patterns = append(opts.Patterns, regexp.MustCompile(p))
+	}
+	for _, p := range cfg.NoteFiles {
+		opts.NoteFiles = append(opts.NoteFiles, regexp.MustCompile(p))
+	}
+	return opts
+}
+
+func TestFromContextPatterns(t *testing.T) {
+	opts := defaultContextOptions(t)
+	opts.ParentDepth = 0
+	path := filepath.Join(t.TempDir(), "a.zip")
+	tests := map[string]string{
+		"密码: abc":             "abc",
+		"解压码是 x1y2":           "x1y2",
+		"提取码：q9w8，有效期7天":      "q9w8",
+		"PASSWORD=Secret1":    "Secret1",
+		"pwd abc123":          "abc123",
+		"口令为 666888":          "666888",
+		"资源 (解压密码 www.x.com)": "www.x.com",
+	}
+	for comment, want := range tests {
+		got := FromContext(path, "a", comment, opts)
+		if len(got) == 0 || got[0] != want {
+			t.Errorf("注释 %q 中提取出 %q，第一个应为 %q", comment, got, want)
+		}
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	root := t.TempDir()
+	opts := defaultContextOptions(t)
+
+	t.Run("文件名和上级文件夹", func(t *testing.T) {
+		dir := filepath.Join(root, "分享 pwd=fold9", "合集")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		got := FromContext(filepath.Join(dir, "资料_密码abc123.part1.rar"), "资料_密码abc123", "", opts)
+		want := List{"abc123", "fold9", "资料_密码abc123", "资料", "密码abc123", "合集", "分享 pwd=fold9", "分享", "pwd"}
+		if !slices.Equal(got, want) {
+			t.Errorf("FromContext() = %q\n应为 %q", got, want)
+		}
+	})
+
+	t.Run("说明文件", func(t *testing.T) {
+		dir := filepath.Join(root, "notes")
+		files := map[string]string{
+			"解压密码.txt":  "\ufeff解压密码：n0te\r\n第二行 hello\r\n" + strings.Repeat("x", noteLineLength+1) + "\r\n",
+			"A.TXT":     "same-name\n", // 与压缩包同名的 .txt 文件，不区分大小写
+			"other.txt": "ignored\n",
+		}
+		if err := os.MkdirAll(filepath.Join(dir, "readme.txt"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		o := opts
+		o.ParentDepth = 0
+		got := FromContext(filepath.Join(dir, "a.7z"), "a", "", o)
+		want := List{"n0te", "same-name", "解压密码：n0te", "第二行 hello", "a"}
+		if !slices.Equal(got, want) {
+			t.Errorf("FromContext() = %q\n应为 %q", got, want)
+		}
+	})
+
+	t.Run("注释", func(t *testing.T) {
+		o := opts
+		o.ParentDepth = 0
+		// 注释、文件名中的同一个密码和同一个片段只出现一次
+		comment := "本站资源\n解压密码：abc123\n资料\n"
+		got := FromContext(filepath.Join(root, "资料_密码abc123.zip"), "资料_密码abc123", comment, o)
+		want := List{"abc123", "本站资源", "解压密码：abc123", "资料", "资料_密码abc123", "密码abc123"}
+		if !slices.Equal(got, want) {
+			t.Errorf("FromContext() = %q\n应为 %q", got, want)
+		}
+	})
+
+	t.Run("空注释", func(t *testing.T) {
+		dir := filepath.Join(root, "empty")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		o := opts
+		o.ParentDepth = 0
+		for _, comment := range []string{"", "  \n\n\t\n"} {
+			got := FromContext(filepath.Join(dir, "a.zip"), "a", comment, o)
+			if !slices.Equal(got, List{"a"}) {
+				t.Errorf("注释为 %q 时 FromContext() = %q，应只有压缩包名称", comment, got)
+			}
+		}
+	})
+}
+
+func TestReadNoteFile(t *testing.T) {
+	gbk, err := simplifiedchinese.GBK.NewEncoder().String("解压密码：n0te\r\n第二行\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 读取上限恰好截断在一个三字节的 UTF-8 字符中间
+	long := strings.Repeat("x", noteFileLimit-1) + "码"
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"UTF-8", "\ufeff解压密码：n0te\r\n", "解压密码：n0te\n"},
+		{"GBK", gbk, "解压密码：n0te\n第二行\n"},
+		{"截断的 UTF-8", long, strings.Repeat("x", noteFileLimit-1)},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name+".txt")
+		if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := readNoteFile(path)
+		if err != nil {
+			t.Fatalf("%s: readNoteFile() 出错: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: readNoteFile() = %q，应为 %q", tt.name, limitString(got, 40), limitString(tt.want, 40))
+		}
+	}
+
+	// GBK 编码的说明文件同样能提取出密码
+	o := defaultContextOptions(t)
+	o.ParentDepth = 0
+	notes := filepath.Join(dir, "notes")
+	if err := os.MkdirAll(notes, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(notes, "解压密码.txt"), []byte(gbk), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := FromContext(filepath.Join(notes, "a.7z"), "a", "", o); len(got) == 0 || got[0] != "n0te" {
+		t.Errorf("FromContext() = %q，第一个应为 n0te", got)
+	}
+}
+
+// limitString 截取字符串的开头，避免出错时输出过长
+func limitString(s string, n int) string {
+	if len(s) > n {
+		return s[:n] + "..."
+	}
+	return s
+}
@@ -0,0 +1,264 @@
+package candidate
+
+import (
+	"fmt"
+	"slices"
+)
+
+// --- 掩码与暴力破解 ---
+//
+// 掩码使用 hashcat 的语法，每个位置是一个字符或一个字符集，例如 "?d?d?d?d?d?d" 或 "abc?l?l?d"：
+//
+//	?l  a-z            ?u  A-Z            ?d  0-9
+//	?h  0-9a-f         ?H  0-9A-F         ?s  键盘上的特殊符号
+//	?a  ?l?u?d?s       ?1-?4  自定义字符集   ??  字符 ? 本身
+//
+// 暴力破解相当于每个位置都使用同一个字符集的掩码，并在长度范围内逐个尝试。
+
+// 内置字符集
+var builtinCharsets = map[rune]string{
+	'l': "abcdefghijklmnopqrstuvwxyz",
+	'u': "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	'd': "0123456789",
+	'h': "0123456789abcdef",
+	'H': "0123456789ABCDEF",
+	's': " !\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~",
+}
+
+func init() {
+	builtinCharsets['a'] = builtinCharsets['l'] + builtinCharsets['u'] + builtinCharsets['d'] + builtinCharsets['s']
+}
+
+// MaskOptions 描述一次掩码攻击
+type MaskOptions struct {
+	Mask      string
+	Charsets  [4]string // 自定义字符集 ?1-?4，可以引用内置字符集，例如 "?l?d_"
+	Increment bool      // 增量模式：从 MinLength 个位置开始，依次尝试掩码的每个前缀
+	MinLength int       // 增量模式下的最短长度，小于 1 时按 1 处理
+}
+
+// Mask 是解析后的掩码，作为候选密码来源时按长度从短到长、每个长度内按字典序产生密码
+type Mask struct {
+	desc      string
+	positions [][]rune
+	minLength int
+}
+
+// NewMask 解析掩码
+func NewMask(opts MaskOptions) (*Mask, error) {
+	var custom [4][]rune
+	for i, spec := range opts.Charsets {
+		if spec == "" {
+			continue
+		}
+		set, err := expandCharset(spec, nil)
+		if err != nil {
+			return nil, fmt.Errorf("自定义字符集 ?%d: %w", i+1, err)
+		}
+		custom[i] = set
+	}
+
+	m := &Mask{desc: "掩码 " + opts.Mask}
+	src := []rune(opts.Mask)
+	for i := 0; i < len(src); i++ {
+		if src[i] != '?' {
+			m.positions = append(m.positions, []rune{src[i]})
+			continue
+		}
+		if i+1 >= len(src) {
+			return nil, fmt.Errorf("掩码 '%s' 以单独的 ? 结尾", opts.Mask)
+		}
+		i++
+		set, err := lookupCharset(src[i], custom[:])
+		if err != nil {
+			return nil, err
+		}
+		m.positions = append(m.positions, set)
+	}
+	if len(m.positions) == 0 {
+		return nil, fmt.Errorf("掩码为空")
+	}
+
+	m.minLength = len(m.positions)
+	if opts.Increment {
+		m.minLength = min(max(opts.MinLength, 1), len(m.positions))
+		m.desc += fmt.Sprintf(" (增量, 长度 %d-%d)", m.minLength, len(m.positions))
+	}
+	return m, nil
+}
+
+// NewBruteForce 生成暴力破解的掩码：长度从 minLength 到 maxLength，每个位置都使用 charset
+func NewBruteForce(charset string, minLength, maxLength int) (*Mask, error) {
+	set, err := expandCharset(charset, nil)
+	if err != nil {
+		return nil, fmt.Errorf("暴力破解字符集: %w", err)
+	}
+	if minLength < 1 || maxLength < minLength {
+		return nil, fmt.Errorf("无效的长度范围 %d-%d", minLength, maxLength)
+	}
+	m := &Mask{
+		desc:      fmt.Sprintf("暴力破解 %s (长度 %d-%d)", charset, minLength, maxLength),
+		positions: make([][]rune, maxLength),
+		minLength: minLength,
+	}
+	for i := range m.positions {
+		m.positions[i] = set
+	}
+	return m, nil
+}
+
+// expandCharset 展开字符集定义中的 ?x 引用，并去除重复字符
+func expandCharset(spec string, custom [][]rune) ([]rune, error) {
+	var set []rune
+	src := []rune(spec)
+	for i := 0; i < len(src); i++ {
+		if src[i] != '?' {
+			set = append(set, src[i])
+			continue
+		}
+		if i+1 >= len(src) {
+			return nil, fmt.Errorf("'%s' 以单独的 ? 结尾", spec)
+		}
+		i++
+		part, err := lookupCharset(src[i], custom)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, part...)
+	}
+	if len(set) == 0 {
+		return nil, fmt.Errorf("字符集为空")
+	}
+	seen := make(map[rune]bool, len(set))
+	return slices.DeleteFunc(set, func(r rune) bool {
+		dup := seen[r]
+		seen[r] = true
+		return dup
+	}), nil
+}
+
+// lookupCharset 查找 ?x 对应的字符集，custom 为 nil 时不允许引用自定义字符集
+func lookupCharset(c rune, custom [][]rune) ([]rune, error) {
+	if c == '?' {
+		return []rune{'?'}, nil
+	}
+	if set, ok := builtinCharsets[c]; ok {
+		return []rune(set), nil
+	}
+	if c >= '1' && c <= '4' && custom != nil {
+		if set := custom[c-'1']; set != nil {
+			return set, nil
+		}
+		return nil, fmt.Errorf("未定义自定义字符集 ?%c", c)
+	}
+	return nil, fmt.Errorf("未知的字符集 ?%c", c)
+}
+
+// String 返回掩码的描述，用于显示任务摘要
+func (m *Mask) String() string { return m.desc }
+
+// Size 返回掩码的候选密码总数，超出 int64 范围时返回 math.MaxInt64
+func (m *Mask) Size() int64 {
+	var total int64
+	for length := m.minLength; length <= len(m.positions); length++ {
+		n := int64(1)
+		for _, set := range m.positions[:length] {
+			n = mulSaturating(n, int64(len(set)))
+		}
+		total = addSaturating(total, n)
+	}
+	return total
+}
+
+func (m *Mask) Iter() Iterator {
+	return &maskIterator{mask: m, length: m.minLength}
+}
+
+type maskIterator struct {
+	mask    *Mask
+	length  int
+	indices []int // 每个位置当前使用的字符序号，nil 表示当前长度还没有开始
+	buf     []rune
+	done    bool
+}
+
+func (it *maskIterator) Next() (string, bool) {
+	if it.done {
+		return "", false
+	}
+	positions := it.mask.positions
+	if it.indices == nil {
+		it.indices = make([]int, it.length)
+		it.buf = make([]rune, it.length)
+		for i := range it.buf {
+			it.buf[i] = positions[i][0]
+		}
+		return string(it.buf), true
+	}
+
+	// 像里程表一样从最后一个位置开始进位
+	for i := it.length - 1; i >= 0; i-- {
+		it.indices[i]++
+		if it.indices[i] < len(positions[i]) {
+			it.buf[i] = positions[i][it.indices[i]]
+			return string(it.buf), true
+		}
+		it.indices[i] = 0
+		it.buf[i] = positions[i][0]
+	}
+
+	// 当前长度已经穷尽，增量模式下继续下一个长度
+	if it.length >= len(positions) {
+		it.done = true
+		return "", false
+	}
+	it.length++
+	it.indices = nil
+	return it.Next()
+}
+
+func (it *maskIterator) skip(n int64) int64 {
+	positions := it.mask.positions
+	var skipped int64
+	for skipped < n && !it.done {
+		// 当前长度中还没有产生的组合数，组合数超出 int64 时按 math.MaxInt64 计算
+		size := int64(1)
+		for _, set := range positions[:it.length] {
+			size = mulSaturating(size, int64(len(set)))
+		}
+		current := int64(-1) // 最近产生的组合的序号
+		if it.indices != nil {
+			current = 0
+			for i, index := range it.indices {
+				current = current*int64(len(positions[i])) + int64(index)
+			}
+		}
+		remaining := size - 1 - current
+
+		if n-skipped < remaining {
+			it.seek(current + n - skipped)
+			return n
+		}
+		skipped += remaining
+		if it.length >= len(positions) {
+			it.done = true
+			break
+		}
+		it.length++
+		it.indices = nil
+	}
+	return skipped
+}
+
+// seek 把当前长度的位置设置为第 k 个组合，下一次 Next 返回第 k+1 个
+func (it *maskIterator) seek(k int64) {
+	positions := it.mask.positions
+	it.indices = make([]int, it.length)
+	it.buf = make([]rune, it.length)
+	for i := it.length - 1; i >= 0; i-- {
+		base := int64(len(positions[i]))
+		it.indices[i] = int(k % base)
+		it.buf[i] = positions[i][it.indices[i]]
+		k /= base
+	}
+}
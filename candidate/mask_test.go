@@ -0,0 +1,108 @@
+package candidate
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func mustNewMask(t *testing.T, opts MaskOptions) *Mask {
+	t.Helper()
+	m, err := NewMask(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMaskOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		opts MaskOptions
+		want []string
+	}{
+		{"固定字符", MaskOptions{Mask: "ab"}, []string{"ab"}},
+		{"字符集", MaskOptions{Mask: "x?d"}, []string{"x0", "x1", "x2", "x3", "x4", "x5", "x6", "x7", "x8", "x9"}},
+		{"最后一个位置变化最快", MaskOptions{Mask: "?1?1", Charsets: [4]string{"ab"}}, []string{"aa", "ab", "ba", "bb"}},
+		{"自定义字符集引用内置字符集并去重", MaskOptions{Mask: "?1", Charsets: [4]string{"?d0a"}}, []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "a"}},
+		{"问号本身", MaskOptions{Mask: "??"}, []string{"?"}},
+		{"中文", MaskOptions{Mask: "密码?1", Charsets: [4]string{"一二"}}, []string{"密码一", "密码二"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mustNewMask(t, tt.opts)
+			if got := collect(m.Iter()); !slices.Equal(got, tt.want) {
+				t.Errorf("候选密码为 %q，应为 %q", got, tt.want)
+			}
+			if m.Size() != int64(len(tt.want)) {
+				t.Errorf("Size() = %d，应为 %d", m.Size(), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestMaskIncrement(t *testing.T) {
+	tests := []struct {
+		name      string
+		minLength int
+		want      []string
+	}{
+		{"从 1 开始", 1, []string{"a", "b", "a0", "a1", "b0", "b1", "a0x", "a1x", "b0x", "b1x"}},
+		{"最短长度小于 1", 0, []string{"a", "b", "a0", "a1", "b0", "b1", "a0x", "a1x", "b0x", "b1x"}},
+		{"从 2 开始", 2, []string{"a0", "a1", "b0", "b1", "a0x", "a1x", "b0x", "b1x"}},
+		{"最短长度超过掩码", 5, []string{"a0x", "a1x", "b0x", "b1x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mustNewMask(t, MaskOptions{Mask: "?1?2x", Charsets: [4]string{"ab", "01"}, Increment: true, MinLength: tt.minLength})
+			if got := collect(m.Iter()); !slices.Equal(got, tt.want) {
+				t.Errorf("候选密码为 %q，应为 %q", got, tt.want)
+			}
+			if m.Size() != int64(len(tt.want)) {
+				t.Errorf("Size() = %d，应为 %d", m.Size(), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestBruteForce(t *testing.T) {
+	m, err := NewBruteForce("ab", 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a", "b", "aa", "ab", "ba", "bb", "aaa", "aab", "aba", "abb", "baa", "bab", "bba", "bbb"}
+	if got := collect(m.Iter()); !slices.Equal(got, want) {
+		t.Errorf("候选密码为 %q，应为 %q", got, want)
+	}
+
+	for _, r := range [][2]int{{0, 3}, {3, 2}} {
+		if _, err := NewBruteForce("ab", r[0], r[1]); err == nil {
+			t.Errorf("长度范围 %d-%d 应当返回错误", r[0], r[1])
+		}
+	}
+}
+
+func TestMaskSizeSaturates(t *testing.T) {
+	m, err := NewBruteForce("?a", 1, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Size() != math.MaxInt64 {
+		t.Errorf("Size() = %d，应为 math.MaxInt64", m.Size())
+	}
+}
+
+func TestMaskErrors(t *testing.T) {
+	for _, opts := range []MaskOptions{
+		{Mask: ""},
+		{Mask: "abc?"},
+		{Mask: "?x"},
+		{Mask: "?1"},
+		{Mask: "?1", Charsets: [4]string{"?"}},
+		{Mask: "?1", Charsets: [4]string{"?1"}},
+	} {
+		if _, err := NewMask(opts); err == nil {
+			t.Errorf("NewMask(%+v) 应当返回错误", opts)
+		}
+	}
+}
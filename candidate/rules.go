@@ -0,0 +1,431 @@
+package candidate
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// --- hashcat 风格的变形规则 ---
+//
+// 每行一条规则，由若干个单字符函数组成，函数之间的空格可以省略，例如 "c $1 $2 $3" 或 "sa@so0"。
+// 位置参数使用 0-9 和 A-Z (A 表示 10)，超出密码长度的位置会让该函数不起作用。
+// 以 # 开头的行和空行会被忽略，行首行尾的空格不会被去掉。规则按字符 (而不是字节) 处理，中文密码同样适用。
+
+// Rule 是解析后的一条规则
+type Rule struct {
+	text string
+	ops  []ruleOp
+}
+
+// ruleOp 对密码做一次变形，返回 false 表示拒绝这个候选密码
+type ruleOp func(w []rune) ([]rune, bool)
+
+// String 返回规则的原始文本
+func (r Rule) String() string { return r.text }
+
+// Apply 对密码应用规则，规则拒绝该密码时返回 false
+func (r Rule) Apply(word string) (string, bool) {
+	w := []rune(word)
+	for _, op := range r.ops {
+		var ok bool
+		if w, ok = op(w); !ok {
+			return "", false
+		}
+	}
+	return string(w), true
+}
+
+// LoadRules 从规则文件中加载规则，出错时报告所在的行号
+func LoadRules(filePath string) ([]Rule, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("规则文件 '%s' 不存在", filePath)
+		}
+		return nil, fmt.Errorf("无法打开规则文件 '%s': %w", filePath, err)
+	}
+	defer file.Close()
+
+	rules := []Rule{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		// 空格可以是规则的参数 (例如 "$ " 在末尾追加空格)，只去掉行尾的换行符
+		text := strings.TrimRight(scanner.Text(), "\r\n")
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		rule, err := ParseRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("规则文件第 %d 行: %w", line, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取规则文件时出错: %w", err)
+	}
+	return rules, nil
+}
+
+// ParseRule 解析一条规则
+func ParseRule(text string) (Rule, error) {
+	rule := Rule{text: text}
+	src := []rune(text)
+	for i := 0; i < len(src); {
+		fn := src[i]
+		i++
+		if fn == ' ' || fn == '\t' {
+			continue
+		}
+		arity, ok := ruleArity[fn]
+		if !ok {
+			return Rule{}, fmt.Errorf("不支持的规则函数 '%c'", fn)
+		}
+		if i+arity > len(src) {
+			return Rule{}, fmt.Errorf("规则函数 '%c' 缺少参数", fn)
+		}
+		args := src[i : i+arity]
+		i += arity
+		op, err := newRuleOp(fn, args)
+		if err != nil {
+			return Rule{}, err
+		}
+		rule.ops = append(rule.ops, op)
+	}
+	return rule, nil
+}
+
+// ruleArity 是每个规则函数的参数个数
+var ruleArity = map[rune]int{
+	':': 0, 'l': 0, 'u': 0, 'c': 0, 'C': 0, 't': 0, 'E': 0,
+	'r': 0, 'd': 0, 'f': 0, '{': 0, '}': 0, '[': 0, ']': 0, 'q': 0, 'k': 0, 'K': 0,
+	'T': 1, 'p': 1, 'D': 1, '\'': 1, 'z': 1, 'Z': 1, 'y': 1, 'Y': 1,
+	'$': 1, '^': 1, '@': 1,
+	's': 2, 'i': 2, 'o': 2, 'x': 2, 'O': 2, '*': 2,
+	'<': 1, '>': 1, '_': 1, '!': 1, '/': 1, '(': 1, ')': 1,
+}
+
+// position 把位置参数 0-9、A-Z 转换为数字
+func position(c rune) (int, error) {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0'), nil
+	case c >= 'A' && c <= 'Z':
+		return int(c-'A') + 10, nil
+	}
+	return 0, fmt.Errorf("无效的位置参数 '%c'", c)
+}
+
+// newRuleOp 根据函数和参数生成变形操作
+func newRuleOp(fn rune, args []rune) (ruleOp, error) {
+	// 需要位置参数的函数先统一解析
+	var n, m int
+	var err error
+	switch fn {
+	case 'T', 'p', 'D', '\'', 'z', 'Z', 'y', 'Y', 'i', 'o', 'x', 'O', '*', '<', '>', '_':
+		if n, err = position(args[0]); err != nil {
+			return nil, err
+		}
+	}
+	switch fn {
+	case 'x', 'O', '*':
+		if m, err = position(args[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	switch fn {
+	case ':':
+		return func(w []rune) ([]rune, bool) { return w, true }, nil
+	case 'l':
+		return mapRunes(unicode.ToLower), nil
+	case 'u':
+		return mapRunes(unicode.ToUpper), nil
+	case 't':
+		return mapRunes(toggleCase), nil
+	case 'c', 'C':
+		first, rest := unicode.ToUpper, unicode.ToLower
+		if fn == 'C' {
+			first, rest = unicode.ToLower, unicode.ToUpper
+		}
+		return func(w []rune) ([]rune, bool) {
+			for i := range w {
+				if i == 0 {
+					w[i] = first(w[i])
+				} else {
+					w[i] = rest(w[i])
+				}
+			}
+			return w, true
+		}, nil
+	case 'E':
+		return func(w []rune) ([]rune, bool) {
+			for i := range w {
+				if i == 0 || w[i-1] == ' ' {
+					w[i] = unicode.ToUpper(w[i])
+				} else {
+					w[i] = unicode.ToLower(w[i])
+				}
+			}
+			return w, true
+		}, nil
+	case 'T':
+		return func(w []rune) ([]rune, bool) {
+			if n < len(w) {
+				w[n] = toggleCase(w[n])
+			}
+			return w, true
+		}, nil
+	case 'r':
+		return func(w []rune) ([]rune, bool) {
+			slices.Reverse(w)
+			return w, true
+		}, nil
+	case 'd':
+		return func(w []rune) ([]rune, bool) { return append(w, w...), true }, nil
+	case 'p':
+		return func(w []rune) ([]rune, bool) {
+			word := slices.Clone(w)
+			for range n {
+				w = append(w, word...)
+			}
+			return w, true
+		}, nil
+	case 'f':
+		return func(w []rune) ([]rune, bool) {
+			reversed := slices.Clone(w)
+			slices.Reverse(reversed)
+			return append(w, reversed...), true
+		}, nil
+	case '{':
+		return func(w []rune) ([]rune, bool) {
+			if len(w) > 1 {
+				w = append(w[1:], w[0])
+			}
+			return w, true
+		}, nil
+	case '}':
+		return func(w []rune) ([]rune, bool) {
+			if len(w) > 1 {
+				w = append([]rune{w[len(w)-1]}, w[:len(w)-1]...)
+			}
+			return w, true
+		}, nil
+	case '$':
+		return func(w []rune) ([]rune, bool) { return append(w, args[0]), true }, nil
+	case '^':
+		return func(w []rune) ([]rune, bool) { return append([]rune{args[0]}, w...), true }, nil
+	case '[':
+		return func(w []rune) ([]rune, bool) {
+			if len(w) > 0 {
+				w = w[1:]
+			}
+			return w, true
+		}, nil
+	case ']':
+		return func(w []rune) ([]rune, bool) {
+			if len(w) > 0 {
+				w = w[:len(w)-1]
+			}
+			return w, true
+		}, nil
+	case 'D':
+		return func(w []rune) ([]rune, bool) {
+			if n < len(w) {
+				w = slices.Delete(w, n, n+1)
+			}
+			return w, true
+		}, nil
+	case '\'':
+		return func(w []rune) ([]rune, bool) {
+			if n < len(w) {
+				w = w[:n]
+			}
+			return w, true
+		}, nil
+	case 'x':
+		return func(w []rune) ([]rune, bool) {
+			if n+m <= len(w) {
+				w = w[n : n+m]
+			}
+			return w, true
+		}, nil
+	case 'O':
+		return func(w []rune) ([]rune, bool) {
+			if n+m <= len(w) {
+				w = slices.Delete(w, n, n+m)
+			}
+			return w, true
+		}, nil
+	case 'i':
+		return func(w []rune) ([]rune, bool) {
+			if n <= len(w) {
+				w = slices.Insert(w, n, args[1])
+			}
+			return w, true
+		}, nil
+	case 'o':
+		return func(w []rune) ([]rune, bool) {
+			if n < len(w) {
+				w[n] = args[1]
+			}
+			return w, true
+		}, nil
+	case 's':
+		return func(w []rune) ([]rune, bool) {
+			for i := range w {
+				if w[i] == args[0] {
+					w[i] = args[1]
+				}
+			}
+			return w, true
+		}, nil
+	case '@':
+		return func(w []rune) ([]rune, bool) {
+			return slices.DeleteFunc(w, func(r rune) bool { return r == args[0] }), true
+		}, nil
+	case 'z':
+		return func(w []rune) ([]rune, bool) {
+			if len(w) > 0 {
+				w = append(slices.Repeat([]rune{w[0]}, n), w...)
+			}
+			return w, true
+		}, nil
+	case 'Z':
+		return func(w []rune) ([]rune, bool) {
+			if len(w) > 0 {
+				w = append(w, slices.Repeat([]rune{w[len(w)-1]}, n)...)
+			}
+			return w, true
+		}, nil
+	case 'y':
+		return func(w []rune) ([]rune, bool) {
+			if n <= len(w) {
+				w = append(slices.Clone(w[:n]), w...)
+			}
+			return w, true
+		}, nil
+	case 'Y':
+		return func(w []rune) ([]rune, bool) {
+			if n <= len(w) {
+				w = append(w, slices.Clone(w[len(w)-n:])...)
+			}
+			return w, true
+		}, nil
+	case 'q':
+		return func(w []rune) ([]rune, bool) {
+			out := make([]rune, 0, len(w)*2)
+			for _, r := range w {
+				out = append(out, r, r)
+			}
+			return out, true
+		}, nil
+	case 'k':
+		return func(w []rune) ([]rune, bool) {
+			if len(w) > 1 {
+				w[0], w[1] = w[1], w[0]
+			}
+			return w, true
+		}, nil
+	case 'K':
+		return func(w []rune) ([]rune, bool) {
+			if l := len(w); l > 1 {
+				w[l-1], w[l-2] = w[l-2], w[l-1]
+			}
+			return w, true
+		}, nil
+	case '*':
+		return func(w []rune) ([]rune, bool) {
+			if n < len(w) && m < len(w) {
+				w[n], w[m] = w[m], w[n]
+			}
+			return w, true
+		}, nil
+
+	// 以下为拒绝规则，不满足条件时丢弃这个候选密码
+	case '<':
+		return func(w []rune) ([]rune, bool) { return w, len(w) <= n }, nil
+	case '>':
+		return func(w []rune) ([]rune, bool) { return w, len(w) >= n }, nil
+	case '_':
+		return func(w []rune) ([]rune, bool) { return w, len(w) == n }, nil
+	case '!':
+		return func(w []rune) ([]rune, bool) { return w, !slices.Contains(w, args[0]) }, nil
+	case '/':
+		return func(w []rune) ([]rune, bool) { return w, slices.Contains(w, args[0]) }, nil
+	case '(':
+		return func(w []rune) ([]rune, bool) { return w, len(w) > 0 && w[0] == args[0] }, nil
+	case ')':
+		return func(w []rune) ([]rune, bool) { return w, len(w) > 0 && w[len(w)-1] == args[0] }, nil
+	}
+	return nil, fmt.Errorf("不支持的规则函数 '%c'", fn)
+}
+
+func mapRunes(f func(rune) rune) ruleOp {
+	return func(w []rune) ([]rune, bool) {
+		for i := range w {
+			w[i] = f(w[i])
+		}
+		return w, true
+	}
+}
+
+func toggleCase(r rune) rune {
+	if unicode.IsUpper(r) {
+		return unicode.ToLower(r)
+	}
+	return unicode.ToUpper(r)
+}
+
+// WithRules 在密码来源之上应用规则：先依次尝试全部原始密码，再对全部密码依次应用每条规则。
+// 候选密码在遍历时逐个生成，不会一次性展开成列表；变形结果与原密码相同或被拒绝时跳过。
+func WithRules(words Source, rules []Rule) Source {
+	if len(rules) == 0 {
+		return words
+	}
+	return &ruleSource{words: words, rules: rules}
+}
+
+type ruleSource struct {
+	words Source
+	rules []Rule
+}
+
+func (s *ruleSource) Iter() Iterator {
+	return &ruleIterator{source: s, words: s.words.Iter(), rule: -1}
+}
+
+func (s *ruleSource) Size() int64 {
+	return mulSaturating(s.words.Size(), int64(len(s.rules)+1))
+}
+
+type ruleIterator struct {
+	source *ruleSource
+	words  Iterator
+	rule   int // 当前应用的规则序号，-1 表示原始密码
+}
+
+func (it *ruleIterator) Next() (string, bool) {
+	for {
+		word, ok := it.words.Next()
+		if !ok {
+			// 一轮结束后换下一条规则，从头遍历密码
+			it.rule++
+			if it.rule >= len(it.source.rules) {
+				return "", false
+			}
+			it.words = it.source.words.Iter()
+			continue
+		}
+		if it.rule < 0 {
+			return word, true
+		}
+		candidate, ok := it.source.rules[it.rule].Apply(word)
+		if ok && candidate != "" && candidate != word {
+			return candidate, true
+		}
+	}
+}
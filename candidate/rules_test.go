@@ -0,0 +1,156 @@
+package candidate
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRuleApply(t *testing.T) {
+	tests := []struct {
+		rule string
+		word string
+		want string
+		ok   bool
+	}{
+		{":", "Pass", "Pass", true},
+		{"l", "PaSS", "pass", true},
+		{"u", "PaSS", "PASS", true},
+		{"c", "pASS word", "Pass word", true},
+		{"C", "pass", "pASS", true},
+		{"t", "PaSs", "pAsS", true},
+		{"E", "hello WORLD", "Hello World", true},
+		{"T1", "pass", "pAss", true},
+		{"T9", "pass", "pass", true},
+		{"r", "abc", "cba", true},
+		{"d", "ab", "abab", true},
+		{"p2", "ab", "ababab", true},
+		{"f", "abc", "abccba", true},
+		{"{", "abc", "bca", true},
+		{"}", "abc", "cab", true},
+		{"$1", "abc", "abc1", true},
+		{"^1", "abc", "1abc", true},
+		{"$ ", "abc", "abc ", true},
+		{"^ ", "abc", " abc", true},
+		{"[", "abc", "bc", true},
+		{"]", "abc", "ab", true},
+		{"D1", "abc", "ac", true},
+		{"'2", "abcd", "ab", true},
+		{"x12", "abcd", "bc", true},
+		{"x35", "abcd", "abcd", true},
+		{"O12", "abcd", "ad", true},
+		{"i1-", "abc", "a-bc", true},
+		{"i3-", "abc", "abc-", true},
+		{"o0X", "abc", "Xbc", true},
+		{"sa@", "banana", "b@n@n@", true},
+		{"@a", "banana", "bnn", true},
+		{"z2", "abc", "aaabc", true},
+		{"Z2", "abc", "abccc", true},
+		{"y2", "abc", "ababc", true},
+		{"Y2", "abc", "abcbc", true},
+		{"q", "ab", "aabb", true},
+		{"k", "abc", "bac", true},
+		{"K", "abc", "acb", true},
+		{"*02", "abc", "cba", true},
+		{"<3", "abc", "abc", true},
+		{"<2", "abc", "", false},
+		{">3", "abc", "abc", true},
+		{">4", "abc", "", false},
+		{"_3", "abc", "abc", true},
+		{"_2", "abc", "", false},
+		{"!z", "abc", "abc", true},
+		{"!b", "abc", "", false},
+		{"/b", "abc", "abc", true},
+		{"/z", "abc", "", false},
+		{"(a", "abc", "abc", true},
+		{"(b", "abc", "", false},
+		{")c", "abc", "abc", true},
+		{")b", "abc", "", false},
+		{"c $1 $2 $3", "password", "Password123", true},
+		{"sa@so0", "password", "p@ssw0rd", true},
+		{"TA", "abcdefghijk", "abcdefghijK", true},
+		{"u", "密码abc", "密码ABC", true},
+		{"r", "密码", "码密", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule+"/"+tt.word, func(t *testing.T) {
+			rule, err := ParseRule(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := rule.Apply(tt.word)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Apply(%q) = %q, %v，应为 %q, %v", tt.word, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	for _, text := range []string{"X", "$", "s1", "T", "Ta", "x1", "*0a"} {
+		if _, err := ParseRule(text); err == nil {
+			t.Errorf("ParseRule(%q) 应当返回错误", text)
+		}
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.txt")
+	content := "# 注释\r\n\r\n$ \r\n^ \n c\n$1 $2\n#^x\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range rules {
+		w, _ := r.Apply("ab")
+		got = append(got, w)
+	}
+	if want := []string{"ab ", " ab", "Ab", "ab12"}; !slices.Equal(got, want) {
+		t.Errorf("规则的结果为 %q，应为 %q", got, want)
+	}
+
+	if err := os.WriteFile(path, []byte("c\nX\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRules(path); err == nil {
+		t.Error("无效的规则应当返回错误")
+	}
+}
+
+// 先依次尝试原始密码，再对全部密码依次应用每条规则，跳过被拒绝或没有变化的结果
+func TestWithRules(t *testing.T) {
+	rules := []Rule{mustParseRule(t, "u"), mustParseRule(t, "$1"), mustParseRule(t, ">3")}
+	source := WithRules(List{"ab", "CD", "xyz1"}, rules)
+	want := []string{"ab", "CD", "xyz1", "AB", "XYZ1", "ab1", "CD1", "xyz11"}
+	if got := collect(source.Iter()); !slices.Equal(got, want) {
+		t.Errorf("候选密码为 %q，应为 %q", got, want)
+	}
+	if size := source.Size(); size != 12 {
+		t.Errorf("Size() = %d，应为 12", size)
+	}
+}
+
+func mustParseRule(t *testing.T, text string) Rule {
+	t.Helper()
+	rule, err := ParseRule(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rule
+}
+
+func collect(it Iterator) []string {
+	var list []string
+	for {
+		word, ok := it.Next()
+		if !ok {
+			return list
+		}
+		list = append(list, word)
+	}
+}
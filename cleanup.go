@@ -0,0 +1,101 @@
+package main
+
+import (
+	"ArchiveTools/display"
+	"ArchiveTools/extract"
+	"ArchiveTools/store"
+	"ArchiveTools/utils"
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// targetFolder 返回任务的目标文件夹，目标路径是单个文件时返回它所在的文件夹
+func targetFolder(target string) string {
+	if info, err := os.Stat(target); err == nil && !info.IsDir() {
+		target = filepath.Dir(target)
+	}
+	return absPath(target)
+}
+
+// runCleanup 在解压任务全部完成后按 opts.Cleanup 删除或移动源压缩包，processed 为每个已处理完的压缩包的结果。
+// 执行前先列出所有操作，预览模式下到此为止，交互模式下需要用户确认
+func runCleanup(opts taskOptions, archives []utils.Archive, processed map[int]store.SessionArchive) {
+	if !opts.Cleanup.Enabled() {
+		return
+	}
+	cleanup := opts.Cleanup
+	for _, dir := range []*string{&cleanup.DoneDir, &cleanup.FailedDir} {
+		if *dir != "" {
+			*dir = absPath(*dir)
+		}
+	}
+	root := targetFolder(opts.TargetPath)
+
+	var steps, kept []extract.Step
+	for i, a := range archives {
+		result, ok := processed[i]
+		if !ok {
+			continue
+		}
+		volumes := make([]string, len(a.Volumes))
+		for j, v := range a.Volumes {
+			volumes[j] = absPath(v)
+		}
+		name, err := filepath.Rel(root, absPath(a.Path))
+		if err != nil {
+			name = a.Path
+		}
+		step, ok := cleanup.Plan(root, name, volumes, result.Success, result.Skipped)
+		switch {
+		case !ok:
+		case step.Action == extract.Keep:
+			kept = append(kept, step)
+		default:
+			steps = append(steps, step)
+		}
+	}
+
+	display.PrintSection("解压后的操作")
+	for _, step := range kept {
+		display.PrintWarning(step.String())
+	}
+	if len(steps) == 0 {
+		display.PrintInfo("没有需要移动或删除的压缩包。")
+		display.PrintSectionEnd()
+		return
+	}
+	for _, step := range steps {
+		display.PrintInfo(step.String())
+	}
+	display.PrintSectionEnd()
+
+	if opts.DryRun {
+		display.PrintInfo(fmt.Sprintf("预览模式，没有移动或删除任何文件 (共 %d 个压缩包)。", len(steps)))
+		return
+	}
+	if opts.Interactive {
+		display.PrintInputPrompt(fmt.Sprintf("是否对以上 %d 个压缩包执行操作? (y/N): ", len(steps)))
+		reader := bufio.NewReader(os.Stdin)
+		choice, _ := reader.ReadString('\n')
+		if strings.TrimSpace(strings.ToLower(choice)) != "y" {
+			display.PrintInfo("已取消，压缩包保持不变。")
+			return
+		}
+	}
+
+	failed := 0
+	for _, step := range steps {
+		if err := step.Apply(); err != nil {
+			failed++
+			display.PrintWarning(fmt.Sprintf("%s 失败: %v", step, err))
+		}
+	}
+	if failed > 0 {
+		display.PrintWarning(fmt.Sprintf("已处理 %d 个压缩包，%d 个失败。", len(steps)-failed, failed))
+		return
+	}
+	display.PrintSuccess(fmt.Sprintf("已处理 %d 个压缩包。", len(steps)))
+}
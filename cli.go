@@ -0,0 +1,405 @@
+package main
+
+import (
+	"ArchiveTools/candidate"
+	"ArchiveTools/config"
+	"ArchiveTools/cracker"
+	"ArchiveTools/display"
+	"ArchiveTools/extract"
+	"ArchiveTools/report"
+	"ArchiveTools/store"
+	"ArchiveTools/utils"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// 进程退出码，便于脚本区分任务结果
+const (
+	exitSuccess = 0 // 所有压缩包均处理成功
+	exitPartial = 1 // 部分压缩包处理成功
+	exitFailure = 2 // 全部失败、没有可处理的压缩包、参数错误或任务无法开始
+	exitSession = 3 // -session 指定的会话文件已存在，没有开始新的任务
+
+	exitInterrupted = 130 // 任务被 Ctrl-C 中断，与 shell 中被 SIGINT 结束的进程一致
+)
+
+// taskOptions 汇总一次任务所需的全部参数，交互模式与命令行模式共用
+type taskOptions struct {
+	TargetPath    string
+	Scan          utils.ScanOptions
+	Mode          cracker.Mode
+	ExtractMode   int                  // 1: 智能解压, 2: 解压到当前目录, 3: 解压到同名文件夹
+	Conflict      extract.Policy       // 解压出的文件与已有文件同名时的处理方式
+	Unsafe        extract.UnsafePolicy // 压缩包中存在不安全路径 (zip-slip) 时的处理方式
+	Nested        int                  // 解压后继续解压其中的压缩包的最大层数，0 表示不解压
+	Cleanup       extract.Cleanup      // 任务完成后对源压缩包的处理方式
+	DryRun        bool                 // 只列出任务完成后会移动或删除的压缩包，不实际执行
+	Space         extract.SpacePolicy  // 目标磁盘剩余空间不足时的处理方式
+	MaxRatio      float64              // 压缩比超过该值的压缩包标记为可能的压缩炸弹，0 表示不检查
+	PasswordsFile string
+	RulesFile     string // 变形规则文件，为空时不使用规则
+	Context       bool   // 是否先尝试从压缩包名称、上级文件夹、说明文件和注释中提取的密码
+	MaskOptions   candidate.MaskOptions
+	BruteCharset  string // 暴力破解的字符集，为空时不进行暴力破解
+	BruteMin      int
+	BruteMax      int
+	StatsFile     string // 密码命中统计文件，为空时不使用统计
+	StatsByFolder bool   // 是否按压缩包所在文件夹的命中次数排序
+	CacheFile     string // 以压缩包内容为键的密码缓存文件，为空时不使用缓存
+	SessionFile   string // 保存任务进度的会话文件，为空时不记录进度
+	ResultDir     string
+	ResultFormat  string // 结果文件格式: text、jsonl 或 csv
+	Workers       int    // 同时处理的压缩包数量
+	Threads       int    // 单个压缩包内同时尝试的密码数量
+	Interactive   bool   `json:"-"` // 是否在运行过程中通过菜单询问用户
+	Force         bool   `json:"-"` // 会话文件已存在时覆盖，放弃上次未完成的任务
+}
+
+// defaultTaskOptions 返回与交互菜单默认选项一致的任务参数
+func defaultTaskOptions() taskOptions {
+	return taskOptions{
+		Scan:          utils.ScanOptions{ExcludePacked: true},
+		Mode:          cracker.QuickMode,
+		ExtractMode:   1,
+		Conflict:      extract.Overwrite,
+		PasswordsFile: defaultPasswordsFile,
+		RulesFile:     defaultRulesFile,
+		Context:       true,
+		BruteMin:      1,
+		BruteMax:      6,
+		StatsFile:     defaultStatsFile,
+		CacheFile:     defaultCacheFile,
+		SessionFile:   defaultSessionFile,
+		ResultDir:     defaultResultDir,
+		ResultFormat:  config.Cfg.ResultFormat,
+		Workers:       defaultWorkers(),
+		Threads:       1,
+	}
+}
+
+// defaultWorkers 返回默认的并发数。每个任务都会启动 7z 进程，过多的并发反而会互相拖慢
+func defaultWorkers() int {
+	return min(runtime.NumCPU(), 4)
+}
+
+// runCommand 解析命令行子命令并执行，返回进程退出码
+func runCommand(args []string) int {
+	switch args[0] {
+	case "match":
+		opts, ok := parseTaskFlags("match", args[1:])
+		if !ok {
+			return exitFailure
+		}
+		if !checkNoSession(opts) {
+			return exitSession
+		}
+		printTitle()
+		return runPasswordMatcher(opts, nil)
+	case "extract":
+		opts, ok := parseTaskFlags("extract", args[1:])
+		if !ok {
+			return exitFailure
+		}
+		if !checkNoSession(opts) {
+			return exitSession
+		}
+		printTitle()
+		return runExtractor(opts, nil)
+	case "resume":
+		return runResume(args[1:])
+	case "list":
+		opts, ok := parseTaskFlags("list", args[1:])
+		if !ok {
+			return exitFailure
+		}
+		return runList(opts)
+	case "help", "-h", "-help", "--help":
+		printUsage()
+		return exitSuccess
+	default:
+		fmt.Fprintf(os.Stderr, "未知的子命令: %s\n\n", args[0])
+		printUsage()
+		return exitFailure
+	}
+}
+
+// parseTaskFlags 解析子命令的参数，目标路径既可以用 -path 指定，也可以作为位置参数给出
+func parseTaskFlags(name string, args []string) (taskOptions, bool) {
+	opts := defaultTaskOptions()
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+
+	fs.StringVar(&opts.TargetPath, "path", "", "要处理的压缩包或文件夹路径 (默认为当前目录)")
+	fs.BoolVar(&opts.Scan.Recursive, "recursive", opts.Scan.Recursive, "递归扫描子文件夹")
+	fs.BoolVar(&opts.Scan.ExcludePacked, "exclude-packed", opts.Scan.ExcludePacked, "排除已存在同名文件夹的压缩包")
+	fs.BoolVar(&opts.Scan.Sniff, "sniff", opts.Scan.Sniff, "按文件头识别格式，收录扩展名错误或缺失的压缩包")
+	fs.BoolVar(&opts.Scan.SFX, "sfx", opts.Scan.SFX, "识别自解压程序，把 .exe 中嵌入的 RAR/7z/ZIP 当作压缩包处理")
+
+	var mode, extractMode, conflict, unsafe, onSuccess, onFailure, space string
+	switch name {
+	case "match":
+		fs.StringVar(&mode, "mode", "quick", "匹配模式: quick (快速) 或 accurate (精确)")
+	case "extract":
+		fs.StringVar(&extractMode, "extract-mode", "smart", "解压模式: smart (智能), here (当前目录) 或 folder (同名文件夹)")
+		fs.StringVar(&conflict, "conflict", opts.Conflict.String(), "同名文件的处理方式: overwrite (覆盖)、skip (跳过)、rename-new (重命名解压出的文件)、rename-existing (重命名已有文件) 或 abort (跳过整个压缩包)")
+		fs.IntVar(&opts.Nested, "nested", opts.Nested, "解压后继续解压其中的压缩包，最多解压 N 层，0 表示不解压")
+		fs.StringVar(&unsafe, "unsafe", opts.Unsafe.String(), "压缩包中有绝对路径、\"..\" 或指向外部的链接等不安全路径时: refuse (拒绝解压) 或 sanitize (清理后解压)")
+		fs.StringVar(&onSuccess, "on-success", opts.Cleanup.OnSuccess.String(), "任务完成后对解压成功的压缩包: keep (保留)、delete (删除，包括全部分卷) 或 move (移动到 -done-dir)")
+		fs.StringVar(&opts.Cleanup.DoneDir, "done-dir", "", "解压成功的压缩包移动到的文件夹 (默认为目标文件夹中的 done)")
+		fs.StringVar(&onFailure, "on-failure", opts.Cleanup.OnFailure.String(), "任务完成后对解压失败的压缩包: keep (保留) 或 move (移动到 -failed-dir)")
+		fs.StringVar(&opts.Cleanup.FailedDir, "failed-dir", "", "解压失败的压缩包移动到的文件夹 (默认为目标文件夹中的 failed)")
+		fs.BoolVar(&opts.DryRun, "dry-run", false, "只列出任务完成后会移动或删除的压缩包，不实际移动或删除 (仍会解压)")
+		fs.StringVar(&space, "space", opts.Space.String(), "解压后的大小超过目标磁盘剩余空间时: warn (警告后照常解压)、refuse (不解压) 或 off (不检查)")
+		fs.Float64Var(&opts.MaxRatio, "max-ratio", opts.MaxRatio, "压缩比超过该值 (例如 100 表示 100:1) 的压缩包标记为可能的压缩炸弹，0 表示不检查")
+	}
+	if name != "list" {
+		fs.StringVar(&opts.PasswordsFile, "passwords", opts.PasswordsFile, "密码本文件路径")
+		fs.StringVar(&opts.RulesFile, "rules", opts.RulesFile, "变形规则文件路径 (hashcat 规则语法)，为空时不使用规则")
+		fs.BoolVar(&opts.Context, "context", opts.Context, "先尝试从压缩包名称、上级文件夹、说明文件和注释中提取的密码")
+		fs.StringVar(&opts.MaskOptions.Mask, "mask", "", "密码本用完后尝试的掩码，例如 ?d?d?d?d?d?d 或 abc?l?l?d")
+		for i := range opts.MaskOptions.Charsets {
+			fs.StringVar(&opts.MaskOptions.Charsets[i], fmt.Sprintf("charset%d", i+1), "", fmt.Sprintf("掩码中 ?%d 对应的自定义字符集，例如 ?l?d_", i+1))
+		}
+		fs.BoolVar(&opts.MaskOptions.Increment, "increment", false, "增量模式：从 -increment-min 个字符开始依次尝试掩码的每个前缀")
+		fs.IntVar(&opts.MaskOptions.MinLength, "increment-min", 1, "增量模式的最短长度")
+		fs.StringVar(&opts.BruteCharset, "brute", "", "最后进行暴力破解使用的字符集，例如 ?d 或 ?l?d")
+		fs.IntVar(&opts.BruteMin, "brute-min", opts.BruteMin, "暴力破解的最短长度")
+		fs.IntVar(&opts.BruteMax, "brute-max", opts.BruteMax, "暴力破解的最长长度")
+		fs.StringVar(&opts.StatsFile, "stats", opts.StatsFile, "密码命中统计文件，常用的密码会排在前面尝试，为空时不使用统计")
+		fs.BoolVar(&opts.StatsByFolder, "stats-by-folder", opts.StatsByFolder, "优先尝试在压缩包所在文件夹中命中过的密码")
+		fs.StringVar(&opts.CacheFile, "cache", opts.CacheFile, "密码缓存文件，跳过已经找到密码或已用相同密码本尝试过的压缩包，为空时不使用缓存")
+		// 命令行模式常用于计划任务，被强制结束后留下的会话文件不应让之后的每次运行都拒绝启动，因此默认不记录进度
+		opts.SessionFile = ""
+		fs.StringVar(&opts.SessionFile, "session", "", "保存任务进度的文件，中断后可以用 resume 子命令继续，默认不记录进度")
+		fs.BoolVar(&opts.Force, "force", false, "-session 指定的文件已存在时覆盖它，放弃上次未完成的任务")
+		fs.StringVar(&opts.ResultDir, "result-dir", opts.ResultDir, "结果文件保存目录")
+		fs.StringVar(&opts.ResultFormat, "result-format", opts.ResultFormat, "结果文件格式: text (只记录找到的密码)、jsonl 或 csv")
+		fs.IntVar(&opts.Workers, "workers", opts.Workers, "同时处理的压缩包数量")
+		fs.IntVar(&opts.Threads, "threads", opts.Threads, "单个压缩包内同时尝试的密码数量")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return opts, false
+	}
+
+	if fs.NArg() > 1 || (fs.NArg() == 1 && opts.TargetPath != "") {
+		fmt.Fprintln(os.Stderr, "只能指定一个目标路径")
+		return opts, false
+	}
+	if fs.NArg() == 1 {
+		opts.TargetPath = fs.Arg(0)
+	}
+	if opts.TargetPath == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "无法获取当前目录: %v\n", err)
+			return opts, false
+		}
+		opts.TargetPath = wd
+	}
+
+	if opts.Workers < 1 || opts.Threads < 1 {
+		fmt.Fprintln(os.Stderr, "并发数必须大于 0")
+		return opts, false
+	}
+	if opts.Nested < 0 {
+		fmt.Fprintln(os.Stderr, "嵌套的层数不能小于 0")
+		return opts, false
+	}
+	if opts.MaxRatio < 0 {
+		fmt.Fprintln(os.Stderr, "压缩比不能小于 0")
+		return opts, false
+	}
+
+	if _, err := report.ParseFormat(opts.ResultFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return opts, false
+	}
+
+	switch mode {
+	case "", "quick":
+	case "accurate":
+		opts.Mode = cracker.AccurateMode
+	default:
+		fmt.Fprintf(os.Stderr, "无效的匹配模式: %s\n", mode)
+		return opts, false
+	}
+
+	switch extractMode {
+	case "", "smart":
+	case "here":
+		opts.ExtractMode = 2
+	case "folder":
+		opts.ExtractMode = 3
+	default:
+		fmt.Fprintf(os.Stderr, "无效的解压模式: %s\n", extractMode)
+		return opts, false
+	}
+	if conflict != "" {
+		policy, err := extract.ParsePolicy(conflict)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return opts, false
+		}
+		opts.Conflict = policy
+	}
+	if unsafe != "" {
+		policy, err := extract.ParseUnsafePolicy(unsafe)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return opts, false
+		}
+		opts.Unsafe = policy
+	}
+	if space != "" {
+		policy, err := extract.ParseSpacePolicy(space)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return opts, false
+		}
+		opts.Space = policy
+	}
+	if onSuccess != "" {
+		action, err := extract.ParseAction(onSuccess)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return opts, false
+		}
+		opts.Cleanup.OnSuccess = action
+	}
+	if onFailure != "" {
+		action, err := extract.ParseAction(onFailure)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return opts, false
+		}
+		if action == extract.Delete {
+			fmt.Fprintln(os.Stderr, "解压失败的压缩包只能保留或移动，不能删除")
+			return opts, false
+		}
+		opts.Cleanup.OnFailure = action
+	}
+
+	return opts, true
+}
+
+// checkNoSession 确认没有未完成的任务，避免新的任务覆盖上次的进度，指定了 -force 时直接覆盖
+func checkNoSession(opts taskOptions) bool {
+	if opts.SessionFile == "" || !store.SessionExists(opts.SessionFile) {
+		return true
+	}
+	if opts.Force {
+		display.PrintWarning(fmt.Sprintf("放弃未完成的任务，覆盖 %s", opts.SessionFile))
+		return true
+	}
+	fmt.Fprintf(os.Stderr, "存在未完成的任务 (%s)，请使用 resume 子命令继续，或使用 -force 覆盖后重新开始\n", opts.SessionFile)
+	return false
+}
+
+// runResume 从会话文件中恢复上次中断的任务
+func runResume(args []string) int {
+	fs := flag.NewFlagSet("resume", flag.ContinueOnError)
+	path := fs.String("session", defaultSessionFile, "保存任务进度的文件")
+	if err := fs.Parse(args); err != nil {
+		return exitFailure
+	}
+	session, err := store.OpenSession(*path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "没有未完成的任务: %s\n", *path)
+		} else {
+			fmt.Fprintf(os.Stderr, "无法读取任务进度: %v\n", err)
+		}
+		return exitFailure
+	}
+	printTitle()
+	return resumeSession(session)
+}
+
+// resumeSession 按会话中保存的参数继续任务，不再询问用户
+func resumeSession(session *store.Session) int {
+	opts := defaultTaskOptions()
+	if err := session.Options(&opts); err != nil {
+		display.PrintError(fmt.Sprintf("任务参数无效: %v", err))
+		return exitFailure
+	}
+	opts.SessionFile = session.Path()
+	switch session.Command() {
+	case "match":
+		return runPasswordMatcher(opts, session)
+	case "extract":
+		return runExtractor(opts, session)
+	default:
+		display.PrintError(fmt.Sprintf("未知的任务类型: %s", session.Command()))
+		return exitFailure
+	}
+}
+
+// runList 列出扫描到的压缩文件，每行一个路径 (分卷压缩包只列出第一个分卷)，便于在脚本中使用
+func runList(opts taskOptions) int {
+	archives, err := utils.ScanArchives(opts.TargetPath, opts.Scan)
+	if err != nil {
+		display.PrintError(fmt.Sprintf("扫描失败: %v", err))
+		return exitFailure
+	}
+	for _, archive := range archives {
+		fmt.Println(archive.Path)
+		if len(archive.Missing) > 0 {
+			fmt.Fprintf(os.Stderr, "[警告] %s 缺少分卷: %s\n", archive.Path, strings.Join(archive.Missing, ", "))
+		}
+		switch {
+		case archive.IsSFX():
+			fmt.Fprintf(os.Stderr, "[提示] %s 是自解压程序，内含 %s 压缩包\n", archive.Path, archive.Format.Name)
+		case archive.Sniffed():
+			fmt.Fprintf(os.Stderr, "[提示] %s 按文件头识别为 %s\n", archive.Path, archive.Format.Name)
+		}
+	}
+	return exitSuccess
+}
+
+// printUsage 打印命令行用法
+func printUsage() {
+	exe := filepath.Base(os.Args[0])
+	lines := []string{
+		"用法:",
+		fmt.Sprintf("  %s                       不带参数启动交互模式", exe),
+		fmt.Sprintf("  %s match [选项] [路径]    批量匹配压缩包密码", exe),
+		fmt.Sprintf("  %s extract [选项] [路径]  使用密码本批量解压", exe),
+		fmt.Sprintf("  %s list [选项] [路径]     列出扫描到的压缩包", exe),
+		fmt.Sprintf("  %s resume [-session 文件] 继续上次中断的任务", exe),
+		"",
+		fmt.Sprintf("使用 \"%s <子命令> -h\" 查看子命令的全部选项。", exe),
+		"",
+		"退出码:",
+		"  0    全部成功",
+		"  1    部分成功",
+		"  2    全部失败、没有可处理的压缩包或发生错误",
+		"  3    -session 指定的任务进度文件已存在，没有开始新的任务",
+		"  130  被 Ctrl-C 中断，可以使用 resume 继续",
+	}
+	fmt.Println(strings.Join(lines, "\n"))
+}
+
+// modeName 返回匹配模式的显示名称
+func modeName(mode cracker.Mode) string {
+	if mode == cracker.AccurateMode {
+		return "精确模式"
+	}
+	return "快速模式"
+}
+
+// exitCodeFor 根据成功数量与总数计算退出码，没有压缩包 (total 为 0) 时视为失败
+func exitCodeFor(succeeded, total int) int {
+	switch {
+	case total > 0 && succeeded == total:
+		return exitSuccess
+	case succeeded > 0:
+		return exitPartial
+	default:
+		return exitFailure
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"ArchiveTools/extract"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 被强制结束后留下的会话文件不应影响之后默认参数的运行，只有指定了同一个会话文件时才拒绝启动
+func TestStaleSession(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "session.json")
+	if err := os.WriteFile(stale, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.json")
+
+	tests := []struct {
+		name    string
+		args    []string
+		session string
+		ok      bool
+	}{
+		{"默认不记录进度", []string{dir}, "", true},
+		{"会话文件已存在", []string{"-session", stale, dir}, stale, false},
+		{"覆盖已存在的会话文件", []string{"-session", stale, "-force", dir}, stale, true},
+		{"会话文件不存在", []string{"-session", missing, dir}, missing, true},
+	}
+	for _, tt := range tests {
+		for _, command := range []string{"match", "extract"} {
+			t.Run(command+"/"+tt.name, func(t *testing.T) {
+				opts, ok := parseTaskFlags(command, tt.args)
+				if !ok {
+					t.Fatal("参数解析失败")
+				}
+				if opts.SessionFile != tt.session {
+					t.Errorf("SessionFile = %q，应为 %q", opts.SessionFile, tt.session)
+				}
+				if got := checkNoSession(opts); got != tt.ok {
+					t.Errorf("checkNoSession() = %v，应为 %v", got, tt.ok)
+				}
+			})
+		}
+	}
+
+	for _, command := range []string{"match", "extract"} {
+		if code := runCommand([]string{command, "-session", stale, dir}); code != exitSession {
+			t.Errorf("%s: 存在未完成的任务时退出码为 %d，应为 %d", command, code, exitSession)
+		}
+	}
+	if _, err := os.Stat(stale); err != nil {
+		t.Errorf("拒绝启动时不应删除会话文件: %v", err)
+	}
+}
+
+// 不指定 -conflict 时与原来 7z -y 的行为一致，覆盖已有的文件
+func TestConflictFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		want extract.Policy
+	}{
+		{[]string{"."}, extract.Overwrite},
+		{[]string{"-conflict", "rename-new", "."}, extract.RenameNew},
+		{[]string{"-conflict", "ABORT", "."}, extract.Abort},
+	}
+	for _, tt := range tests {
+		opts, ok := parseTaskFlags("extract", tt.args)
+		if !ok {
+			t.Fatalf("%v: 参数解析失败", tt.args)
+		}
+		if opts.Conflict != tt.want {
+			t.Errorf("%v: Conflict = %v，应为 %v", tt.args, opts.Conflict, tt.want)
+		}
+	}
+}
+
+func TestExitCodeFor(t *testing.T) {
+	tests := []struct {
+		succeeded, total, want int
+	}{
+		{3, 3, exitSuccess},
+		{1, 3, exitPartial},
+		{0, 3, exitFailure},
+		{0, 0, exitFailure},
+	}
+	for _, tt := range tests {
+		if got := exitCodeFor(tt.succeeded, tt.total); got != tt.want {
+			t.Errorf("exitCodeFor(%d, %d) = %d，应为 %d", tt.succeeded, tt.total, got, tt.want)
+		}
+	}
+}
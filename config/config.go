@@ -1,6 +1,10 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -8,7 +12,19 @@ import (
 
 // AppConfig 保存应用程序的全局配置
 type AppConfig struct {
-	SevenZipPath string
+	SevenZipPath string        `json:"-"`
+	Context      ContextConfig `json:"context"`
+	ResultFormat string        `json:"result_format"` // 结果文件格式: text、jsonl 或 csv，可以被命令行参数覆盖
+}
+
+// ContextConfig 控制从压缩包名称、上级文件夹、说明文件和注释中提取候选密码的方式
+type ContextConfig struct {
+	// Patterns 是从文本中提取密码的正则表达式，第一个捕获组 (没有捕获组时为整个匹配) 作为密码
+	Patterns []string `json:"patterns"`
+	// NoteFiles 是与压缩包同目录、需要读取的说明文件名的正则表达式
+	NoteFiles []string `json:"note_files"`
+	// ParentDepth 是参与提取的上级文件夹层数
+	ParentDepth int `json:"parent_depth"`
 }
 
 // Cfg 是全局唯一的配置实例
@@ -17,7 +33,36 @@ var Cfg *AppConfig
 func init() {
 	Cfg = &AppConfig{
 		SevenZipPath: findExecutable("7z", "7za"),
+		Context: ContextConfig{
+			Patterns: []string{
+				`(?i)(?:解压密码|解压码|密码|口令|提取码|password|passwd|pwd)\s*(?:[:：=]|是|为)?\s*([^\s，。；,;【】\[\]()（）「」《》]+)`,
+			},
+			NoteFiles: []string{
+				`(?i)^(解压)?密码.*\.txt$`,
+				`(?i)^(password|passwd|pwd).*\.txt$`,
+				`(?i)^(readme|read me|说明|必读|使用说明).*\.txt$`,
+			},
+			ParentDepth: 2,
+		},
+		ResultFormat: "text",
+	}
+}
+
+// Load 从 JSON 配置文件读取配置，文件中出现的字段覆盖默认值，文件不存在时保持默认配置
+func Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// 记事本保存的 UTF-8 文件可能带有 BOM
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if err := json.Unmarshal(data, Cfg); err != nil {
+		return fmt.Errorf("配置文件 '%s' 格式错误: %w", path, err)
 	}
+	return nil
 }
 
 // findExecutable 优先在程序工作目录查找，然后才依赖系统 PATH
@@ -2,116 +2,209 @@ package cracker
 
 import (
 	"ArchiveTools/config"
+	"ArchiveTools/format"
+	"ArchiveTools/utils"
 	"context"
-	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
-	"time"
 )
 
 // Mode 定义了破解模式
 type Mode int
 
 const (
-	// QuickMode 快速模式，超时即成功
+	// QuickMode 快速模式：只校验一个加密条目 (进程内校验，或用 7z 测试体积最小的加密文件)
 	QuickMode Mode = iota
-	// AccurateMode 精确模式，等待进程返回结果
+	// AccurateMode 精确模式：校验全部加密内容
 	AccurateMode
 )
 
-// Cracker 定义了破解器的接口
+// Cracker 定义了破解器的接口，实现需要支持多个协程同时调用 TryPassword
+// Probe 需要在第一次 TryPassword 之前调用，探测结果会被之后的尝试共用
 type Cracker interface {
+	Probe(ctx context.Context) (Encryption, error)
 	TryPassword(ctx context.Context, password string) (bool, error)
 	Extract(ctx context.Context, password, destPath string) error
-	ListRootItems(ctx context.Context, password string) ([]string, error)
+	ListEntries(ctx context.Context, password string) ([]Entry, error)
+	// Comment 返回压缩包的注释，没有注释或文件头加密时返回空字符串
+	Comment(ctx context.Context) (string, error)
 }
 
-// NewCracker 是一个工厂函数，根据文件类型返回合适的破解器
-func NewCracker(filePath string, mode Mode, timeout time.Duration) (Cracker, error) {
-	ext := strings.ToLower(filepath.Ext(filePath))
-	switch ext {
-	case ".rar", ".7z", ".zip":
-		return newCommandCracker(filePath, mode, timeout)
+// NewCracker 是一个工厂函数，根据压缩格式返回合适的破解器
+// 分卷压缩包只需打开 archive.Path，其余分卷由 7z 或原生破解器自动读取
+func NewCracker(archive utils.Archive, mode Mode) (Cracker, error) {
+	switch archive.Format {
+	case format.ZIP:
+		// 优先使用原生校验，分卷 ZIP 或无法解析时回退到 7z
+		if !archive.IsMultiVolume() {
+			if c, err := newZipCracker(archive, mode); err == nil {
+				return c, nil
+			}
+		}
+		return newCommandCracker(archive, mode)
+	case format.SevenZip:
+		// 优先使用原生校验，无法解析时回退到 7z
+		if c, err := newSevenZipCracker(archive, mode); err == nil {
+			return c, nil
+		}
+		return newCommandCracker(archive, mode)
+	case format.RAR:
+		// RAR5 优先比对压缩包内保存的密码校验值，RAR4 等情况回退到 7z
+		if c, err := newRarCracker(archive, mode); err == nil {
+			return c, nil
+		}
+		return newCommandCracker(archive, mode)
 	default:
-		return nil, fmt.Errorf("不支持的文件类型: %s", ext)
+		// 其他格式统一交给 7z
+		return newCommandCracker(archive, mode)
 	}
 }
 
-// --- 命令行破解器 (用于 rar, 7z, zip) ---
+// --- 命令行破解器 (用于 7z 支持的所有格式) ---
 
 type commandCracker struct {
 	filePath string
+	format   *format.Format
 	mode     Mode
-	timeout  time.Duration
 	command  string
-	baseArgs []string
+	// 扩展名与内容不符时传给 7z 的 -t 参数，否则为空
+	typeSwitch string
+	volumes    []string // 按顺序排列的全部分卷，普通压缩包只有一个
+	offset     int64    // 自解压程序中压缩包数据的起始位置
+
+	// 以下字段由 Probe 写入，之后只读
+	encryption Encryption
+	probeEntry string // 快速模式下单独测试的条目，为空时测试整个压缩包
 }
 
-func newCommandCracker(filePath string, mode Mode, timeout time.Duration) (Cracker, error) {
-	ext := strings.ToLower(filepath.Ext(filePath))
-	var cmdPath string
-	var args []string
-
+func newCommandCracker(archive utils.Archive, mode Mode) (Cracker, error) {
 	// 统一使用 7z 来处理所有支持的格式
-	cmdPath = config.Cfg.SevenZipPath
-	args = []string{"t"}
-
-	switch ext {
-	case ".rar", ".7z", ".zip":
-		// 所有支持的类型都使用相同的命令和参数
-	default:
-		return nil, errors.New("内部错误: 不支持的命令行破解类型")
+	if archive.Format == nil {
+		return nil, fmt.Errorf("不支持的文件类型: %s", filepath.Base(archive.Path))
 	}
 
 	return &commandCracker{
-		filePath: filePath,
-		mode:     mode,
-		timeout:  timeout,
-		command:  cmdPath,
-		baseArgs: args,
+		filePath:   archive.Path,
+		format:     archive.Format,
+		mode:       mode,
+		command:    config.Cfg.SevenZipPath,
+		typeSwitch: typeSwitch(archive),
+		volumes:    archive.Volumes,
+		offset:     archive.Offset,
 	}, nil
 }
 
-func (c *commandCracker) TryPassword(parentCtx context.Context, password string) (bool, error) {
-	fileName := filepath.Base(c.filePath)
-	workDir := filepath.Dir(c.filePath)
-	fullArgs := append(c.baseArgs, fmt.Sprintf("-p%s", password), fileName)
-
-	ctx, cancel := context.WithTimeout(parentCtx, c.timeout)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, c.command, fullArgs...)
-	cmd.Dir = workDir // 设置工作目录
-	hideWindow(cmd)
+// typeSwitch 在格式由文件头识别得出时返回 7z 的 -t 参数
+// 7z 会优先按扩展名打开文件，例如把 RAR 命名为 .zip 时会先当作 ZIP 打开并报错
+func typeSwitch(archive utils.Archive) string {
+	if !archive.Sniffed() {
+		return ""
+	}
+	if archive.Format == format.RAR {
+		// 7z 中 RAR5 是单独的格式
+		if isRar5(archive.Path, archive.Offset) {
+			return "-trar5"
+		}
+	}
+	return "-t" + archive.Format.Type
+}
 
+func (c *commandCracker) TryPassword(ctx context.Context, password string) (bool, error) {
 	if c.mode == QuickMode {
-		err := cmd.Run()
-		if ctx.Err() == context.DeadlineExceeded {
-			return true, nil
+		switch {
+		case c.encryption == EncryptionHeaders:
+			return c.tryHeaders(ctx, password)
+		case c.probeEntry != "":
+			return c.test(ctx, password, c.probeEntry)
 		}
+	}
+	return c.test(ctx, password)
+}
+
+// test 运行 7z t 测试整个压缩包，或只测试给出的条目
+func (c *commandCracker) test(ctx context.Context, password string, entries ...string) (bool, error) {
+	args := append(append([]string{"t", "-p" + password, "-spd"}, c.target()...), entries...)
+	if _, err := c.run(ctx, args...); err != nil {
 		if _, ok := err.(*exec.ExitError); ok {
 			return false, nil
 		}
-		return err == nil, err
+		return false, err
 	}
+	return true, nil
+}
 
-	err := cmd.Run()
-	if err == nil {
+// tryHeaders 用于文件头加密的压缩包：先尝试列出文件，成功后再测试其中最小的加密条目
+func (c *commandCracker) tryHeaders(ctx context.Context, password string) (bool, error) {
+	output, err := c.run(ctx, append([]string{"l", "-slt", "-sccUTF-8", "-p" + password}, c.target()...)...)
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return false, nil
+		}
+		return false, err
+	}
+	_, entries := parseEntries(string(output))
+	entry, encrypted := smallestEncryptedEntry(entries)
+	if !encrypted || entry == "" {
+		// 能解开文件头就说明密码正确
 		return true, nil
 	}
-	if _, ok := err.(*exec.ExitError); ok {
-		return false, nil
+	return c.test(ctx, password, entry)
+}
+
+// target 返回命令行末尾指定压缩包的参数，扩展名与内容不符时附带 -t 指明格式
+func (c *commandCracker) target() []string {
+	if c.typeSwitch != "" {
+		return []string{c.typeSwitch, "--", filepath.Base(c.filePath)}
 	}
-	return false, err
+	return []string{"--", filepath.Base(c.filePath)}
+}
+
+// run 在压缩包所在目录执行 7z，返回合并后的输出
+func (c *commandCracker) run(ctx context.Context, args ...string) ([]byte, error) {
+	return c.command7z(ctx, args...).CombinedOutput()
+}
+
+// command7z 创建一个在压缩包所在目录执行的 7z 命令
+func (c *commandCracker) command7z(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, c.command, args...)
+	cmd.Dir = filepath.Dir(c.filePath) // 设置工作目录
+	cmd.Env = messageEnv(os.Environ())
+	setupCommand(cmd)
+	return cmd
+}
+
+// messageEnv 让 7z 以英文输出提示信息，便于识别密码错误
+// 字符集仍沿用原来的设置，避免影响非 ASCII 文件名；LC_ALL 会覆盖 LC_MESSAGES，因此把它改写为 LC_CTYPE
+func messageEnv(env []string) []string {
+	out := make([]string, 0, len(env)+2)
+	var all string
+	for _, kv := range env {
+		switch {
+		case strings.HasPrefix(kv, "LC_ALL="):
+			all = strings.TrimPrefix(kv, "LC_ALL=")
+		case strings.HasPrefix(kv, "LC_MESSAGES="), strings.HasPrefix(kv, "LANGUAGE="):
+		default:
+			out = append(out, kv)
+		}
+	}
+	if all != "" {
+		ctype := out[:0]
+		for _, kv := range out {
+			if !strings.HasPrefix(kv, "LC_CTYPE=") {
+				ctype = append(ctype, kv)
+			}
+		}
+		out = append(ctype, "LC_CTYPE="+all)
+	}
+	return append(out, "LC_MESSAGES=C")
 }
 
 func (c *commandCracker) Extract(ctx context.Context, password, destPath string) error {
 	// 统一使用 7z 进行解压，因为它兼容 rar 且行为更可预测
 	command := config.Cfg.SevenZipPath
-	fileName := filepath.Base(c.filePath)
 	workDir := filepath.Dir(c.filePath)
 
 	// 确保目标路径是绝对路径
@@ -119,19 +212,21 @@ func (c *commandCracker) Extract(ctx context.Context, password, destPath string)
 	if err != nil {
 		return fmt.Errorf("无法获取绝对目标路径: %w", err)
 	}
+	if c.format.Tar {
+		return c.extractTar(ctx, password, absDestPath)
+	}
 
-	// 7z x <fileName> -p<password> -o<absDestPath> -y
-	args := []string{
+	// 7z x -p<password> -o<absDestPath> -y -- <fileName>
+	args := append([]string{
 		"x",
-		fileName,
 		fmt.Sprintf("-p%s", password),
 		fmt.Sprintf("-o%s", absDestPath),
 		"-y", // Assume Yes on all queries
-	}
+	}, c.target()...)
 
 	cmd := exec.CommandContext(ctx, command, args...)
 	cmd.Dir = workDir // 设置工作目录
-	hideWindow(cmd)
+	setupCommand(cmd)
 
 	// 使用 CombinedOutput 来捕获所有输出，以便在出错时提供更详细的信息
 	output, err := cmd.CombinedOutput()
@@ -141,60 +236,3 @@ func (c *commandCracker) Extract(ctx context.Context, password, destPath string)
 
 	return nil
 }
-
-func (c *commandCracker) ListRootItems(ctx context.Context, password string) ([]string, error) {
-	command := config.Cfg.SevenZipPath
-	fileName := filepath.Base(c.filePath)
-	workDir := filepath.Dir(c.filePath)
-
-	// 7z l <fileName> -p<password>
-	args := []string{"l", fileName, fmt.Sprintf("-p%s", password)}
-
-	cmd := exec.CommandContext(ctx, command, args...)
-	cmd.Dir = workDir
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		// 如果返回错误，但输出中包含 "Wrong password"，则返回一个特定的错误类型
-		if strings.Contains(string(output), "Wrong password") {
-			return nil, errors.New("wrong password")
-		}
-		return nil, fmt.Errorf("无法列出文件: %w\n--- 7z 输出 ---\n%s", err, string(output))
-	}
-
-	// --- 解析 7z 输出 ---
-	// 7z l 的输出格式大致如下:
-	// ... (header info)
-	// ------------------- ----- ------------ ------------  ------------------------
-	// 2025-08-16 16:00:00 D....            0            0  FolderName
-	// 2025-08-16 16:00:00 .....        12345        54321  FolderName\file1.txt
-	// 2025-08-16 16:00:00 .....         6789         9876  file2.txt
-	// ------------------- ----- ------------ ------------  ------------------------
-	// ... (footer info)
-
-	lines := strings.Split(string(output), "\n")
-	var items []string
-	var dataSection bool
-	separator := "-------------------"
-
-	for _, line := range lines {
-		if strings.HasPrefix(line, separator) {
-			dataSection = !dataSection
-			continue
-		}
-		if dataSection {
-			// 找到最后一个空格，之后的内容就是文件名/路径
-			lastSpaceIndex := strings.LastIndex(line, "  ")
-			if lastSpaceIndex > 0 && len(line) > lastSpaceIndex+2 {
-				itemPath := strings.TrimSpace(line[lastSpaceIndex+2:])
-				// 我们只关心根目录下的项目
-				// 不包含 '\' 或 '/' 的就是根目录项目
-				if !strings.Contains(itemPath, string(os.PathSeparator)) {
-					items = append(items, itemPath)
-				}
-			}
-		}
-	}
-
-	return items, nil
-}
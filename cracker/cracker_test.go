@@ -0,0 +1,34 @@
+package cracker
+
+import (
+	"ArchiveTools/utils"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testModes = map[Mode]string{QuickMode: "快速模式", AccurateMode: "精确模式"}
+
+func readTestData(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// testArchive 按扫描压缩包的规则识别 path，返回它所在的压缩包
+func testArchive(t *testing.T, path string) utils.Archive {
+	t.Helper()
+	archives := utils.ScanFiles([]string{path}, utils.ScanOptions{})
+	if len(archives) != 1 {
+		t.Fatalf("无法识别压缩包 %s", path)
+	}
+	return archives[0]
+}
+
+// no7z 让破解器中的 7z 命令指向不存在的文件，确保测试只走进程内的校验
+func no7z(t *testing.T, c *commandCracker) {
+	c.command = filepath.Join(t.TempDir(), "7z")
+}
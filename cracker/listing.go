@@ -0,0 +1,188 @@
+package cracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrWrongPassword 表示列出文件时 7z 报告密码错误
+var ErrWrongPassword = errors.New("密码错误")
+
+// Entry 是压缩包中的一个条目，由 7z l -slt 的技术格式输出解析而来
+type Entry struct {
+	Path       string    // 压缩包内的路径，统一使用 / 分隔
+	Size       int64     // 解压后的大小，未知时为 -1
+	PackedSize int64     // 压缩后的大小，未知时为 -1 (固实压缩中通常只有块内第一个条目有值)
+	Attributes string    // 7z 显示的属性字符串，例如 "A" 或 "D"
+	CRC        uint32    // HasCRC 为 false 时无意义
+	HasCRC     bool      // 目录和部分格式的条目没有 CRC
+	Encrypted  bool      // 条目内容是否加密
+	IsDir      bool      // 是否为文件夹
+	Method     string    // 压缩方法，例如 "LZMA2:24 7zAES:19"
+	Modified   time.Time // 修改时间，未知时为零值
+	Link       string    // 符号链接或硬链接指向的路径，不是链接或 7z 没有给出时为空
+}
+
+// sltTimeLayout 是 -slt 输出中时间字段的格式，部分格式会在秒之后附带小数
+const sltTimeLayout = "2006-01-02 15:04:05"
+
+// ListEntries 使用 7z l -slt 列出压缩包中的全部条目
+func (c *commandCracker) ListEntries(ctx context.Context, password string) ([]Entry, error) {
+	if c.format.Tar {
+		return c.listTarEntries(ctx, password)
+	}
+	output, err := c.run(ctx, append([]string{"l", "-slt", "-sccUTF-8", "-p" + password}, c.target()...)...)
+	if err != nil {
+		if c.wrongPassword(err, output) {
+			return nil, ErrWrongPassword
+		}
+		return nil, fmt.Errorf("无法列出文件: %w\n--- 7z 输出 ---\n%s", err, string(output))
+	}
+	_, entries := parseEntries(string(output))
+	return entries, nil
+}
+
+// Comment 使用 7z l -slt 读取压缩包的注释
+func (c *commandCracker) Comment(ctx context.Context) (string, error) {
+	if c.format.Tar || !c.format.Encryptable {
+		// 只有 ZIP、RAR、7z 这类格式可以带注释
+		return "", nil
+	}
+	output, err := c.run(ctx, append([]string{"l", "-slt", "-sccUTF-8", "-p" + probePassword()}, c.target()...)...)
+	if err != nil {
+		if c.wrongPassword(err, output) {
+			// 文件头加密时不输入密码无法读取注释
+			return "", nil
+		}
+		return "", fmt.Errorf("无法读取注释: %w\n--- 7z 输出 ---\n%s", err, string(output))
+	}
+	archive, _ := parseEntries(string(output))
+	return archive["Comment"], nil
+}
+
+// parseEntries 将 -slt 输出解析为条目列表，同时返回压缩包本身的属性
+func parseEntries(output string) (map[string]string, []Entry) {
+	archive, blocks := parseSlt(output)
+	entries := make([]Entry, 0, len(blocks))
+	for _, b := range blocks {
+		path, ok := b["Path"]
+		if !ok {
+			continue
+		}
+		e := Entry{
+			Path:       filepath.ToSlash(path),
+			Size:       parseSltSize(b["Size"]),
+			PackedSize: parseSltSize(b["Packed Size"]),
+			Attributes: b["Attributes"],
+			Encrypted:  b["Encrypted"] == "+",
+			IsDir:      b["Folder"] == "+" || strings.HasPrefix(b["Attributes"], "D"),
+			Method:     b["Method"],
+			Link:       b["Symbolic Link"],
+		}
+		if e.Link == "" {
+			e.Link = b["Hard Link"]
+		}
+		if crc, err := strconv.ParseUint(b["CRC"], 16, 32); err == nil {
+			e.CRC, e.HasCRC = uint32(crc), true
+		}
+		if modified := b["Modified"]; len(modified) >= len(sltTimeLayout) {
+			if t, err := time.ParseInLocation(sltTimeLayout, modified[:len(sltTimeLayout)], time.Local); err == nil {
+				e.Modified = t
+			}
+		}
+		entries = append(entries, e)
+	}
+	return archive, entries
+}
+
+func parseSltSize(s string) int64 {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// parseSlt 解析 7z l -slt 的输出，返回压缩包本身的属性和每个条目的属性
+//
+// 输出格式大致如下，"--" 之后是压缩包属性，"----------" 之后是以空行分隔的条目:
+//
+//	--
+//	Path = test.7z
+//	Type = 7z
+//	Comment = 第一行
+//	第二行
+//
+//	----------
+//	Path = a.txt
+//	Size = 5
+//	Encrypted = +
+func parseSlt(output string) (map[string]string, []map[string]string) {
+	archive := make(map[string]string)
+	var entries []map[string]string
+	var current map[string]string
+	section := 0 // 0: 前导信息，1: 压缩包属性，2: 条目列表
+	// 压缩包属性中的最后一个键，多行注释的后续行 (包括空行) 需要接到它的值后面
+	lastKey := ""
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		switch {
+		case line == "--" && section == 0:
+			section = 1
+			continue
+		case line == "----------" && section <= 1:
+			section = 2
+			continue
+		case section == 0:
+			continue
+		case section == 1 && lastKey == "Comment" && !isSltProperty(line):
+			archive[lastKey] += "\n" + line
+			continue
+		case line == "":
+			current = nil
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, " =")
+		if !ok {
+			continue
+		}
+		value = strings.TrimPrefix(value, " ")
+		if section == 1 {
+			archive[key] = value
+			lastKey = key
+			continue
+		}
+		if current == nil {
+			current = make(map[string]string)
+			entries = append(entries, current)
+		}
+		current[key] = value
+	}
+	if comment, ok := archive["Comment"]; ok {
+		// 压缩包属性与条目列表之间的空行也被接到了注释后面
+		archive["Comment"] = strings.TrimRight(comment, "\n")
+	}
+	return archive, entries
+}
+
+// isSltProperty 判断一行是否为 7z 输出的属性，属性名由英文字母、数字和空格组成，例如 "Physical Size = 123"。
+// 注释的后续行中也可能出现 " ="，例如 "解压密码 = 123"，这样的行仍然属于注释
+func isSltProperty(line string) bool {
+	key, _, ok := strings.Cut(line, " =")
+	if !ok || key == "" {
+		return false
+	}
+	for _, r := range key {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == ' ') {
+			return false
+		}
+	}
+	return true
+}
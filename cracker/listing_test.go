@@ -0,0 +1,181 @@
+package cracker
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 按 7-Zip 23.01 列出加密 7z 压缩包时的输出格式整理，注释有多行，其中包括空行和带 " =" 的行
+const slt7z = `
+7-Zip 23.01 (x64) : Copyright (c) 1999-2023 Igor Pavlov : 2023-06-20
+ 64-bit locale=zh_CN.UTF-8 Threads:8 OPEN_MAX:1024
+
+Scanning the drive for archives:
+1 file, 1432 bytes (2 KiB)
+
+Listing archive: test.7z
+
+--
+Path = test.7z
+Type = 7z
+Physical Size = 1432
+Headers Size = 254
+Method = LZMA2:12 7zAES
+Solid = +
+Blocks = 1
+Comment = 第一行
+解压密码 = abc123
+
+最后一行
+
+----------
+Path = docs
+Size = 0
+Packed Size = 0
+Modified = 2024-01-02 03:04:05.1234567
+Attributes = D
+CRC =
+Encrypted = -
+Method =
+Block =
+
+Path = docs/a  b.txt
+Size = 12
+Packed Size = 48
+Modified = 2024-01-02 03:04:05.1234567
+Attributes = A
+CRC = 3610A686
+Encrypted = +
+Method = LZMA2:12 7zAES:19
+Block = 0
+
+Path = docs/empty.txt
+Size = 0
+Packed Size = 0
+Modified = 2024-01-02 03:04:06
+Attributes = A
+CRC =
+Encrypted = +
+Method =
+Block =
+`
+
+// 按 Windows 上 7z 的输出格式整理：使用 \ 分隔路径、以 CRLF 换行，ZIP 的条目使用 Folder 表示文件夹
+const sltWindowsZip = "7-Zip 23.01 (x64) : Copyright (c) 1999-2023 Igor Pavlov : 2023-06-20\r\n" +
+	"\r\n" +
+	"Listing archive: C:\\test.zip\r\n" +
+	"\r\n" +
+	"--\r\n" +
+	"Path = C:\\test.zip\r\n" +
+	"Type = zip\r\n" +
+	"Physical Size = 312\r\n" +
+	"\r\n" +
+	"----------\r\n" +
+	"Path = dir\r\n" +
+	"Folder = +\r\n" +
+	"Size = 0\r\n" +
+	"Packed Size = 0\r\n" +
+	"Modified = 2024-05-06 07:08:09\r\n" +
+	"Attributes = D\r\n" +
+	"Encrypted = -\r\n" +
+	"CRC = \r\n" +
+	"Method = Store\r\n" +
+	"\r\n" +
+	"Path = dir\\link\r\n" +
+	"Folder = -\r\n" +
+	"Size = 7\r\n" +
+	"Packed Size = 7\r\n" +
+	"Modified = 2024-05-06 07:08:09\r\n" +
+	"Attributes = A -rwxrwxrwx\r\n" +
+	"Encrypted = -\r\n" +
+	"CRC = 0A1B2C3D\r\n" +
+	"Method = Store\r\n" +
+	"Symbolic Link = ../target\r\n"
+
+func TestParseSlt(t *testing.T) {
+	archive, entries := parseSlt(slt7z)
+	if archive["Type"] != "7z" || archive["Blocks"] != "1" {
+		t.Errorf("压缩包属性为 %v", archive)
+	}
+	if want := "第一行\n解压密码 = abc123\n\n最后一行"; archive["Comment"] != want {
+		t.Errorf("Comment = %q，应为 %q", archive["Comment"], want)
+	}
+	if _, ok := archive["解压密码"]; ok {
+		t.Error("注释中的行被当作了压缩包属性")
+	}
+	if len(entries) != 3 {
+		t.Fatalf("解析出 %d 个条目，应为 3 个", len(entries))
+	}
+	if entries[0]["CRC"] != "" || entries[1]["Path"] != "docs/a  b.txt" {
+		t.Errorf("条目属性为 %v", entries)
+	}
+
+	// 没有注释时不会把条目列表之前的空行当作注释
+	archive, _ = parseSlt(sltWindowsZip)
+	if _, ok := archive["Comment"]; ok {
+		t.Errorf("没有注释的压缩包解析出了注释 %q", archive["Comment"])
+	}
+}
+
+func TestParseEntries(t *testing.T) {
+	_, entries := parseEntries(slt7z)
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)
+	want := []Entry{
+		{Path: "docs", Size: 0, PackedSize: 0, Attributes: "D", IsDir: true, Modified: modified},
+		{Path: "docs/a  b.txt", Size: 12, PackedSize: 48, Attributes: "A", CRC: 0x3610A686, HasCRC: true,
+			Encrypted: true, Method: "LZMA2:12 7zAES:19", Modified: modified},
+		{Path: "docs/empty.txt", Size: 0, PackedSize: 0, Attributes: "A", Encrypted: true, Modified: modified.Add(time.Second)},
+	}
+	checkEntries(t, entries, want)
+}
+
+func TestParseEntriesWindows(t *testing.T) {
+	archive, entries := parseEntries(sltWindowsZip)
+	if archive["Path"] != `C:\test.zip` || archive["Type"] != "zip" {
+		t.Errorf("压缩包属性为 %v", archive)
+	}
+	// 7z 只在 Windows 上使用 \ 分隔路径，其他系统上 \ 是文件名中的普通字符
+	link := `dir\link`
+	if runtime.GOOS == "windows" {
+		link = "dir/link"
+	}
+	modified := time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local)
+	want := []Entry{
+		{Path: "dir", Size: 0, PackedSize: 0, Attributes: "D", IsDir: true, Method: "Store", Modified: modified},
+		{Path: link, Size: 7, PackedSize: 7, Attributes: "A -rwxrwxrwx", CRC: 0x0A1B2C3D, HasCRC: true,
+			Method: "Store", Modified: modified, Link: "../target"},
+	}
+	checkEntries(t, entries, want)
+}
+
+func TestParseEntriesUnknownSize(t *testing.T) {
+	_, entries := parseEntries("--\nPath = a.gz\nType = gzip\n\n----------\nPath = a\nSize = \nPacked Size = 20\nHard Link = b\n")
+	want := []Entry{{Path: "a", Size: -1, PackedSize: 20, Link: "b"}}
+	checkEntries(t, entries, want)
+}
+
+func checkEntries(t *testing.T, got, want []Entry) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("解析出 %d 个条目，应为 %d 个: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !got[i].Modified.Equal(want[i].Modified) {
+			t.Errorf("%s: Modified = %v，应为 %v", want[i].Path, got[i].Modified, want[i].Modified)
+		}
+		got[i].Modified, want[i].Modified = time.Time{}, time.Time{}
+		if got[i] != want[i] {
+			t.Errorf("第 %d 个条目为 %+v\n应为 %+v", i, got[i], want[i])
+		}
+	}
+}
+
+// 注释只有一行且之后紧接其他属性时，后面的属性不会被接到注释中
+func TestParseSltCommentFollowedByProperty(t *testing.T) {
+	archive, _ := parseSlt(strings.Join([]string{"--", "Path = a.rar", "Comment = pwd: x", "Physical Size = 10", "", "----------"}, "\n"))
+	if archive["Comment"] != "pwd: x" || archive["Physical Size"] != "10" {
+		t.Errorf("压缩包属性为 %v", archive)
+	}
+}
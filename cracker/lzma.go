@@ -0,0 +1,598 @@
+package cracker
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// --- LZMA / LZMA2 解码器 ---
+//
+// 7z 的文件头和数据通常经过 LZMA 或 LZMA2 压缩后再加密。
+// 为了在进程内判断密码是否正确，需要把解密后的数据解压出来校验 CRC，
+// 或者至少确认它是一个结构合法的压缩流。这里只实现校验所需的部分：
+// 解压大小总是已知的，并且可以只解压开头的一部分。
+
+var errLZMACorrupt = errors.New("LZMA 数据损坏")
+
+const (
+	lzmaNumBitModelTotalBits = 11
+	lzmaBitModelTotal        = 1 << lzmaNumBitModelTotalBits
+	lzmaNumMoveBits          = 5
+	lzmaTopValue             = 1 << 24
+	lzmaProbInit             = lzmaBitModelTotal / 2
+
+	lzmaNumStates         = 12
+	lzmaNumPosBitsMax     = 4
+	lzmaNumLenToPosStates = 4
+	lzmaNumAlignBits      = 4
+	lzmaEndPosModelIndex  = 14
+	lzmaNumFullDistances  = 1 << (lzmaEndPosModelIndex >> 1)
+	lzmaMatchMinLen       = 2
+	lzmaMaxLitStates      = 1 << 4 // LZMA2 限制 lc+lp <= 4
+)
+
+// rangeDecoder 是 LZMA 的区间解码器
+type rangeDecoder struct {
+	r    io.ByteReader
+	rng  uint32
+	code uint32
+	read int64 // 已读取的字节数，LZMA2 用它核对分块的压缩大小
+	err  error
+}
+
+func (rc *rangeDecoder) readByte() byte {
+	b, err := rc.r.ReadByte()
+	if err != nil {
+		if rc.err == nil {
+			rc.err = err
+		}
+		return 0
+	}
+	rc.read++
+	return b
+}
+
+func (rc *rangeDecoder) init() error {
+	rc.rng = 0xFFFFFFFF
+	rc.code = 0
+	// 区间编码器输出的第一个字节总是 0
+	if rc.readByte() != 0 {
+		return errLZMACorrupt
+	}
+	for i := 0; i < 4; i++ {
+		rc.code = rc.code<<8 | uint32(rc.readByte())
+	}
+	if rc.err != nil {
+		return rc.err
+	}
+	if rc.code == rc.rng {
+		return errLZMACorrupt
+	}
+	return nil
+}
+
+func (rc *rangeDecoder) finishedOK() bool {
+	return rc.code == 0
+}
+
+func (rc *rangeDecoder) normalize() {
+	if rc.rng < lzmaTopValue {
+		rc.rng <<= 8
+		rc.code = rc.code<<8 | uint32(rc.readByte())
+	}
+}
+
+func (rc *rangeDecoder) decodeBit(p *uint16) uint32 {
+	bound := (rc.rng >> lzmaNumBitModelTotalBits) * uint32(*p)
+	var bit uint32
+	if rc.code < bound {
+		*p += (lzmaBitModelTotal - *p) >> lzmaNumMoveBits
+		rc.rng = bound
+	} else {
+		*p -= *p >> lzmaNumMoveBits
+		rc.code -= bound
+		rc.rng -= bound
+		bit = 1
+	}
+	rc.normalize()
+	return bit
+}
+
+func (rc *rangeDecoder) decodeDirectBits(numBits uint32) uint32 {
+	var res uint32
+	for ; numBits > 0; numBits-- {
+		rc.rng >>= 1
+		rc.code -= rc.rng
+		t := 0 - (rc.code >> 31)
+		rc.code += rc.rng & t
+		if rc.code == rc.rng {
+			rc.err = errLZMACorrupt
+		}
+		rc.normalize()
+		res = res<<1 + t + 1
+	}
+	return res
+}
+
+func bitTreeDecode(rc *rangeDecoder, probs []uint16, numBits uint32) uint32 {
+	m := uint32(1)
+	for i := uint32(0); i < numBits; i++ {
+		m = m<<1 + rc.decodeBit(&probs[m])
+	}
+	return m - 1<<numBits
+}
+
+func bitTreeReverseDecode(rc *rangeDecoder, probs []uint16, numBits uint32) uint32 {
+	m := uint32(1)
+	var symbol uint32
+	for i := uint32(0); i < numBits; i++ {
+		bit := rc.decodeBit(&probs[m])
+		m = m<<1 + bit
+		symbol |= bit << i
+	}
+	return symbol
+}
+
+func initProbs(probs []uint16) {
+	for i := range probs {
+		probs[i] = lzmaProbInit
+	}
+}
+
+// lzmaLenDecoder 解码匹配长度
+type lzmaLenDecoder struct {
+	choice  uint16
+	choice2 uint16
+	low     [1 << lzmaNumPosBitsMax][1 << 3]uint16
+	mid     [1 << lzmaNumPosBitsMax][1 << 3]uint16
+	high    [1 << 8]uint16
+}
+
+func (ld *lzmaLenDecoder) init() {
+	ld.choice = lzmaProbInit
+	ld.choice2 = lzmaProbInit
+	initProbs(ld.high[:])
+	for i := range ld.low {
+		initProbs(ld.low[i][:])
+		initProbs(ld.mid[i][:])
+	}
+}
+
+func (ld *lzmaLenDecoder) decode(rc *rangeDecoder, posState uint32) uint32 {
+	if rc.decodeBit(&ld.choice) == 0 {
+		return bitTreeDecode(rc, ld.low[posState][:], 3)
+	}
+	if rc.decodeBit(&ld.choice2) == 0 {
+		return 8 + bitTreeDecode(rc, ld.mid[posState][:], 3)
+	}
+	return 16 + bitTreeDecode(rc, ld.high[:], 8)
+}
+
+// lzmaWindow 是解压使用的滑动窗口，写满一轮后把数据交给 out
+type lzmaWindow struct {
+	buf     []byte
+	pos     int
+	flushed int
+	total   int64 // 已输出的总字节数
+	base    int64 // 最近一次重置字典时的 total
+	out     io.Writer
+}
+
+func newLZMAWindow(size int, out io.Writer) *lzmaWindow {
+	return &lzmaWindow{buf: make([]byte, size), out: out}
+}
+
+// reset 重置字典，之后的匹配不能再引用重置前的数据
+func (w *lzmaWindow) reset() {
+	w.base = w.total
+}
+
+// processed 返回自上次重置字典以来输出的字节数
+func (w *lzmaWindow) processed() uint32 {
+	return uint32(w.total - w.base)
+}
+
+func (w *lzmaWindow) putByte(b byte) {
+	w.buf[w.pos] = b
+	w.pos++
+	w.total++
+	if w.pos == len(w.buf) {
+		w.flush()
+		w.pos = 0
+		w.flushed = 0
+	}
+}
+
+func (w *lzmaWindow) getByte(dist uint32) byte {
+	i := w.pos - int(dist)
+	if i < 0 {
+		i += len(w.buf)
+	}
+	return w.buf[i]
+}
+
+func (w *lzmaWindow) isEmpty() bool {
+	return w.total == w.base
+}
+
+// checkDistance 判断 dist+1 字节之前的数据是否仍在字典中
+func (w *lzmaWindow) checkDistance(dist uint32) bool {
+	return int64(dist) < w.total-w.base && int(dist) < len(w.buf)
+}
+
+// copyMatch 复制匹配的数据，输出达到 limit 字节后不再复制
+func (w *lzmaWindow) copyMatch(dist uint32, length int, limit int64) {
+	for ; length > 0 && w.total < limit; length-- {
+		w.putByte(w.getByte(dist))
+	}
+}
+
+func (w *lzmaWindow) flush() {
+	if w.pos > w.flushed && w.out != nil {
+		w.out.Write(w.buf[w.flushed:w.pos])
+	}
+	w.flushed = w.pos
+}
+
+// lzmaDecoder 保存 LZMA 解码状态，LZMA2 的多个分块之间会复用它
+type lzmaDecoder struct {
+	rc         rangeDecoder
+	win        *lzmaWindow
+	lc, lp, pb uint32
+	dictSize   uint32
+
+	literalProbs [0x300 * lzmaMaxLitStates]uint16
+	posSlot      [lzmaNumLenToPosStates][1 << 6]uint16
+	posDecoders  [1 + lzmaNumFullDistances - lzmaEndPosModelIndex]uint16
+	align        [1 << lzmaNumAlignBits]uint16
+	isMatch      [lzmaNumStates << lzmaNumPosBitsMax]uint16
+	isRep        [lzmaNumStates]uint16
+	isRepG0      [lzmaNumStates]uint16
+	isRepG1      [lzmaNumStates]uint16
+	isRepG2      [lzmaNumStates]uint16
+	isRep0Long   [lzmaNumStates << lzmaNumPosBitsMax]uint16
+	lenDecoder   lzmaLenDecoder
+	repLenDec    lzmaLenDecoder
+
+	state uint32
+	rep   [4]uint32
+}
+
+// setProps 解析 lc/lp/pb 组合字节
+func (d *lzmaDecoder) setProps(b byte) error {
+	if b >= 9*5*5 {
+		return errLZMACorrupt
+	}
+	d.lc = uint32(b % 9)
+	b /= 9
+	d.lp = uint32(b % 5)
+	d.pb = uint32(b / 5)
+	if 0x300<<(d.lc+d.lp) > len(d.literalProbs) {
+		return errors.New("不支持的 LZMA 参数")
+	}
+	return nil
+}
+
+// resetState 重置概率模型和状态变量
+func (d *lzmaDecoder) resetState() {
+	initProbs(d.literalProbs[:0x300<<(d.lc+d.lp)])
+	for i := range d.posSlot {
+		initProbs(d.posSlot[i][:])
+	}
+	initProbs(d.posDecoders[:])
+	initProbs(d.align[:])
+	initProbs(d.isMatch[:])
+	initProbs(d.isRep[:])
+	initProbs(d.isRepG0[:])
+	initProbs(d.isRepG1[:])
+	initProbs(d.isRepG2[:])
+	initProbs(d.isRep0Long[:])
+	d.lenDecoder.init()
+	d.repLenDec.init()
+	d.state = 0
+	d.rep = [4]uint32{}
+}
+
+func (d *lzmaDecoder) decodeLiteral() {
+	var prevByte uint32
+	if !d.win.isEmpty() {
+		prevByte = uint32(d.win.getByte(1))
+	}
+	litState := ((d.win.processed() & (1<<d.lp - 1)) << d.lc) + prevByte>>(8-d.lc)
+	probs := d.literalProbs[0x300*litState : 0x300*(litState+1)]
+
+	symbol := uint32(1)
+	if d.state >= 7 {
+		matchByte := uint32(d.win.getByte(d.rep[0] + 1))
+		for symbol < 0x100 {
+			matchBit := (matchByte >> 7) & 1
+			matchByte <<= 1
+			bit := d.rc.decodeBit(&probs[((1+matchBit)<<8)+symbol])
+			symbol = symbol<<1 | bit
+			if matchBit != bit {
+				break
+			}
+		}
+	}
+	for symbol < 0x100 {
+		symbol = symbol<<1 | d.rc.decodeBit(&probs[symbol])
+	}
+	d.win.putByte(byte(symbol - 0x100))
+}
+
+func (d *lzmaDecoder) decodeDistance(length uint32) uint32 {
+	lenState := min(length, lzmaNumLenToPosStates-1)
+	posSlot := bitTreeDecode(&d.rc, d.posSlot[lenState][:], 6)
+	if posSlot < 4 {
+		return posSlot
+	}
+	numDirectBits := (posSlot >> 1) - 1
+	dist := (2 | posSlot&1) << numDirectBits
+	if posSlot < lzmaEndPosModelIndex {
+		dist += bitTreeReverseDecode(&d.rc, d.posDecoders[dist-posSlot:], numDirectBits)
+	} else {
+		dist += d.rc.decodeDirectBits(numDirectBits-lzmaNumAlignBits) << lzmaNumAlignBits
+		dist += bitTreeReverseDecode(&d.rc, d.align[:], lzmaNumAlignBits)
+	}
+	return dist
+}
+
+// decode 解压 unpackSize 字节，输出达到 limit 字节后提前返回。
+// allowEndMarker 为 true 时允许流以结束标记提前结束 (仅 LZMA1)。
+func (d *lzmaDecoder) decode(unpackSize, limit int64, allowEndMarker bool) error {
+	for {
+		if d.rc.err != nil {
+			return d.rc.err
+		}
+		if unpackSize == 0 {
+			return nil
+		}
+		if d.win.total >= limit {
+			return nil
+		}
+
+		posState := d.win.processed() & (1<<d.pb - 1)
+		if d.rc.decodeBit(&d.isMatch[d.state<<lzmaNumPosBitsMax+posState]) == 0 {
+			d.decodeLiteral()
+			switch {
+			case d.state < 4:
+				d.state = 0
+			case d.state < 10:
+				d.state -= 3
+			default:
+				d.state -= 6
+			}
+			unpackSize--
+			continue
+		}
+
+		var length uint32
+		if d.rc.decodeBit(&d.isRep[d.state]) != 0 {
+			if d.win.isEmpty() {
+				return errLZMACorrupt
+			}
+			if d.rc.decodeBit(&d.isRepG0[d.state]) == 0 {
+				if d.rc.decodeBit(&d.isRep0Long[d.state<<lzmaNumPosBitsMax+posState]) == 0 {
+					if d.state < 7 {
+						d.state = 9
+					} else {
+						d.state = 11
+					}
+					d.win.putByte(d.win.getByte(d.rep[0] + 1))
+					unpackSize--
+					continue
+				}
+			} else {
+				var dist uint32
+				if d.rc.decodeBit(&d.isRepG1[d.state]) == 0 {
+					dist = d.rep[1]
+				} else {
+					if d.rc.decodeBit(&d.isRepG2[d.state]) == 0 {
+						dist = d.rep[2]
+					} else {
+						dist = d.rep[3]
+						d.rep[3] = d.rep[2]
+					}
+					d.rep[2] = d.rep[1]
+				}
+				d.rep[1] = d.rep[0]
+				d.rep[0] = dist
+			}
+			length = d.repLenDec.decode(&d.rc, posState)
+			if d.state < 7 {
+				d.state = 8
+			} else {
+				d.state = 11
+			}
+		} else {
+			d.rep[3], d.rep[2], d.rep[1] = d.rep[2], d.rep[1], d.rep[0]
+			length = d.lenDecoder.decode(&d.rc, posState)
+			if d.state < 7 {
+				d.state = 7
+			} else {
+				d.state = 10
+			}
+			d.rep[0] = d.decodeDistance(length)
+			if d.rep[0] == 0xFFFFFFFF {
+				// 结束标记
+				if allowEndMarker && d.rc.finishedOK() {
+					return nil
+				}
+				return errLZMACorrupt
+			}
+			if d.rep[0] >= d.dictSize || !d.win.checkDistance(d.rep[0]) {
+				return errLZMACorrupt
+			}
+		}
+
+		n := int64(length + lzmaMatchMinLen)
+		if n > unpackSize {
+			return errLZMACorrupt
+		}
+		d.win.copyMatch(d.rep[0]+1, int(n), limit)
+		unpackSize -= n
+	}
+}
+
+// lzmaWindowSize 计算窗口大小：只解压前 limit 字节时，不需要分配整个字典
+func lzmaWindowSize(dictSize uint32, limit int64) int {
+	size := int64(dictSize)
+	if limit+4096 < size {
+		size = limit + 4096
+	}
+	return int(max(size, 4096))
+}
+
+// decodeLZMA 解压 7z 中的 LZMA 流，props 为 5 字节的编码器属性
+func decodeLZMA(r io.ByteReader, props []byte, unpackSize int64, out io.Writer, limit int64) error {
+	if len(props) < 5 {
+		return errors.New("LZMA 属性长度错误")
+	}
+	d := &lzmaDecoder{dictSize: max(binary.LittleEndian.Uint32(props[1:5]), 4096)}
+	if err := d.setProps(props[0]); err != nil {
+		return err
+	}
+	d.win = newLZMAWindow(lzmaWindowSize(d.dictSize, min(limit, unpackSize)), out)
+	d.resetState()
+	d.rc.r = r
+	if err := d.rc.init(); err != nil {
+		return err
+	}
+	err := d.decode(unpackSize, limit, true)
+	d.win.flush()
+	return err
+}
+
+// decodeLZMA2 解压 7z 中的 LZMA2 流，prop 为 1 字节的字典大小属性
+func decodeLZMA2(r io.ByteReader, prop byte, unpackSize int64, out io.Writer, limit int64) error {
+	if prop > 40 {
+		return errors.New("LZMA2 属性错误")
+	}
+	dictSize := uint32(0xFFFFFFFF)
+	if prop < 40 {
+		dictSize = (2 | uint32(prop)&1) << (prop/2 + 11)
+	}
+
+	d := &lzmaDecoder{dictSize: dictSize}
+	d.win = newLZMAWindow(lzmaWindowSize(dictSize, min(limit, unpackSize)), out)
+	d.rc.r = r
+	defer d.win.flush()
+
+	readByte := func() (byte, error) {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return b, err
+	}
+	readUint16 := func() (int64, error) {
+		hi, err := readByte()
+		if err != nil {
+			return 0, err
+		}
+		lo, err := readByte()
+		return int64(hi)<<8 | int64(lo), err
+	}
+
+	first := true
+	needProps := true
+	for d.win.total < limit {
+		control, err := readByte()
+		if err != nil {
+			return err
+		}
+		if control == 0x00 {
+			if d.win.total != unpackSize {
+				return errLZMACorrupt
+			}
+			return nil
+		}
+		// 第一个分块必须重置字典
+		if first && control != 0x01 && control < 0xE0 {
+			return errLZMACorrupt
+		}
+		first = false
+
+		if control < 0x80 {
+			if control > 0x02 {
+				return errLZMACorrupt
+			}
+			if control == 0x01 {
+				d.win.reset()
+			}
+			size, err := readUint16()
+			if err != nil {
+				return err
+			}
+			size++
+			if d.win.total+size > unpackSize {
+				return errLZMACorrupt
+			}
+			// 达到解压上限后不再读取，外层循环随即结束
+			for ; size > 0 && d.win.total < limit; size-- {
+				b, err := readByte()
+				if err != nil {
+					return err
+				}
+				d.win.putByte(b)
+			}
+			continue
+		}
+
+		size, err := readUint16()
+		if err != nil {
+			return err
+		}
+		size += int64(control&0x1F)<<16 + 1
+		packSize, err := readUint16()
+		if err != nil {
+			return err
+		}
+		packSize++
+		if d.win.total+size > unpackSize {
+			return errLZMACorrupt
+		}
+
+		reset := (control >> 5) & 0x03
+		if reset == 3 {
+			d.win.reset()
+		}
+		if reset >= 2 {
+			props, err := readByte()
+			if err != nil {
+				return err
+			}
+			if err := d.setProps(props); err != nil {
+				return err
+			}
+			if d.lc+d.lp > 4 {
+				return errLZMACorrupt
+			}
+			needProps = false
+		} else if needProps {
+			return errLZMACorrupt
+		}
+		if reset >= 1 {
+			d.resetState()
+		}
+
+		d.rc.read = 0
+		if err := d.rc.init(); err != nil {
+			return err
+		}
+		chunkEnd := d.win.total + size
+		if err := d.decode(size, limit, false); err != nil {
+			return err
+		}
+		if d.win.total < chunkEnd {
+			// 达到解压上限，提前结束
+			return nil
+		}
+		// 分块解压完成后，区间解码器必须恰好读完声明的压缩大小
+		if !d.rc.finishedOK() || d.rc.read != packSize {
+			return errLZMACorrupt
+		}
+	}
+	return nil
+}
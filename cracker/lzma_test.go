@@ -0,0 +1,104 @@
+package cracker
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// lzmaTestData 返回 testdata 中 LZMA 流的原始数据：两段文本中间夹着 1000 字节不可压缩的数据。
+// lzma.bin 和 lzma2.bin 由 Python 的 lzma 模块 (liblzma) 压缩生成，字典大小 64KB，不含 7z 的属性字节；
+// lzma2.bin 由两段文本各自的 LZMA2 流拼接而成，中间的数据保存为不压缩的分块
+func lzmaTestData() []byte {
+	var buf bytes.Buffer
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&buf, "%05d the quick brown fox jumps over the lazy dog %d\n", i, i*i%97)
+	}
+	var random bytes.Buffer
+	for i := uint32(0); random.Len() < 1000; i++ {
+		sum := sha256.Sum256(binary.LittleEndian.AppendUint32([]byte("ArchiveTools"), i))
+		random.Write(sum[:])
+	}
+	buf.Write(random.Bytes()[:1000])
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&buf, "%05d the quick brown fox jumps over the lazy dog %d\n", i, i*i%13)
+	}
+	return buf.Bytes()
+}
+
+// lzmaTextSize 是 lzmaTestData 中第一段文本的长度，之后是不压缩的分块
+const lzmaTextSize = 52843
+
+// testDecodeLimits 用不同的解压上限解压同一个流，输出必须恰好是原始数据的前 limit 字节
+func testDecodeLimits(t *testing.T, stream []byte, decode func(r io.ByteReader, unpackSize int64, out io.Writer, limit int64) error) {
+	plain := lzmaTestData()
+	size := int64(len(plain))
+	limits := []int64{0, 1, 4000, lzmaTextSize, lzmaTextSize + 500, 100000, size - 1, size, size + 1000}
+	for _, limit := range limits {
+		t.Run(fmt.Sprint(limit), func(t *testing.T) {
+			var out bytes.Buffer
+			if err := decode(bytes.NewReader(stream), size, &out, limit); err != nil {
+				t.Fatalf("解压失败: %v", err)
+			}
+			want := plain[:min(limit, size)]
+			if out.Len() != len(want) {
+				t.Fatalf("输出 %d 字节，应为 %d 字节", out.Len(), len(want))
+			}
+			if !bytes.Equal(out.Bytes(), want) {
+				t.Fatal("输出与原始数据不一致")
+			}
+		})
+	}
+}
+
+func TestDecodeLZMA(t *testing.T) {
+	props := []byte{0x5D, 0, 0, 1, 0} // lc=3 lp=0 pb=2，字典 64KB
+	testDecodeLimits(t, readTestData(t, "lzma.bin"), func(r io.ByteReader, unpackSize int64, out io.Writer, limit int64) error {
+		return decodeLZMA(r, props, unpackSize, out, limit)
+	})
+}
+
+func TestDecodeLZMA2(t *testing.T) {
+	const prop = 8 // 字典 64KB
+	testDecodeLimits(t, readTestData(t, "lzma2.bin"), func(r io.ByteReader, unpackSize int64, out io.Writer, limit int64) error {
+		return decodeLZMA2(r, prop, unpackSize, out, limit)
+	})
+}
+
+func TestDecodeLZMACorrupt(t *testing.T) {
+	size := int64(len(lzmaTestData()))
+	lzma1 := readTestData(t, "lzma.bin")
+	lzma2 := readTestData(t, "lzma2.bin")
+
+	tests := []struct {
+		name   string
+		decode func() error
+	}{
+		{"LZMA 截断", func() error {
+			return decodeLZMA(bytes.NewReader(lzma1[:len(lzma1)/2]), []byte{0x5D, 0, 0, 1, 0}, size, io.Discard, size)
+		}},
+		{"LZMA 属性错误", func() error {
+			return decodeLZMA(bytes.NewReader(lzma1), []byte{0xFF, 0, 0, 1, 0}, size, io.Discard, size)
+		}},
+		{"LZMA2 截断", func() error {
+			return decodeLZMA2(bytes.NewReader(lzma2[:len(lzma2)/2]), 8, size, io.Discard, size)
+		}},
+		{"LZMA2 解压大小不符", func() error {
+			return decodeLZMA2(bytes.NewReader(lzma2), 8, size+1, io.Discard, size+1)
+		}},
+		{"LZMA2 第一个分块没有重置字典", func() error {
+			stream := append([]byte{0x02, 0x00, 0x00, 'x'}, lzma2...)
+			return decodeLZMA2(bytes.NewReader(stream), 8, size+1, io.Discard, size+1)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.decode(); err == nil {
+				t.Fatal("损坏的数据没有返回错误")
+			}
+		})
+	}
+}
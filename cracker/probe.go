@@ -0,0 +1,145 @@
+package cracker
+
+import (
+	"ArchiveTools/format"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"strings"
+)
+
+// Encryption 描述压缩包的加密方式，由 Probe 探测得到
+type Encryption int
+
+const (
+	// EncryptionNone 没有任何加密内容，无需密码
+	EncryptionNone Encryption = iota
+	// EncryptionEntries 文件内容加密，但不需要密码就能列出文件
+	EncryptionEntries
+	// EncryptionHeaders 文件头也被加密，不输入密码无法列出文件
+	EncryptionHeaders
+)
+
+// Probe 运行一次 7z l -slt 判断加密方式，快速模式下同时挑选体积最小的加密条目
+func (c *commandCracker) Probe(ctx context.Context) (Encryption, error) {
+	if !c.format.Encryptable {
+		// 不支持密码的格式不需要探测
+		c.encryption = EncryptionNone
+		return c.encryption, nil
+	}
+
+	// 用一个随机密码列出文件，避免 7z 在文件头加密时等待输入密码
+	wrong := probePassword()
+	output, err := c.run(ctx, append([]string{"l", "-slt", "-sccUTF-8", "-p" + wrong}, c.target()...)...)
+	if err != nil {
+		if c.wrongPassword(err, output) {
+			c.encryption = EncryptionHeaders
+			return c.encryption, nil
+		}
+		return EncryptionNone, fmt.Errorf("无法列出文件: %w\n--- 7z 输出 ---\n%s", err, string(output))
+	}
+
+	_, entries := parseEntries(string(output))
+	entry, encrypted := smallestEncryptedEntry(entries)
+	if !encrypted {
+		c.encryption = EncryptionNone
+		return c.encryption, nil
+	}
+	c.encryption = EncryptionEntries
+
+	if c.mode == QuickMode && entry != "" {
+		// 先用错误的密码测试一次，确认 7z 能按名称找到这个条目；
+		// 找不到时 7z 会直接报告成功，这种情况下退回测试整个压缩包
+		if ok, err := c.test(ctx, wrong, entry); err == nil && !ok {
+			c.probeEntry = entry
+		}
+	}
+	return c.encryption, nil
+}
+
+// probePassword 生成一个几乎不可能正确的随机密码
+func probePassword() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return "probe-" + hex.EncodeToString(buf)
+}
+
+// isWrongPasswordOutput 判断 7z 的输出是否表示文件头需要密码
+func isWrongPasswordOutput(output string) bool {
+	return strings.Contains(output, "Wrong password") || strings.Contains(output, "encrypted archive")
+}
+
+// wrongPassword 判断 7z 列出文件失败是否因为文件头加密且密码错误
+// 本地化的 7z 可能不输出英文提示，此时根据退出码 2 (致命错误) 和 ERROR: 行，再读取压缩包确认文件头确实加密
+func (c *commandCracker) wrongPassword(err error, output []byte) bool {
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return false
+	}
+	if isWrongPasswordOutput(string(output)) {
+		return true
+	}
+	return exitErr.ExitCode() == 2 && hasErrorLine(string(output)) && c.headersEncrypted()
+}
+
+// hasErrorLine 判断 7z 的输出中是否有 ERROR: 开头的行，这一前缀不随界面语言变化
+func hasErrorLine(output string) bool {
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "ERROR:") {
+			return true
+		}
+	}
+	return false
+}
+
+// headersEncrypted 直接读取压缩包判断文件头是否加密，只支持 7z 和 RAR
+func (c *commandCracker) headersEncrypted() bool {
+	if c.format != format.SevenZip && c.format != format.RAR {
+		return false
+	}
+	m, err := openMultiFile(c.volumes)
+	if err != nil {
+		return false
+	}
+	defer m.Close()
+	f := io.NewSectionReader(m, c.offset, m.size-c.offset)
+
+	if c.format == format.RAR {
+		return rarHeadersEncrypted(f)
+	}
+	header, err := readSevenZipNextHeader(f)
+	if err != nil || header == nil || header[0] != szIDEncodedHeader {
+		return false
+	}
+	r := &szReader{buf: header, pos: 1}
+	si := r.readStreamsInfo()
+	return r.err == nil && len(si.folders) > 0 && si.folders[0].isEncrypted()
+}
+
+// smallestEncryptedEntry 挑选体积最小的非空加密文件
+// 第二个返回值表示是否存在加密条目，只有空的加密文件时返回的路径为空
+func smallestEncryptedEntry(entries []Entry) (string, bool) {
+	var best string
+	var bestSize int64 = math.MaxInt64
+	encrypted := false
+	for _, e := range entries {
+		if !e.Encrypted || e.IsDir {
+			continue
+		}
+		encrypted = true
+		size := e.Size
+		if size < 0 {
+			// 大小未知的条目排在最后
+			size = math.MaxInt64 - 1
+		}
+		// 空文件的校验几乎没有区分度
+		if size > 0 && size < bestSize {
+			best, bestSize = e.Path, size
+		}
+	}
+	return best, encrypted
+}
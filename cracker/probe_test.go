@@ -0,0 +1,226 @@
+package cracker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// 按 7-Zip 23.01 用错误的密码列出文件头加密的 7z 时的输出格式整理
+const probeHeaders7z = `
+7-Zip 23.01 (x64) : Copyright (c) 1999-2023 Igor Pavlov : 2023-06-20
+ 64-bit locale=zh_CN.UTF-8 Threads:8 OPEN_MAX:1024
+
+Scanning the drive for archives:
+1 file, 264 bytes (1 KiB)
+
+Listing archive: secret.7z
+
+ERROR: secret.7z : Cannot open encrypted archive. Wrong password?
+
+ERRORS:
+Cannot open encrypted archive. Wrong password?
+`
+
+// 按 p7zip 16.02 的输出格式整理，旧版本的提示为 "Can not open encrypted archive"
+const probeHeadersP7zip = `
+7-Zip [64] 16.02 : Copyright (c) 1999-2016 Igor Pavlov : 2016-05-21
+p7zip Version 16.02 (locale=en_US.UTF-8,Utf16=on,HugeFiles=on,64 bits,4 CPUs)
+
+Scanning the drive for archives:
+1 file, 1088 bytes (2 KiB)
+
+Listing archive: secret.rar
+
+ERROR: secret.rar : Can not open encrypted archive. Wrong password?
+
+ERRORS:
+Can not open encrypted archive. Wrong password?
+`
+
+// 按 7-Zip 23.01 打开损坏的压缩包时的输出格式整理
+const probeCorrupt = `
+7-Zip 23.01 (x64) : Copyright (c) 1999-2023 Igor Pavlov : 2023-06-20
+
+Scanning the drive for archives:
+1 file, 512 bytes (1 KiB)
+
+Listing archive: broken.7z
+
+ERROR: broken.7z : Cannot open the file as archive
+
+Errors: 1
+`
+
+func TestIsWrongPasswordOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   bool
+	}{
+		{"7-Zip 文件头加密", probeHeaders7z, true},
+		{"p7zip 文件头加密", probeHeadersP7zip, true},
+		{"压缩包损坏", probeCorrupt, false},
+		{"文件不存在", "ERROR: The system cannot find the file specified.\nmissing.7z\n", false},
+		{"正常列出", slt7z, false},
+	}
+	for _, tt := range tests {
+		if got := isWrongPasswordOutput(tt.output); got != tt.want {
+			t.Errorf("%s: isWrongPasswordOutput() = %v，应为 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSmallestEncryptedEntry(t *testing.T) {
+	tests := []struct {
+		name      string
+		entries   []Entry
+		path      string
+		encrypted bool
+	}{
+		{"没有条目", nil, "", false},
+		{"没有加密", []Entry{{Path: "a", Size: 1}}, "", false},
+		{"选择最小的加密文件", []Entry{
+			{Path: "big", Size: 100, Encrypted: true},
+			{Path: "plain", Size: 1},
+			{Path: "small", Size: 10, Encrypted: true},
+		}, "small", true},
+		{"跳过文件夹", []Entry{
+			{Path: "dir", IsDir: true, Size: 1, Encrypted: true},
+			{Path: "dir/file", Size: 50, Encrypted: true},
+		}, "dir/file", true},
+		{"跳过空文件", []Entry{
+			{Path: "empty", Size: 0, Encrypted: true},
+			{Path: "file", Size: 50, Encrypted: true},
+		}, "file", true},
+		{"大小未知的排在最后", []Entry{
+			{Path: "unknown", Size: -1, Encrypted: true},
+			{Path: "known", Size: 1 << 40, Encrypted: true},
+		}, "known", true},
+		{"只有大小未知的", []Entry{{Path: "unknown", Size: -1, Encrypted: true}}, "unknown", true},
+		{"只有空的加密文件", []Entry{
+			{Path: "empty", Size: 0, Encrypted: true},
+			{Path: "dir", IsDir: true, Encrypted: true},
+		}, "", true},
+		{"只有加密的文件夹", []Entry{{Path: "dir", IsDir: true, Encrypted: true}}, "", false},
+	}
+	for _, tt := range tests {
+		path, encrypted := smallestEncryptedEntry(tt.entries)
+		if path != tt.path || encrypted != tt.encrypted {
+			t.Errorf("%s: smallestEncryptedEntry() = %q, %v，应为 %q, %v", tt.name, path, encrypted, tt.path, tt.encrypted)
+		}
+	}
+}
+
+// 7z 用随机密码列出文件失败并提示密码错误时，判断为文件头加密
+// 模拟界面语言为中文的 7z 在文件头加密时的输出，只有 ERROR: 前缀和退出码不随语言变化
+const probeHeadersLocalized = `
+7-Zip 23.01 (x64) : Copyright (c) 1999-2023 Igor Pavlov : 2023-06-20
+
+正在扫描磁盘中的压缩包:
+1 个文件, 264 字节 (1 KiB)
+
+正在列出压缩包: secret.7z
+
+ERROR: secret.7z : 无法打开加密的压缩包。密码错误？
+
+错误:
+无法打开加密的压缩包。密码错误？
+`
+
+// rar4HeadersPath 写入一个主块头带有密码标志的 RAR4 压缩包
+func rar4HeadersPath(t *testing.T) string {
+	t.Helper()
+	main := []byte{0, 0, rar4HeaderMain, byte(rar4MainPassword), 0, 13, 0, 0, 0, 0, 0, 0, 0}
+	path := filepath.Join(t.TempDir(), "secret.rar")
+	if err := os.WriteFile(path, append(append([]byte(nil), rar4Signature...), main...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestProbeHeaders(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("使用 shell 脚本代替 7z")
+	}
+	testdata := func(name string) func(t *testing.T) string {
+		return func(t *testing.T) string { return filepath.Join("testdata", name) }
+	}
+	rar5 := func(t *testing.T) string {
+		b := newRar5Builder()
+		b.block(rar5HeaderCrypt, rar5CryptRecord(false, rar5TestCheck), nil, 0)
+		return b.save(t)
+	}
+	tests := []struct {
+		name    string
+		archive func(t *testing.T) string
+		output  string
+		wantErr bool
+	}{
+		{"7-Zip", testdata("aes_header.7z"), probeHeaders7z, false},
+		{"p7zip", testdata("aes_header.7z"), probeHeadersP7zip, false},
+		{"中文输出", testdata("aes_header.7z"), probeHeadersLocalized, false},
+		{"中文输出 RAR5", rar5, probeHeadersLocalized, false},
+		{"中文输出 RAR4", rar4HeadersPath, probeHeadersLocalized, false},
+		{"中文输出但文件头没有加密", testdata("aes_data.7z"), probeHeadersLocalized, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newCommandCracker(testArchive(t, tt.archive(t)), QuickMode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cc := c.(*commandCracker)
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "output"), []byte(tt.output), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cc.command = filepath.Join(dir, "7z")
+			script := "#!/bin/sh\ncat " + filepath.Join(dir, "output") + "\nexit 2\n"
+			if err := os.WriteFile(cc.command, []byte(script), 0755); err != nil {
+				t.Fatal(err)
+			}
+			enc, err := cc.Probe(context.Background())
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Probe() = %v，应当报错", enc)
+				}
+				return
+			}
+			if err != nil || enc != EncryptionHeaders {
+				t.Errorf("Probe() = %v, %v，应为 EncryptionHeaders", enc, err)
+			}
+			if _, err := cc.ListEntries(context.Background(), "wrong"); err != ErrWrongPassword {
+				t.Errorf("ListEntries() 错误为 %v，应为 ErrWrongPassword", err)
+			}
+		})
+	}
+}
+
+func TestMessageEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  []string
+		want []string
+	}{
+		{"没有语言设置", []string{"PATH=/bin"}, []string{"PATH=/bin", "LC_MESSAGES=C"}},
+		{
+			"替换提示语言",
+			[]string{"LANG=zh_CN.UTF-8", "LANGUAGE=zh_CN", "LC_MESSAGES=zh_CN.UTF-8"},
+			[]string{"LANG=zh_CN.UTF-8", "LC_MESSAGES=C"},
+		},
+		{
+			"LC_ALL 改为 LC_CTYPE",
+			[]string{"LC_ALL=zh_CN.GB18030", "LC_CTYPE=en_US.UTF-8", "HOME=/root"},
+			[]string{"HOME=/root", "LC_CTYPE=zh_CN.GB18030", "LC_MESSAGES=C"},
+		},
+	}
+	for _, tt := range tests {
+		if got := messageEnv(tt.env); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: messageEnv() = %q，应为 %q", tt.name, got, tt.want)
+		}
+	}
+}
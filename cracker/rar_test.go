@@ -0,0 +1,254 @@
+package cracker
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 来自 hashcat 的 RAR5 示例 (模式 13000)，由 WinRAR 创建，密码为 hashcat
+const (
+	rar5TestPassword = "hashcat"
+	rar5TestSalt     = "74575567518807622265582327032280"
+	rar5TestKDFCount = 15
+	rar5TestCheck    = "9843834ed0f7c754"
+)
+
+func TestRar5PasswordCheck(t *testing.T) {
+	salt, _ := hex.DecodeString(rar5TestSalt)
+	check := hex.EncodeToString(rar5PasswordCheck([]byte(rar5TestPassword), salt, rar5TestKDFCount))
+	if check != rar5TestCheck {
+		t.Errorf("rar5PasswordCheck() = %s，应为 %s", check, rar5TestCheck)
+	}
+	if check := hex.EncodeToString(rar5PasswordCheck([]byte("notpassword"), salt, rar5TestKDFCount)); check == rar5TestCheck {
+		t.Error("错误的密码得到了相同的校验值")
+	}
+}
+
+// rar5Builder 按 RAR 5.0 的格式说明拼出只含块头的压缩包，数据区用零填充
+type rar5Builder struct {
+	bytes.Buffer
+}
+
+func newRar5Builder() *rar5Builder {
+	b := &rar5Builder{}
+	b.Write(rar5Signature)
+	return b
+}
+
+func appendVint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(buf, byte(v))
+}
+
+// block 写入一个块头，fields 为类型相关的字段，extra 为扩展区的记录，dataSize 为数据区的长度
+func (b *rar5Builder) block(htype uint64, fields []byte, extra [][]byte, dataSize int) {
+	var extraArea []byte
+	for _, rec := range extra {
+		extraArea = append(appendVint(extraArea, uint64(len(rec))), rec...)
+	}
+	var flags uint64
+	if len(extraArea) > 0 {
+		flags |= rar5FlagExtra
+	}
+	if dataSize > 0 {
+		flags |= rar5FlagData
+	}
+
+	body := appendVint(appendVint(nil, htype), flags)
+	if len(extraArea) > 0 {
+		body = appendVint(body, uint64(len(extraArea)))
+	}
+	if dataSize > 0 {
+		body = appendVint(body, uint64(dataSize))
+	}
+	body = append(append(body, fields...), extraArea...)
+
+	header := append(appendVint(nil, uint64(len(body))), body...)
+	b.Write(binary.LittleEndian.AppendUint32(nil, crc32.ChecksumIEEE(header)))
+	b.Write(header)
+	b.Write(make([]byte, dataSize))
+}
+
+func (b *rar5Builder) main() {
+	b.block(1, appendVint(nil, 0), nil, 0)
+}
+
+// file 写入一个文件块，crypt 为加密记录，为 nil 时不加密
+func (b *rar5Builder) file(name string, crypt []byte) {
+	var fields []byte
+	for _, v := range []uint64{0, 100, 0x20, 0, 0} { // 文件标志、解压后大小、属性、压缩信息、主机系统
+		fields = appendVint(fields, v)
+	}
+	fields = append(appendVint(fields, uint64(len(name))), name...)
+	var extra [][]byte
+	if crypt != nil {
+		extra = append(extra, append([]byte{rar5ExtraCrypt}, crypt...))
+	}
+	b.block(rar5HeaderFile, fields, extra, 32)
+}
+
+func (b *rar5Builder) end() {
+	b.block(rar5HeaderEnd, appendVint(nil, 0), nil, 0)
+}
+
+func (b *rar5Builder) save(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.rar")
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// rar5CryptRecord 返回归档加密头或文件加密记录的内容，check 为空时不含校验值
+func rar5CryptRecord(hasIV bool, check string) []byte {
+	salt, _ := hex.DecodeString(rar5TestSalt)
+	var flags uint64
+	if check != "" {
+		flags = rar5CryptCheckPresent
+	}
+	rec := appendVint(appendVint(nil, 0), flags)
+	rec = append(append(rec, rar5TestKDFCount), salt...)
+	if hasIV {
+		rec = append(rec, make([]byte, rar5IVSize)...)
+	}
+	if check != "" {
+		value, _ := hex.DecodeString(check)
+		sum := sha256.Sum256(value)
+		rec = append(append(rec, value...), sum[:rar5CheckSumSize]...)
+	}
+	return rec
+}
+
+func TestRarCracker(t *testing.T) {
+	tests := []struct {
+		name       string
+		build      func(b *rar5Builder)
+		mode       Mode
+		encryption Encryption
+		fallback   bool
+	}{
+		{
+			name: "文件头加密",
+			build: func(b *rar5Builder) {
+				b.block(rar5HeaderCrypt, rar5CryptRecord(false, rar5TestCheck), nil, 0)
+				b.Write(bytes.Repeat([]byte{0x5A}, 64)) // 加密的块头
+			},
+			encryption: EncryptionHeaders,
+		},
+		{
+			name: "文件加密",
+			build: func(b *rar5Builder) {
+				b.main()
+				b.file("a.txt", rar5CryptRecord(true, rar5TestCheck))
+				b.file("b.txt", rar5CryptRecord(true, rar5TestCheck))
+				b.end()
+			},
+			mode:       AccurateMode,
+			encryption: EncryptionEntries,
+		},
+		{
+			name: "没有加密",
+			build: func(b *rar5Builder) {
+				b.main()
+				b.file("a.txt", nil)
+				b.end()
+			},
+			encryption: EncryptionNone,
+		},
+		{
+			name: "没有校验值",
+			build: func(b *rar5Builder) {
+				b.main()
+				b.file("a.txt", rar5CryptRecord(true, ""))
+				b.end()
+			},
+			fallback: true,
+		},
+		{
+			name: "校验值损坏",
+			build: func(b *rar5Builder) {
+				b.main()
+				rec := rar5CryptRecord(true, rar5TestCheck)
+				rec[len(rec)-1] ^= 0xFF
+				b.file("a.txt", rec)
+				b.end()
+			},
+			fallback: true,
+		},
+		{
+			name: "快速模式只需要一个校验值",
+			build: func(b *rar5Builder) {
+				b.main()
+				b.file("a.txt", rar5CryptRecord(true, rar5TestCheck))
+				b.file("b.txt", rar5CryptRecord(true, ""))
+				b.end()
+			},
+			mode:       QuickMode,
+			encryption: EncryptionEntries,
+		},
+		{
+			name: "精确模式需要全部校验值",
+			build: func(b *rar5Builder) {
+				b.main()
+				b.file("a.txt", rar5CryptRecord(true, rar5TestCheck))
+				b.file("b.txt", rar5CryptRecord(true, ""))
+				b.end()
+			},
+			mode:     AccurateMode,
+			fallback: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			b := newRar5Builder()
+			tt.build(b)
+			c, err := newRarCracker(testArchive(t, b.save(t)), tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := c.(*rarCracker)
+			no7z(t, r.commandCracker)
+			if r.fallback != tt.fallback {
+				t.Fatalf("fallback = %v，应为 %v", r.fallback, tt.fallback)
+			}
+			if tt.fallback {
+				return
+			}
+			if enc, err := r.Probe(ctx); err != nil || enc != tt.encryption {
+				t.Fatalf("Probe() = %v, %v，应为 %v", enc, err, tt.encryption)
+			}
+			encrypted := tt.encryption != EncryptionNone
+			for password, want := range map[string]bool{rar5TestPassword: true, "notpassword": !encrypted} {
+				ok, err := r.TryPassword(ctx, password)
+				if err != nil {
+					t.Fatalf("TryPassword(%q) 出错: %v", password, err)
+				}
+				if ok != want {
+					t.Errorf("TryPassword(%q) = %v，应为 %v", password, ok, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRarCrackerRar4(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.rar")
+	if err := os.WriteFile(path, append([]byte("Rar!\x1a\x07\x00"), make([]byte, 32)...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newRarCracker(testArchive(t, path), QuickMode); err == nil {
+		t.Error("RAR4 压缩包应当回退到 7z")
+	}
+}
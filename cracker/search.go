@@ -0,0 +1,160 @@
+package cracker
+
+import (
+	"ArchiveTools/candidate"
+	"context"
+	"math"
+	"sync"
+)
+
+// SearchOptions 控制在单个压缩包内查找密码的方式
+type SearchOptions struct {
+	// Concurrency 同时进行的密码尝试数量，小于 1 时按 1 处理
+	Concurrency int
+	// OnAttempt 在每个密码开始尝试前调用，可以为 nil
+	OnAttempt func(password string)
+	// Skip 跳过序列开头的若干个候选密码，用于从中断的位置继续，序号仍从序列开头计算
+	Skip int
+	// OnCheckpoint 在序号小于 next 的候选密码都已尝试且都不正确时调用，可以为 nil。
+	// 调用是串行的，从 next 开始重新查找不会漏掉密码
+	OnCheckpoint func(next int)
+}
+
+// SearchResult 保存一次密码查找的结果
+type SearchResult struct {
+	Found    bool
+	Password string
+	Index    int // 命中的密码在候选序列中的序号，未找到时为 -1
+	Tried    int // 完整尝试过的密码数量
+}
+
+// Search 依次从 candidates 中取出密码查找能打开压缩包的那个，最多同时进行 opts.Concurrency 个尝试。
+// 某个密码成功后不再派发新的尝试，并通过 context 取消序号更大的进行中尝试；
+// 序号更小的尝试会继续等待完成，因此当多个密码都可用时，总是返回序列中最靠前的那个。
+func Search(ctx context.Context, c Cracker, candidates candidate.Iterator, opts SearchOptions) (SearchResult, error) {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		mu            sync.Mutex
+		wg            sync.WaitGroup
+		found         = -1 // 已知成功的最小序号
+		foundPassword string
+		errIndex      = -1 // 已知出错的最小序号
+		firstErr      error
+		tried         int
+		cancels       = make(map[int]context.CancelFunc)
+		checkpoint    int              // 之前的候选密码都已确认不正确
+		failed        = map[int]bool{} // 已确认不正确、但序号不连续的候选密码
+	)
+
+	// stopAt 返回不再需要尝试的起始序号，调用方需持有锁
+	stopAt := func() int {
+		stop := math.MaxInt
+		if found >= 0 {
+			stop = min(stop, found+1)
+		}
+		if errIndex >= 0 {
+			stop = min(stop, errIndex+1)
+		}
+		return stop
+	}
+
+	if opts.Skip > 0 {
+		checkpoint = int(candidate.Skip(candidates, int64(opts.Skip)))
+	}
+
+	slots := make(chan struct{}, concurrency)
+	for i := checkpoint; ; i++ {
+		password, ok := candidates.Next()
+		if !ok {
+			break
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		mu.Lock()
+		if i >= stopAt() {
+			mu.Unlock()
+			<-slots
+			break
+		}
+		attemptCtx, cancel := context.WithCancel(ctx)
+		cancels[i] = cancel
+		mu.Unlock()
+
+		if opts.OnAttempt != nil {
+			opts.OnAttempt(password)
+		}
+
+		wg.Add(1)
+		go func(i int, password string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			ok, err := c.TryPassword(attemptCtx, password)
+
+			mu.Lock()
+			defer mu.Unlock()
+			// 被主动取消的尝试结果不可信，直接丢弃
+			canceled := attemptCtx.Err() != nil
+			cancels[i]()
+			delete(cancels, i)
+			if canceled {
+				return
+			}
+			tried++
+			switch {
+			case err != nil:
+				if errIndex < 0 || i < errIndex {
+					errIndex, firstErr = i, err
+				}
+			case ok:
+				if found < 0 || i < found {
+					found, foundPassword = i, password
+				}
+			default:
+				failed[i] = true
+				if i == checkpoint {
+					for failed[checkpoint] {
+						delete(failed, checkpoint)
+						checkpoint++
+					}
+					if opts.OnCheckpoint != nil {
+						opts.OnCheckpoint(checkpoint)
+					}
+				}
+				return
+			}
+			// 取消所有序号更大的尝试，它们的结果已经不会被采用
+			stop := stopAt()
+			for j, cancelAttempt := range cancels {
+				if j >= stop {
+					cancelAttempt()
+				}
+			}
+		}(i, password)
+	}
+	wg.Wait()
+
+	result := SearchResult{Index: -1, Tried: tried}
+	switch {
+	case found >= 0 && (errIndex < 0 || found < errIndex):
+		result.Found = true
+		result.Index = found
+		result.Password = foundPassword
+		return result, nil
+	case errIndex >= 0:
+		return result, firstErr
+	case ctx.Err() != nil:
+		return result, ctx.Err()
+	}
+	return result, nil
+}
@@ -0,0 +1,177 @@
+package cracker
+
+import (
+	"ArchiveTools/candidate"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCracker 只实现 TryPassword，记录每个完整尝试过的密码
+type fakeCracker struct {
+	Cracker
+	valid map[string]bool
+	fail  map[string]error
+	delay func(password string) time.Duration // 为 nil 时立即返回
+
+	mu    sync.Mutex
+	tried map[string]bool
+}
+
+func (c *fakeCracker) TryPassword(ctx context.Context, password string) (bool, error) {
+	if c.delay != nil {
+		select {
+		case <-time.After(c.delay(password)):
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tried == nil {
+		c.tried = make(map[string]bool)
+	}
+	c.tried[password] = true
+	return c.valid[password], c.fail[password]
+}
+
+func testPasswords(n int) candidate.List {
+	list := make(candidate.List, n)
+	for i := range list {
+		list[i] = fmt.Sprintf("p%d", i)
+	}
+	return list
+}
+
+// 多个密码都可用时，即使序号更大的先完成，也要返回序列中最靠前的那个
+func TestSearchEarliestMatch(t *testing.T) {
+	c := &fakeCracker{
+		valid: map[string]bool{"p10": true, "p11": true, "p20": true},
+		delay: func(password string) time.Duration {
+			if password == "p10" {
+				return 50 * time.Millisecond
+			}
+			return time.Millisecond
+		},
+	}
+	for _, concurrency := range []int{1, 4, 16} {
+		t.Run(fmt.Sprint(concurrency), func(t *testing.T) {
+			result, err := Search(context.Background(), c, testPasswords(100).Iter(), SearchOptions{Concurrency: concurrency})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Found || result.Password != "p10" || result.Index != 10 {
+				t.Errorf("Search() = %+v，应当找到 p10", result)
+			}
+		})
+	}
+}
+
+func TestSearchNotFound(t *testing.T) {
+	c := &fakeCracker{}
+	var checkpoints []int
+	result, err := Search(context.Background(), c, testPasswords(50).Iter(), SearchOptions{
+		Concurrency:  4,
+		OnCheckpoint: func(next int) { checkpoints = append(checkpoints, next) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Found || result.Index != -1 || result.Tried != 50 {
+		t.Errorf("Search() = %+v，应当尝试全部 50 个密码且没有找到", result)
+	}
+	if len(checkpoints) == 0 || checkpoints[len(checkpoints)-1] != 50 {
+		t.Errorf("最后的进度应为 50，实际: %v", checkpoints)
+	}
+}
+
+// 出错的密码之前有可用的密码时返回找到的密码，否则返回错误
+func TestSearchError(t *testing.T) {
+	errBroken := errors.New("broken")
+	tests := []struct {
+		name  string
+		valid map[string]bool
+		found bool
+	}{
+		{"之前有可用的密码", map[string]bool{"p3": true}, true},
+		{"之后才有可用的密码", map[string]bool{"p8": true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &fakeCracker{valid: tt.valid, fail: map[string]error{"p5": errBroken}}
+			result, err := Search(context.Background(), c, testPasswords(20).Iter(), SearchOptions{Concurrency: 4})
+			if tt.found {
+				if err != nil || !result.Found || result.Password != "p3" {
+					t.Errorf("Search() = %+v, %v，应当找到 p3", result, err)
+				}
+			} else if !errors.Is(err, errBroken) || result.Found {
+				t.Errorf("Search() = %+v, %v，应当返回错误", result, err)
+			}
+		})
+	}
+}
+
+// 中断后从记录的进度继续，两次合起来不能漏掉任何密码
+func TestSearchCancelAndResume(t *testing.T) {
+	const total, answer, cancelAt = 400, 300, 120
+	passwords := testPasswords(total)
+	c := &fakeCracker{
+		valid: map[string]bool{passwords[answer]: true},
+		delay: func(password string) time.Duration {
+			// 让完成的顺序与序号不一致，检查进度只在之前的密码全部确认后才前进
+			return time.Duration(len(password)%3) * time.Millisecond
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	saved := 0
+	result, err := Search(ctx, c, passwords.Iter(), SearchOptions{
+		Concurrency: 8,
+		OnAttempt: func(password string) {
+			if password == passwords[cancelAt] {
+				cancel()
+			}
+		},
+		OnCheckpoint: func(next int) {
+			if next <= saved {
+				t.Errorf("进度没有前进: %d -> %d", saved, next)
+			}
+			saved = next
+		},
+	})
+	if !errors.Is(err, context.Canceled) || result.Found {
+		t.Fatalf("取消后 Search() = %+v, %v，应当返回 context.Canceled", result, err)
+	}
+	if saved == 0 || saved > cancelAt {
+		t.Fatalf("记录的进度 %d 应在 1 到 %d 之间", saved, cancelAt)
+	}
+	for _, p := range passwords[:saved] {
+		if !c.tried[p] {
+			t.Fatalf("进度 %d 之前的 %s 没有尝试过", saved, p)
+		}
+	}
+
+	var first string
+	result, err = Search(context.Background(), c, passwords.Iter(), SearchOptions{
+		Concurrency: 8,
+		Skip:        saved,
+		OnAttempt: func(password string) {
+			if first == "" {
+				first = password
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != passwords[saved] {
+		t.Errorf("继续时第一个尝试的密码是 %s，应为 %s", first, passwords[saved])
+	}
+	if !result.Found || result.Index != answer || result.Password != passwords[answer] {
+		t.Errorf("继续后 Search() = %+v，应当在第 %d 个找到", result, answer)
+	}
+}
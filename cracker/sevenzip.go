@@ -0,0 +1,1087 @@
+package cracker
+
+import (
+	"ArchiveTools/utils"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"sort"
+	"unicode/utf16"
+)
+
+// --- 7z 原生破解器 ---
+//
+// 解析 7z 的签名头和 (编码后的) 文件头，按 7zAES 的参数派生 AES-256 密钥，
+// 然后解密文件头或第一个加密数据流来判断密码是否正确：
+//   - 文件头已加密：解密并解压整个文件头，校验其 CRC
+//   - 仅数据加密：解密并解压体积最小的加密文件夹中的第一个文件，校验其 CRC；
+//     没有可以校验 CRC 的文件夹时 (文件过大或没有 CRC)，只解压开头一部分排除大部分错误的密码，
+//     解出合法的 LZMA/LZMA2 流后再交给 7z 确认
+// 遇到无法在进程内处理的编码组合时，回退到 7z 命令。
+
+var sevenZipSignature = []byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C}
+
+// 7z 文件头中的属性 ID
+const (
+	szIDEnd                   = 0x00
+	szIDHeader                = 0x01
+	szIDArchiveProperties     = 0x02
+	szIDAdditionalStreamsInfo = 0x03
+	szIDMainStreamsInfo       = 0x04
+	szIDFilesInfo             = 0x05
+	szIDPackInfo              = 0x06
+	szIDUnpackInfo            = 0x07
+	szIDSubStreamsInfo        = 0x08
+	szIDSize                  = 0x09
+	szIDCRC                   = 0x0A
+	szIDFolder                = 0x0B
+	szIDCodersUnpackSize      = 0x0C
+	szIDNumUnpackStream       = 0x0D
+	szIDEncodedHeader         = 0x17
+)
+
+// 7z 编码器 ID
+const (
+	szMethodCopy  = "\x00"
+	szMethodLZMA  = "\x03\x01\x01"
+	szMethodLZMA2 = "\x21"
+	szMethodAES   = "\x06\xf1\x07\x01"
+)
+
+const (
+	szSignatureHeaderSize = 32
+	szMaxHeaderSize       = 64 << 20 // 文件头大小的合理上限，防止异常文件耗尽内存
+	szMaxCRCVerifySize    = 16 << 20 // 第一个文件不超过该大小时完整解压并校验 CRC
+	szStructureVerifySize = 1 << 20  // 否则只解压开头这么多字节检查压缩流结构，通过后交给 7z 确认
+)
+
+var errSZTruncated = errors.New("7z 文件头不完整")
+
+// errSZDecode 表示解密、解压出的数据不合法，通常是密码错误。
+// 读取压缩包本身出错、编码器属性不支持等与密码无关的错误不使用它
+var errSZDecode = errors.New("7z 数据解码失败")
+
+type szCoder struct {
+	method string
+	numIn  int
+	numOut int
+	props  []byte
+}
+
+type szBindPair struct {
+	in  int
+	out int
+}
+
+// szFolder 对应 7z 中的一个文件夹 (一组串联的编码器及其数据流)
+type szFolder struct {
+	coders        []szCoder
+	bindPairs     []szBindPair
+	packedStreams []int
+	unpackSizes   []uint64
+	crc           uint32
+	crcDefined    bool
+
+	packOffset int64 // 第一个打包流在文件中的绝对位置
+	packSize   int64
+
+	numSubstreams   int
+	firstSize       uint64 // 第一个文件的解压大小
+	firstCRC        uint32
+	firstCRCDefined bool
+}
+
+type szStreamsInfo struct {
+	packPos   uint64
+	packSizes []uint64
+	folders   []*szFolder
+}
+
+// szReader 顺序读取 7z 文件头，遇到错误后后续读取都返回零值，由调用方统一检查 err
+type szReader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *szReader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *szReader) readByte() byte {
+	if r.err != nil || r.pos >= len(r.buf) {
+		r.fail(errSZTruncated)
+		return 0
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *szReader) readBytes(n uint64) []byte {
+	if r.err != nil || n > uint64(len(r.buf)-r.pos) {
+		r.fail(errSZTruncated)
+		return nil
+	}
+	b := r.buf[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b
+}
+
+// readNumber 读取 7z 的变长整数
+func (r *szReader) readNumber() uint64 {
+	first := r.readByte()
+	mask := byte(0x80)
+	var value uint64
+	for i := 0; i < 8; i++ {
+		if first&mask == 0 {
+			high := uint64(first & (mask - 1))
+			return value | high<<(8*i)
+		}
+		value |= uint64(r.readByte()) << (8 * i)
+		mask >>= 1
+	}
+	return value
+}
+
+// readCount 读取一个数量，并确保它不会超过剩余数据所能容纳的范围
+func (r *szReader) readCount() int {
+	n := r.readNumber()
+	if n > uint64(len(r.buf)) {
+		r.fail(errors.New("7z 文件头中的数量异常"))
+		return 0
+	}
+	return int(n)
+}
+
+func (r *szReader) readUint32() uint32 {
+	b := r.readBytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (r *szReader) skipData() {
+	r.readBytes(r.readNumber())
+}
+
+func (r *szReader) readBoolVector(n int) []bool {
+	v := make([]bool, n)
+	var b, mask byte
+	for i := range v {
+		if mask == 0 {
+			b = r.readByte()
+			mask = 0x80
+		}
+		v[i] = b&mask != 0
+		mask >>= 1
+	}
+	return v
+}
+
+func (r *szReader) readDigests(n int) ([]bool, []uint32) {
+	var defined []bool
+	if r.readByte() == 0 {
+		defined = r.readBoolVector(n)
+	} else {
+		defined = make([]bool, n)
+		for i := range defined {
+			defined[i] = true
+		}
+	}
+	crcs := make([]uint32, n)
+	for i := range crcs {
+		if defined[i] {
+			crcs[i] = r.readUint32()
+		}
+	}
+	return defined, crcs
+}
+
+func (r *szReader) readFolder() *szFolder {
+	f := &szFolder{}
+	numCoders := r.readCount()
+	totalIn, totalOut := 0, 0
+	for i := 0; i < numCoders && r.err == nil; i++ {
+		flags := r.readByte()
+		if flags&0x80 != 0 {
+			r.fail(errors.New("不支持的 7z 编码器定义"))
+			return nil
+		}
+		coder := szCoder{method: string(r.readBytes(uint64(flags & 0x0F))), numIn: 1, numOut: 1}
+		if flags&0x10 != 0 {
+			coder.numIn = r.readCount()
+			coder.numOut = r.readCount()
+		}
+		if flags&0x20 != 0 {
+			coder.props = r.readBytes(r.readNumber())
+		}
+		totalIn += coder.numIn
+		totalOut += coder.numOut
+		f.coders = append(f.coders, coder)
+	}
+
+	for i := 0; i < totalOut-1 && r.err == nil; i++ {
+		f.bindPairs = append(f.bindPairs, szBindPair{in: r.readCount(), out: r.readCount()})
+	}
+
+	numPacked := totalIn - len(f.bindPairs)
+	if numPacked == 1 {
+		// 唯一的打包流是没有被绑定的那个输入流
+		for i := 0; i < totalIn; i++ {
+			if f.findBindPairForIn(i) < 0 {
+				f.packedStreams = append(f.packedStreams, i)
+				break
+			}
+		}
+	} else {
+		for i := 0; i < numPacked && r.err == nil; i++ {
+			f.packedStreams = append(f.packedStreams, r.readCount())
+		}
+	}
+	f.unpackSizes = make([]uint64, totalOut)
+	return f
+}
+
+func (f *szFolder) findBindPairForIn(in int) int {
+	for i, bp := range f.bindPairs {
+		if bp.in == in {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *szFolder) findBindPairForOut(out int) int {
+	for i, bp := range f.bindPairs {
+		if bp.out == out {
+			return i
+		}
+	}
+	return -1
+}
+
+// mainOutIndex 返回文件夹最终输出对应的输出流序号
+func (f *szFolder) mainOutIndex() int {
+	for i := range f.unpackSizes {
+		if f.findBindPairForOut(i) < 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+func (f *szFolder) unpackSize() uint64 {
+	return f.unpackSizes[f.mainOutIndex()]
+}
+
+// chain 按解码顺序返回从打包流到最终输出经过的编码器，
+// 只支持每个编码器都是一进一出的简单串联，否则返回 nil
+func (f *szFolder) chain() []int {
+	if len(f.packedStreams) != 1 {
+		return nil
+	}
+	var chain []int
+	in := f.packedStreams[0]
+	for len(chain) <= len(f.coders) {
+		coderIndex, firstIn, firstOut := -1, 0, 0
+		for i, c := range f.coders {
+			if in >= firstIn && in < firstIn+c.numIn {
+				coderIndex = i
+				break
+			}
+			firstIn += c.numIn
+			firstOut += c.numOut
+		}
+		if coderIndex < 0 {
+			return nil
+		}
+		c := f.coders[coderIndex]
+		if c.numIn != 1 || c.numOut != 1 {
+			return nil
+		}
+		chain = append(chain, coderIndex)
+		bp := f.findBindPairForOut(firstOut)
+		if bp < 0 {
+			return chain
+		}
+		in = f.bindPairs[bp].in
+	}
+	return nil
+}
+
+// outIndex 返回第 coderIndex 个编码器的输出流序号 (仅适用于一进一出的编码器)
+func (f *szFolder) outIndex(coderIndex int) int {
+	out := 0
+	for i := 0; i < coderIndex; i++ {
+		out += f.coders[i].numOut
+	}
+	return out
+}
+
+// isEncrypted 判断文件夹是否包含 7zAES 编码器
+func (f *szFolder) isEncrypted() bool {
+	for _, c := range f.coders {
+		if c.method == szMethodAES {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *szReader) readPackInfo(si *szStreamsInfo) {
+	si.packPos = r.readNumber()
+	numPackStreams := r.readCount()
+	for r.err == nil {
+		switch id := r.readByte(); id {
+		case szIDEnd:
+			return
+		case szIDSize:
+			si.packSizes = make([]uint64, numPackStreams)
+			for i := range si.packSizes {
+				si.packSizes[i] = r.readNumber()
+			}
+		case szIDCRC:
+			r.readDigests(numPackStreams)
+		default:
+			r.skipData()
+		}
+	}
+}
+
+func (r *szReader) readUnpackInfo(si *szStreamsInfo) {
+	if r.readByte() != szIDFolder {
+		r.fail(errors.New("7z 文件头格式错误: 缺少文件夹信息"))
+		return
+	}
+	numFolders := r.readCount()
+	if r.readByte() != 0 {
+		r.fail(errors.New("不支持外部存储的 7z 文件夹信息"))
+		return
+	}
+	for i := 0; i < numFolders && r.err == nil; i++ {
+		si.folders = append(si.folders, r.readFolder())
+	}
+	if r.err != nil {
+		return
+	}
+	if r.readByte() != szIDCodersUnpackSize {
+		r.fail(errors.New("7z 文件头格式错误: 缺少解压大小"))
+		return
+	}
+	for _, f := range si.folders {
+		for i := range f.unpackSizes {
+			f.unpackSizes[i] = r.readNumber()
+		}
+	}
+	for r.err == nil {
+		switch id := r.readByte(); id {
+		case szIDEnd:
+			return
+		case szIDCRC:
+			defined, crcs := r.readDigests(len(si.folders))
+			for i, f := range si.folders {
+				f.crcDefined, f.crc = defined[i], crcs[i]
+			}
+		default:
+			r.skipData()
+		}
+	}
+}
+
+// readSubStreamsInfo 只保留每个文件夹中第一个文件的大小和 CRC
+func (r *szReader) readSubStreamsInfo(si *szStreamsInfo) {
+	for _, f := range si.folders {
+		f.numSubstreams = 1
+	}
+
+	id := r.readByte()
+	for r.err == nil && id != szIDEnd && id != szIDSize && id != szIDCRC {
+		if id == szIDNumUnpackStream {
+			for _, f := range si.folders {
+				f.numSubstreams = r.readCount()
+			}
+		} else {
+			r.skipData()
+		}
+		id = r.readByte()
+	}
+
+	for _, f := range si.folders {
+		if f.numSubstreams == 1 {
+			f.firstSize = f.unpackSize()
+		}
+	}
+	if id == szIDSize {
+		for _, f := range si.folders {
+			for i := 0; i < f.numSubstreams-1; i++ {
+				size := r.readNumber()
+				if i == 0 {
+					f.firstSize = size
+				}
+			}
+		}
+		id = r.readByte()
+	}
+
+	for r.err == nil && id != szIDEnd {
+		if id == szIDCRC {
+			numDigests := 0
+			for _, f := range si.folders {
+				if f.numSubstreams != 1 || !f.crcDefined {
+					numDigests += f.numSubstreams
+				}
+			}
+			defined, crcs := r.readDigests(numDigests)
+			next := 0
+			for _, f := range si.folders {
+				if f.numSubstreams == 1 && f.crcDefined {
+					continue
+				}
+				if f.numSubstreams > 0 {
+					f.firstCRCDefined, f.firstCRC = defined[next], crcs[next]
+				}
+				next += f.numSubstreams
+			}
+		} else {
+			r.skipData()
+		}
+		id = r.readByte()
+	}
+
+	for _, f := range si.folders {
+		if f.numSubstreams == 1 && f.crcDefined {
+			f.firstCRCDefined, f.firstCRC = true, f.crc
+		}
+	}
+}
+
+func (r *szReader) readStreamsInfo() *szStreamsInfo {
+	si := &szStreamsInfo{}
+	hasSubStreams := false
+	for r.err == nil {
+		switch id := r.readByte(); id {
+		case szIDEnd:
+			if !hasSubStreams {
+				// 没有子流信息时，每个文件夹只包含一个文件
+				for _, f := range si.folders {
+					f.numSubstreams = 1
+					f.firstSize = f.unpackSize()
+					f.firstCRCDefined, f.firstCRC = f.crcDefined, f.crc
+				}
+			}
+			return si
+		case szIDPackInfo:
+			r.readPackInfo(si)
+		case szIDUnpackInfo:
+			r.readUnpackInfo(si)
+		case szIDSubStreamsInfo:
+			hasSubStreams = true
+			r.readSubStreamsInfo(si)
+		default:
+			r.fail(fmt.Errorf("7z 文件头中出现未知的属性: 0x%02x", id))
+		}
+	}
+	return si
+}
+
+// readHeader 解析未编码的文件头，只返回主数据流信息，文件列表部分不需要
+func (r *szReader) readHeader() *szStreamsInfo {
+	for r.err == nil {
+		switch id := r.readByte(); id {
+		case szIDArchiveProperties:
+			for r.err == nil && r.readByte() != 0 {
+				r.skipData()
+			}
+		case szIDAdditionalStreamsInfo:
+			r.readStreamsInfo()
+		case szIDMainStreamsInfo:
+			return r.readStreamsInfo()
+		case szIDFilesInfo, szIDEnd:
+			return &szStreamsInfo{}
+		default:
+			r.fail(fmt.Errorf("7z 文件头中出现未知的属性: 0x%02x", id))
+		}
+	}
+	return nil
+}
+
+// locate 计算每个文件夹的打包流在文件中的位置
+func (si *szStreamsInfo) locate() error {
+	offset := int64(szSignatureHeaderSize) + int64(si.packPos)
+	packIndex := 0
+	for _, f := range si.folders {
+		if f == nil || len(f.packedStreams) == 0 {
+			return errors.New("7z 文件夹信息不完整")
+		}
+		if packIndex+len(f.packedStreams) > len(si.packSizes) {
+			return errors.New("7z 打包流信息不完整")
+		}
+		f.packOffset = offset
+		for i := 0; i < packIndex; i++ {
+			f.packOffset += int64(si.packSizes[i])
+		}
+		f.packSize = int64(si.packSizes[packIndex])
+		packIndex += len(f.packedStreams)
+	}
+	return nil
+}
+
+// sevenZipCracker 在进程内校验 7z 密码，解压与列表仍交给 7z
+type sevenZipCracker struct {
+	*commandCracker
+	mode         Mode
+	headerFolder *szFolder   // 文件头加密时用于校验的文件夹
+	folders      []*szFolder // 需要校验的加密数据文件夹
+	fallback     bool        // 存在无法在进程内校验的加密数据，交给 7z
+	confirm      bool        // 校验的文件夹中有无法校验 CRC 的，进程内的检查通过后还需要交给 7z 确认
+}
+
+func newSevenZipCracker(archive utils.Archive, mode Mode) (Cracker, error) {
+	cmd, err := newCommandCracker(archive, mode)
+	if err != nil {
+		return nil, err
+	}
+	if len(archive.Missing) > 0 {
+		return nil, errors.New("7z 分卷不完整")
+	}
+	c := &sevenZipCracker{commandCracker: cmd.(*commandCracker), mode: mode}
+
+	// 分卷按顺序拼接后就是一个完整的 7z 文件
+	f, closer, err := c.open()
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	header, err := readSevenZipNextHeader(f)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		// 空压缩包
+		return c, nil
+	}
+
+	for header[0] == szIDEncodedHeader {
+		r := &szReader{buf: header, pos: 1}
+		si := r.readStreamsInfo()
+		if r.err != nil {
+			return nil, r.err
+		}
+		if len(si.folders) == 0 {
+			return nil, errors.New("7z 编码文件头中没有文件夹")
+		}
+		if err := si.locate(); err != nil {
+			return nil, err
+		}
+		folder := si.folders[0]
+		if folder.isEncrypted() {
+			if !folder.decodable() {
+				return nil, errors.New("不支持的 7z 文件头编码方式")
+			}
+			if !folder.keySupported() || folder.unpackSize() > szMaxHeaderSize {
+				// 无法在进程内派生密钥或文件头过大，每个密码都会被当作错误，只能交给 7z
+				c.fallback = true
+				return c, nil
+			}
+			c.headerFolder = folder
+			return c, nil
+		}
+		if header, err = c.decodeHeader(f, folder, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if header[0] != szIDHeader {
+		return nil, errors.New("7z 文件头格式错误")
+	}
+	if err := c.selectFolders(header); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// open 打开拼接后的分卷，返回的数据从 7z 签名头开始 (自解压程序跳过前面的程序部分)
+func (c *sevenZipCracker) open() (io.ReaderAt, io.Closer, error) {
+	m, err := openMultiFile(c.volumes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return io.NewSectionReader(m, c.offset, m.size-c.offset), m, nil
+}
+
+// readSevenZipNextHeader 读取并校验签名头指向的文件头，空压缩包返回 nil
+func readSevenZipNextHeader(f io.ReaderAt) ([]byte, error) {
+	sig := make([]byte, szSignatureHeaderSize)
+	if _, err := f.ReadAt(sig, 0); err != nil {
+		return nil, fmt.Errorf("读取 7z 签名头失败: %w", err)
+	}
+	if !bytes.Equal(sig[:6], sevenZipSignature) {
+		return nil, errors.New("不是 7z 文件")
+	}
+	if crc32.ChecksumIEEE(sig[12:32]) != binary.LittleEndian.Uint32(sig[8:12]) {
+		return nil, errors.New("7z 签名头校验失败")
+	}
+
+	nextOffset := binary.LittleEndian.Uint64(sig[12:20])
+	nextSize := binary.LittleEndian.Uint64(sig[20:28])
+	nextCRC := binary.LittleEndian.Uint32(sig[28:32])
+	if nextSize == 0 {
+		return nil, nil
+	}
+	if nextSize > szMaxHeaderSize || nextOffset > 1<<62 {
+		return nil, errors.New("7z 文件头大小异常")
+	}
+
+	header := make([]byte, nextSize)
+	if _, err := f.ReadAt(header, szSignatureHeaderSize+int64(nextOffset)); err != nil {
+		return nil, fmt.Errorf("读取 7z 文件头失败 (压缩包可能不完整): %w", err)
+	}
+	if crc32.ChecksumIEEE(header) != nextCRC {
+		return nil, errors.New("7z 文件头校验失败")
+	}
+	return header, nil
+}
+
+// selectFolders 从文件头中挑选需要校验的加密文件夹
+func (c *sevenZipCracker) selectFolders(header []byte) error {
+	r := &szReader{buf: header, pos: 1}
+	si := r.readHeader()
+	if r.err != nil {
+		return r.err
+	}
+	if err := si.locate(); err != nil {
+		return err
+	}
+
+	var candidates []*szFolder
+	for _, f := range si.folders {
+		if !f.isEncrypted() || f.numSubstreams == 0 {
+			continue
+		}
+		if !f.verifiable() {
+			// 有无法在进程内校验的加密数据，精确模式必须交给 7z
+			if c.mode == AccurateMode {
+				c.fallback = true
+			}
+			continue
+		}
+		candidates = append(candidates, f)
+	}
+	if len(candidates) == 0 {
+		// 有加密文件夹但都无法校验
+		for _, f := range si.folders {
+			if f.isEncrypted() && f.numSubstreams > 0 {
+				c.fallback = true
+			}
+		}
+		return nil
+	}
+
+	// 优先选择可以完整校验 CRC 且第一个文件最小的文件夹
+	sort.SliceStable(candidates, func(i, j int) bool {
+		ci, cj := candidates[i].crcVerifiable(), candidates[j].crcVerifiable()
+		if ci != cj {
+			return ci
+		}
+		if ci {
+			return candidates[i].firstSize < candidates[j].firstSize
+		}
+		return candidates[i].packSize < candidates[j].packSize
+	})
+	if c.mode == QuickMode {
+		candidates = candidates[:1]
+	}
+	for _, f := range candidates {
+		if !f.crcVerifiable() {
+			c.confirm = true
+		}
+	}
+	c.folders = candidates
+	return nil
+}
+
+// decodable 判断文件夹能否按 [7zAES] + [Copy/LZMA/LZMA2] 的方式解出数据
+func (f *szFolder) decodable() bool {
+	chain := f.chain()
+	if len(chain) == 0 {
+		return false
+	}
+	i := 0
+	if f.coders[chain[0]].method == szMethodAES {
+		i++
+	}
+	if i < len(chain) {
+		switch f.coders[chain[i]].method {
+		case szMethodCopy, szMethodLZMA, szMethodLZMA2:
+		default:
+			return false
+		}
+	}
+	// 只允许在打包流一侧解密
+	for _, ci := range chain[1:] {
+		if f.coders[ci].method == szMethodAES {
+			return false
+		}
+	}
+	return true
+}
+
+// crcVerifiable 判断能否完整解出第一个文件并校验 CRC
+func (f *szFolder) crcVerifiable() bool {
+	chain := f.chain()
+	return f.decodable() && len(chain) <= 2 && f.firstCRCDefined && f.firstSize <= szMaxCRCVerifySize
+}
+
+// verifiable 判断加密文件夹能否在进程内检查：要么可以校验 CRC，要么解密后是可以检查结构的压缩流。
+// 只检查结构时不能确定密码正确，需要再交给 7z 确认
+func (f *szFolder) verifiable() bool {
+	chain := f.chain()
+	if !f.decodable() || f.coders[chain[0]].method != szMethodAES || !f.keySupported() {
+		return false
+	}
+	if f.crcVerifiable() {
+		return true
+	}
+	if len(chain) < 2 {
+		return false
+	}
+	method := f.coders[chain[1]].method
+	return method == szMethodLZMA || method == szMethodLZMA2
+}
+
+// keySupported 判断能否按第一个编码器的 7zAES 属性在进程内派生密钥
+func (f *szFolder) keySupported() bool {
+	_, _, _, err := parseSevenZipAESProps(f.coders[f.chain()[0]].props)
+	return err == nil
+}
+
+// wrap 把解码过程中的错误 err 归类：读取压缩包出错时原样返回该错误，否则包装为 errSZDecode
+func (s *sourceReader) wrap(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case s.err != nil:
+		return s.err
+	}
+	return fmt.Errorf("%w: %v", errSZDecode, err)
+}
+
+// decodeFolder 依次经过解密和解压，把数据写入 out，最多写出 limit 字节。
+// 返回值 final 表示写出的是否为文件夹的最终输出 (后面没有未处理的编码器)。
+// 解出的数据不合法时返回的错误包装了 errSZDecode
+func decodeFolder(f io.ReaderAt, folder *szFolder, key, iv []byte, out io.Writer, limit int64) (bool, error) {
+	chain := folder.chain()
+	src := newSourceReader(nil, io.NewSectionReader(f, folder.packOffset, folder.packSize))
+	var r io.Reader = src
+	size := folder.packSize
+
+	i := 0
+	if folder.coders[chain[0]].method == szMethodAES {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return false, err
+		}
+		size = int64(folder.unpackSizes[folder.outIndex(chain[0])])
+		r = io.LimitReader(&cbcReader{r: r, mode: cipher.NewCBCDecrypter(block, iv)}, size)
+		i++
+	}
+
+	if i == len(chain) {
+		_, err := io.CopyN(out, r, min(size, limit))
+		return true, src.wrap(err)
+	}
+
+	coder := folder.coders[chain[i]]
+	size = int64(folder.unpackSizes[folder.outIndex(chain[i])])
+	final := i == len(chain)-1
+	br := bufio.NewReader(r)
+	var err error
+	switch coder.method {
+	case szMethodCopy:
+		_, err = io.CopyN(out, br, min(size, limit))
+	case szMethodLZMA:
+		if len(coder.props) < 5 {
+			return false, errors.New("LZMA 属性长度错误")
+		}
+		err = decodeLZMA(br, coder.props, size, out, limit)
+	case szMethodLZMA2:
+		if len(coder.props) < 1 || coder.props[0] > 40 {
+			return false, errors.New("LZMA2 属性错误")
+		}
+		err = decodeLZMA2(br, coder.props[0], size, out, limit)
+	default:
+		return false, errors.New("不支持的 7z 编码方式")
+	}
+	return final, src.wrap(err)
+}
+
+// decodeHeader 完整解出编码后的文件头并校验 CRC，解出的文件头不合法时返回的错误包装了 errSZDecode
+func (c *sevenZipCracker) decodeHeader(f io.ReaderAt, folder *szFolder, keys *sevenZipKeyCache) ([]byte, error) {
+	var key, iv []byte
+	if folder.isEncrypted() {
+		var err error
+		if key, iv, err = keys.get(folder.coders[folder.chain()[0]].props); err != nil {
+			return nil, err
+		}
+	}
+	size := folder.unpackSize()
+	if size > szMaxHeaderSize {
+		return nil, errors.New("7z 文件头大小异常")
+	}
+	var buf bytes.Buffer
+	final, err := decodeFolder(f, folder, key, iv, &buf, int64(size))
+	if err != nil {
+		return nil, err
+	}
+	data := buf.Bytes()
+	if !final || uint64(len(data)) < size {
+		return nil, fmt.Errorf("%w: 7z 文件头解码不完整", errSZDecode)
+	}
+	data = data[:size]
+	if folder.crcDefined && crc32.ChecksumIEEE(data) != folder.crc {
+		return nil, fmt.Errorf("%w: 7z 文件头校验失败", errSZDecode)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: 7z 文件头为空", errSZDecode)
+	}
+	return data, nil
+}
+
+func (c *sevenZipCracker) Probe(ctx context.Context) (Encryption, error) {
+	switch {
+	case c.fallback:
+		return c.commandCracker.Probe(ctx)
+	case c.headerFolder != nil:
+		return EncryptionHeaders, nil
+	case len(c.folders) > 0:
+		return EncryptionEntries, nil
+	}
+	return EncryptionNone, nil
+}
+
+func (c *sevenZipCracker) TryPassword(ctx context.Context, password string) (bool, error) {
+	if c.fallback {
+		return c.commandCracker.TryPassword(ctx, password)
+	}
+	if c.headerFolder == nil && len(c.folders) == 0 {
+		// 没有任何加密内容，与 7z t 的行为保持一致
+		return true, nil
+	}
+
+	f, closer, err := c.open()
+	if err != nil {
+		return false, err
+	}
+	defer closer.Close()
+	keys := &sevenZipKeyCache{password: password, keys: make(map[string][2][]byte)}
+
+	folders, confirm := c.folders, c.confirm
+	if c.headerFolder != nil {
+		header, err := c.decodeHeader(f, c.headerFolder, keys)
+		if errors.Is(err, errSZDecode) {
+			// 解不出合法的文件头，说明密码错误
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if c.mode == QuickMode {
+			return true, nil
+		}
+		// 精确模式下继续校验文件头中记录的加密数据
+		if header[0] != szIDHeader {
+			return false, nil
+		}
+		inner := &sevenZipCracker{commandCracker: c.commandCracker, mode: c.mode}
+		if err := inner.selectFolders(header); err != nil || inner.fallback {
+			// 文件头已经解开，但其中的内容无法在进程内校验
+			return c.commandCracker.TryPassword(ctx, password)
+		}
+		folders, confirm = inner.folders, inner.confirm
+	}
+
+	for _, folder := range folders {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		ok, err := c.verifyFolder(f, folder, keys)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	if confirm {
+		// 只检查了压缩流的结构，错误的密码也有很小的概率通过
+		return c.commandCracker.TryPassword(ctx, password)
+	}
+	return true, nil
+}
+
+// verifyFolder 解密并解压加密文件夹的开头，判断密码是否正确。
+// 无法校验 CRC 时只检查压缩流的结构，返回 true 只表示密码可能正确
+func (c *sevenZipCracker) verifyFolder(f io.ReaderAt, folder *szFolder, keys *sevenZipKeyCache) (bool, error) {
+	key, iv, err := keys.get(folder.coders[folder.chain()[0]].props)
+	if err != nil {
+		return false, err
+	}
+
+	if folder.crcVerifiable() {
+		w := &prefixHasher{h: crc32.NewIEEE(), remaining: int64(folder.firstSize)}
+		_, err := decodeFolder(f, folder, key, iv, w, int64(folder.firstSize))
+		if err != nil {
+			return false, ignoreDecodeError(err)
+		}
+		return w.n == int64(folder.firstSize) && w.h.Sum32() == folder.firstCRC, nil
+	}
+
+	// 第一个文件太大或没有 CRC，只检查解压流的结构是否合法，由调用方交给 7z 确认
+	if _, err = decodeFolder(f, folder, key, iv, io.Discard, szStructureVerifySize); err != nil {
+		return false, ignoreDecodeError(err)
+	}
+	return true, nil
+}
+
+// ignoreDecodeError 把密码错误导致的解码失败当作正常的校验结果，其他错误原样返回
+func ignoreDecodeError(err error) error {
+	if errors.Is(err, errSZDecode) {
+		return nil
+	}
+	return err
+}
+
+// sevenZipKeyCache 在一次尝试中缓存派生出的密钥，避免对相同参数重复计算
+type sevenZipKeyCache struct {
+	password string
+	keys     map[string][2][]byte
+}
+
+func (kc *sevenZipKeyCache) get(props []byte) ([]byte, []byte, error) {
+	if k, ok := kc.keys[string(props)]; ok {
+		return k[0], k[1], nil
+	}
+	key, iv, err := sevenZipAESKey(props, kc.password)
+	if err != nil {
+		return nil, nil, err
+	}
+	kc.keys[string(props)] = [2][]byte{key, iv}
+	return key, iv, nil
+}
+
+// parseSevenZipAESProps 解析 7zAES 编码器属性，返回迭代次数的幂、盐值和初始向量。
+// 迭代次数过大 (派生一次密钥就要数分钟) 或长度不合法的属性返回错误
+func parseSevenZipAESProps(props []byte) (byte, []byte, []byte, error) {
+	if len(props) < 1 {
+		return 0, nil, nil, errors.New("7zAES 属性缺失")
+	}
+	numCyclesPower := props[0] & 0x3F
+	if numCyclesPower > 24 && numCyclesPower != 0x3F {
+		return 0, nil, nil, fmt.Errorf("7zAES 迭代次数过大: 2^%d", numCyclesPower)
+	}
+	var salt []byte
+	iv := make([]byte, aes.BlockSize)
+	if props[0]&0xC0 != 0 {
+		if len(props) < 2 {
+			return 0, nil, nil, errors.New("7zAES 属性长度错误")
+		}
+		saltSize := int(props[0]>>7&1) + int(props[1]>>4)
+		ivSize := int(props[0]>>6&1) + int(props[1]&0x0F)
+		if len(props) != 2+saltSize+ivSize || ivSize > aes.BlockSize {
+			return 0, nil, nil, errors.New("7zAES 属性长度错误")
+		}
+		salt = props[2 : 2+saltSize]
+		copy(iv, props[2+saltSize:])
+	}
+	return numCyclesPower, salt, iv, nil
+}
+
+// sevenZipAESKey 按 7zAES 编码器属性派生 AES-256 密钥，并返回初始向量
+func sevenZipAESKey(props []byte, password string) ([]byte, []byte, error) {
+	numCyclesPower, salt, iv, err := parseSevenZipAESProps(props)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 7z 使用 UTF-16LE 编码的密码
+	var pw []byte
+	for _, u := range utf16.Encode([]rune(password)) {
+		pw = append(pw, byte(u), byte(u>>8))
+	}
+
+	key := make([]byte, 32)
+	if numCyclesPower == 0x3F {
+		n := copy(key, salt)
+		copy(key[n:], pw)
+		return key, iv, nil
+	}
+
+	// 每轮输入为 盐值 + 密码 + 8 字节轮次计数，拼成一块一次写入以减少调用开销
+	buf := make([]byte, len(salt)+len(pw)+8)
+	copy(buf, salt)
+	copy(buf[len(salt):], pw)
+	counter := buf[len(salt)+len(pw):]
+	h := sha256.New()
+	for round := uint64(0); round < 1<<numCyclesPower; round++ {
+		binary.LittleEndian.PutUint64(counter, round)
+		h.Write(buf)
+	}
+	return h.Sum(key[:0]), iv, nil
+}
+
+// cbcReader 以 AES-CBC 方式边读边解密
+type cbcReader struct {
+	r    io.Reader
+	mode cipher.BlockMode
+	buf  []byte
+	raw  [32 * aes.BlockSize]byte
+}
+
+func (c *cbcReader) Read(p []byte) (int, error) {
+	if len(c.buf) == 0 {
+		n, err := io.ReadFull(c.r, c.raw[:])
+		n -= n % aes.BlockSize
+		if n == 0 {
+			if err == nil || err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return 0, err
+		}
+		c.mode.CryptBlocks(c.raw[:n], c.raw[:n])
+		c.buf = c.raw[:n]
+	}
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+// prefixHasher 只对写入的前 remaining 个字节计算哈希
+type prefixHasher struct {
+	h         hash.Hash32
+	remaining int64
+	n         int64
+}
+
+func (p *prefixHasher) Write(b []byte) (int, error) {
+	if int64(len(b)) > p.remaining {
+		p.h.Write(b[:p.remaining])
+		p.n += p.remaining
+		p.remaining = 0
+	} else {
+		p.h.Write(b)
+		p.n += int64(len(b))
+		p.remaining -= int64(len(b))
+	}
+	return len(b), nil
+}
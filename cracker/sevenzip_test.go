@@ -0,0 +1,176 @@
+package cracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func newTestSevenZip(t *testing.T, name string, mode Mode) *sevenZipCracker {
+	t.Helper()
+	c, err := newSevenZipCracker(testArchive(t, filepath.Join("testdata", name)), mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sz := c.(*sevenZipCracker)
+	no7z(t, sz.commandCracker)
+	return sz
+}
+
+func TestSevenZipTryPassword(t *testing.T) {
+	tests := []struct {
+		name       string
+		encryption Encryption
+	}{
+		{"aes_header.7z", EncryptionHeaders},
+		{"aes_data.7z", EncryptionEntries},
+		{"aes_copy.7z", EncryptionEntries},
+	}
+	for _, tt := range tests {
+		for mode, modeName := range testModes {
+			t.Run(tt.name+"/"+modeName, func(t *testing.T) {
+				ctx := context.Background()
+				c := newTestSevenZip(t, tt.name, mode)
+				if c.fallback || c.confirm {
+					t.Fatal("应当可以在进程内校验 CRC")
+				}
+				if enc, err := c.Probe(ctx); err != nil || enc != tt.encryption {
+					t.Fatalf("Probe() = %v, %v，应为 %v", enc, err, tt.encryption)
+				}
+				for password, want := range map[string]bool{"password": true, "notpassword": false, "": false} {
+					ok, err := c.TryPassword(ctx, password)
+					if err != nil {
+						t.Fatalf("TryPassword(%q) 出错: %v", password, err)
+					}
+					if ok != want {
+						t.Errorf("TryPassword(%q) = %v，应为 %v", password, ok, want)
+					}
+				}
+			})
+		}
+	}
+}
+
+// 第一个文件无法校验 CRC 时，进程内只能排除错误的密码，通过检查的密码需要 7z 确认
+func TestSevenZipConfirm(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("使用 shell 脚本代替 7z")
+	}
+	for _, code := range []int{0, 2} {
+		t.Run(fmt.Sprint(code), func(t *testing.T) {
+			ctx := context.Background()
+			c := newTestSevenZip(t, "aes_data.7z", QuickMode)
+			for _, f := range c.folders {
+				f.firstCRCDefined = false
+			}
+			c.confirm = true
+
+			log := filepath.Join(t.TempDir(), "calls")
+			script := filepath.Join(t.TempDir(), "7z")
+			if err := os.WriteFile(script, []byte(fmt.Sprintf("#!/bin/sh\necho \"$1\" >> %q\nexit %d\n", log, code)), 0755); err != nil {
+				t.Fatal(err)
+			}
+			c.command = script
+
+			ok, err := c.TryPassword(ctx, "notpassword")
+			if err != nil || ok {
+				t.Fatalf("错误的密码: TryPassword() = %v, %v", ok, err)
+			}
+			if _, err := os.Stat(log); err == nil {
+				t.Fatal("错误的密码没有通过结构检查，不应调用 7z")
+			}
+
+			ok, err = c.TryPassword(ctx, "password")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != (code == 0) {
+				t.Errorf("7z 退出码为 %d 时 TryPassword() = %v", code, ok)
+			}
+			if calls, _ := os.ReadFile(log); string(calls) != "t\n" {
+				t.Errorf("应当调用一次 7z t，实际: %q", calls)
+			}
+		})
+	}
+}
+
+// withAESCyclesPower 复制 testdata 中的 7z 文件，把编码文件头中 7zAES 属性的迭代次数改为 2^power，并更新校验值
+func withAESCyclesPower(t *testing.T, name string, power byte) string {
+	t.Helper()
+	data := readTestData(t, name)
+	start := szSignatureHeaderSize + int(binary.LittleEndian.Uint64(data[12:20]))
+	header := data[start : start+int(binary.LittleEndian.Uint64(data[20:28]))]
+	i := bytes.Index(header, []byte(szMethodAES))
+	if i < 0 || header[i+len(szMethodAES)] == 0 {
+		t.Fatalf("%s 的文件头中没有 7zAES 属性", name)
+	}
+	props := header[i+len(szMethodAES)+1:]
+	props[0] = props[0]&0xC0 | power
+	binary.LittleEndian.PutUint32(data[28:32], crc32.ChecksumIEEE(header))
+	binary.LittleEndian.PutUint32(data[8:12], crc32.ChecksumIEEE(data[12:32]))
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// 迭代次数超出支持范围时无法在进程内派生密钥，每个密码都交给 7z，而不是全部当作错误
+func TestSevenZipUnsupportedKeyFallback(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("使用 shell 脚本代替 7z")
+	}
+	path := withAESCyclesPower(t, "aes_header.7z", 25)
+	for mode, modeName := range testModes {
+		t.Run(modeName, func(t *testing.T) {
+			c, err := newSevenZipCracker(testArchive(t, path), mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sz := c.(*sevenZipCracker)
+			if !sz.fallback || sz.headerFolder != nil {
+				t.Fatalf("fallback = %v, headerFolder = %v，应当交给 7z", sz.fallback, sz.headerFolder)
+			}
+
+			script := filepath.Join(t.TempDir(), "7z")
+			if err := os.WriteFile(script, []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			sz.command = script
+			if ok, err := sz.TryPassword(context.Background(), "password"); err != nil || !ok {
+				t.Errorf("TryPassword() = %v, %v，应当采用 7z 的结果", ok, err)
+			}
+		})
+	}
+}
+
+func TestParseSevenZipAESProps(t *testing.T) {
+	salt := bytes.Repeat([]byte{1}, 16)
+	tests := []struct {
+		name  string
+		props []byte
+		ok    bool
+	}{
+		{"迭代 2^19，带 IV", append([]byte{0x53, 0x07}, make([]byte, 8)...), true},
+		{"迭代 2^24", []byte{24}, true},
+		{"迭代 2^25", []byte{25}, false},
+		{"不迭代", []byte{0x3F}, true},
+		{"盐值和 IV 各 16 字节", append([]byte{0xD3, 0xFF}, append(salt, salt...)...), true},
+		{"缺少属性", nil, false},
+		{"缺少长度字节", []byte{0x53}, false},
+		{"长度与声明不符", []byte{0x53, 0x07, 0}, false},
+	}
+	for _, tt := range tests {
+		_, _, _, err := parseSevenZipAESProps(tt.props)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: parseSevenZipAESProps() 出错 %v，应为成功 %v", tt.name, err, tt.ok)
+		}
+	}
+}
@@ -2,8 +2,13 @@
 
 package cracker
 
-import "os/exec"
+import (
+	"os/exec"
+	"syscall"
+)
 
-func hideWindow(cmd *exec.Cmd) {
-	// No-op on non-Windows systems
-}
\ No newline at end of file
+// setupCommand 让 7z 在单独的进程组中运行，终端中按下 Ctrl-C 时只通知本程序，
+// 由本程序通过 context 结束 7z，避免 7z 先退出而被误判为密码错误
+func setupCommand(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
@@ -7,6 +7,11 @@ import (
 	"syscall"
 )
 
-func hideWindow(cmd *exec.Cmd) {
-	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
-}
\ No newline at end of file
+// setupCommand 隐藏 7z 的控制台窗口，并让它在单独的进程组中运行，
+// 控制台中按下 Ctrl-C 时只通知本程序，由本程序通过 context 结束 7z，避免 7z 先退出而被误判为密码错误
+func setupCommand(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow:    true,
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP,
+	}
+}
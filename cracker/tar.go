@@ -0,0 +1,77 @@
+package cracker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+)
+
+// --- 压缩的 tar 包 ---
+//
+// 直接对 .tar.gz 等文件执行 7z x 只会得到中间的 .tar 文件，
+// 因此先用 7z x -so 解出 tar 数据，再通过管道交给第二个 7z 按 tar 格式展开。
+
+// extractTar 通过管道解压压缩的 tar 包
+func (c *commandCracker) extractTar(ctx context.Context, password, absDestPath string) error {
+	output, err := c.runPipeline(ctx,
+		append([]string{"x", "-so", "-p" + password}, c.target()...),
+		[]string{"x", "-si", "-ttar", fmt.Sprintf("-o%s", absDestPath), "-y"},
+	)
+	if err != nil {
+		return fmt.Errorf("解压失败: %w\n--- 7z 输出 ---\n%s", err, string(output))
+	}
+	return nil
+}
+
+// listTarEntries 通过管道列出压缩的 tar 包中的条目
+func (c *commandCracker) listTarEntries(ctx context.Context, password string) ([]Entry, error) {
+	output, err := c.runPipeline(ctx,
+		append([]string{"x", "-so", "-p" + password}, c.target()...),
+		[]string{"l", "-slt", "-sccUTF-8", "-si", "-ttar"},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("无法列出文件: %w\n--- 7z 输出 ---\n%s", err, string(output))
+	}
+	_, entries := parseEntries(string(output))
+	return entries, nil
+}
+
+// runPipeline 运行 "7z producer | 7z consumer"，返回 consumer 的输出和 producer 的错误输出
+func (c *commandCracker) runPipeline(ctx context.Context, producerArgs, consumerArgs []string) ([]byte, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	var output, producerErr bytes.Buffer
+	producer := c.command7z(ctx, producerArgs...)
+	producer.Stdout = w
+	producer.Stderr = &producerErr
+	consumer := c.command7z(ctx, consumerArgs...)
+	consumer.Stdin = r
+	consumer.Stdout = &output
+	consumer.Stderr = &output
+
+	if err := producer.Start(); err != nil {
+		r.Close()
+		w.Close()
+		return nil, err
+	}
+	err = consumer.Start()
+	// 子进程各自持有管道的一端，关闭本进程中的副本，
+	// 这样任意一方退出时另一方都能收到 EOF 或写入错误，而不会一直阻塞
+	r.Close()
+	w.Close()
+	if err != nil {
+		producer.Wait()
+		return nil, err
+	}
+
+	consumerErr := consumer.Wait()
+	if err := producer.Wait(); err != nil {
+		output.Write(producerErr.Bytes())
+		return output.Bytes(), err
+	}
+	return output.Bytes(), consumerErr
+}
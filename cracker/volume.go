@@ -0,0 +1,98 @@
+package cracker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// multiFile 把按顺序排列的分卷拼接成一个连续的 io.ReaderAt，单个文件同样适用
+type multiFile struct {
+	files   []*os.File
+	offsets []int64 // 每个分卷在拼接后数据中的起始位置
+	size    int64
+}
+
+func openMultiFile(paths []string) (*multiFile, error) {
+	m := &multiFile{}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			m.Close()
+			return nil, err
+		}
+		m.files = append(m.files, f)
+		m.offsets = append(m.offsets, m.size)
+		m.size += info.Size()
+	}
+	return m, nil
+}
+
+func (m *multiFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, os.ErrInvalid
+	}
+	// 找到 off 所在的分卷，然后依次向后读取
+	i := sort.Search(len(m.offsets), func(i int) bool { return m.offsets[i] > off }) - 1
+	n := 0
+	for ; i >= 0 && i < len(m.files) && n < len(p); i++ {
+		k, err := m.files[i].ReadAt(p[n:], off+int64(n)-m.offsets[i])
+		n += k
+		if err != nil && err != io.EOF {
+			return n, err
+		}
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *multiFile) Close() error {
+	var first error
+	for _, f := range m.files {
+		if err := f.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// sourceReader 读取压缩包中的一段数据，记录与密码无关的错误：读取文件出错、文件在这段数据结束之前被截断，
+// 以及 ctx 被取消 (ctx 为 nil 时不检查)。解密或解压失败时据此区分 I/O 错误与密码错误
+type sourceReader struct {
+	ctx context.Context
+	r   *io.SectionReader
+	err error
+}
+
+func newSourceReader(ctx context.Context, r *io.SectionReader) *sourceReader {
+	return &sourceReader{ctx: ctx, r: r}
+}
+
+func (s *sourceReader) Read(p []byte) (int, error) {
+	if s.ctx != nil {
+		if err := s.ctx.Err(); err != nil {
+			s.err = err
+			return 0, err
+		}
+	}
+	n, err := s.r.Read(p)
+	switch {
+	case err == io.EOF:
+		if pos, _ := s.r.Seek(0, io.SeekCurrent); pos < s.r.Size() {
+			s.err = fmt.Errorf("压缩包数据不完整: %w", io.ErrUnexpectedEOF)
+		}
+	case err != nil:
+		s.err = err
+	}
+	return n, err
+}
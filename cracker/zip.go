@@ -0,0 +1,355 @@
+package cracker
+
+import (
+	"ArchiveTools/utils"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/flate"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// --- ZIP 原生破解器 ---
+//
+// 在进程内直接校验 ZIP 密码，不再为每个密码启动一次 7z：
+//   - 传统 PKWARE 加密 (ZipCrypto)：先用加密头的校验字节快速排除，再完整解密并校验 CRC32
+//   - WinZip AES：先比对密码校验值，再校验 HMAC-SHA1 认证码
+// 解压和列出文件仍然交给 7z 完成。
+
+const (
+	zipMethodStore   = 0
+	zipMethodDeflate = 8
+	zipMethodBzip2   = 12
+	zipMethodAES     = 99
+
+	zipFlagEncrypted      = 0x1
+	zipFlagDataDescriptor = 0x8
+
+	zipCryptoHeaderLen = 12
+	zipAESExtraID      = 0x9901
+	zipAESAuthLen      = 10
+	zipAESIterations   = 1000
+)
+
+// zipEntry 保存校验某个加密文件所需的信息
+type zipEntry struct {
+	name        string
+	method      uint16 // 实际的压缩方法，AES 条目为解密后使用的方法
+	flags       uint16
+	crc32       uint32
+	modTime     uint16
+	offset      int64 // 数据在文件中的起始位置
+	packedSize  int64
+	size        int64
+	aesStrength int    // 0 表示 ZipCrypto，1/2/3 分别对应 AES-128/192/256
+	header      []byte // ZipCrypto 的 12 字节加密头，或 AES 的盐值加密码校验值
+}
+
+type zipCracker struct {
+	*commandCracker
+	entries []*zipEntry // 需要校验的加密条目，快速模式下只有体积最小的一个
+	comment string
+}
+
+// newZipCracker 解析 ZIP 目录并挑选要校验的加密条目
+func newZipCracker(archive utils.Archive, mode Mode) (Cracker, error) {
+	cmd, err := newCommandCracker(archive, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := zip.OpenReader(archive.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var entries []*zipEntry
+	for _, f := range r.File {
+		if f.Flags&zipFlagEncrypted == 0 || strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		entry, err := newZipEntry(f)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(entries) > 0 {
+		if err := loadZipHeaders(archive.Path, entries); err != nil {
+			return nil, err
+		}
+	}
+
+	// 优先校验非空且体积最小的条目，空文件的 CRC 校验几乎没有区分度
+	sort.SliceStable(entries, func(i, j int) bool {
+		if (entries[i].size == 0) != (entries[j].size == 0) {
+			return entries[i].size != 0
+		}
+		return entries[i].packedSize < entries[j].packedSize
+	})
+	if mode == QuickMode && len(entries) > 1 {
+		entries = entries[:1]
+	}
+
+	return &zipCracker{
+		commandCracker: cmd.(*commandCracker),
+		entries:        entries,
+		comment:        r.Comment,
+	}, nil
+}
+
+func newZipEntry(f *zip.File) (*zipEntry, error) {
+	offset, err := f.DataOffset()
+	if err != nil {
+		return nil, err
+	}
+	entry := &zipEntry{
+		name:       f.Name,
+		method:     f.Method,
+		flags:      f.Flags,
+		crc32:      f.CRC32,
+		modTime:    f.ModifiedTime,
+		offset:     offset,
+		packedSize: int64(f.CompressedSize64),
+		size:       int64(f.UncompressedSize64),
+	}
+
+	headerLen := zipCryptoHeaderLen
+	if f.Method == zipMethodAES {
+		strength, method, err := parseZipAESExtra(f.Extra)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		entry.aesStrength = strength
+		entry.method = method
+		headerLen = zipAESSaltLen(strength) + 2
+		if entry.packedSize < int64(headerLen+zipAESAuthLen) {
+			return nil, fmt.Errorf("%s: AES 数据长度异常", f.Name)
+		}
+	} else if entry.packedSize < zipCryptoHeaderLen {
+		return nil, fmt.Errorf("%s: 加密头长度异常", f.Name)
+	}
+	entry.header = make([]byte, headerLen)
+	return entry, nil
+}
+
+// parseZipAESExtra 从扩展字段中读取 AES 强度和实际压缩方法
+func parseZipAESExtra(extra []byte) (int, uint16, error) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra[0:2])
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if len(extra) < 4+size {
+			break
+		}
+		if id == zipAESExtraID && size >= 7 {
+			data := extra[4 : 4+size]
+			strength := int(data[4])
+			if strength < 1 || strength > 3 {
+				return 0, 0, fmt.Errorf("未知的 AES 强度: %d", strength)
+			}
+			return strength, binary.LittleEndian.Uint16(data[5:7]), nil
+		}
+		extra = extra[4+size:]
+	}
+	return 0, 0, errors.New("缺少 AES 扩展字段")
+}
+
+// loadZipHeaders 预先读取每个条目的加密头，绝大多数错误密码只需要用到它
+func loadZipHeaders(filePath string, entries []*zipEntry) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for _, entry := range entries {
+		if _, err := f.ReadAt(entry.header, entry.offset); err != nil {
+			return fmt.Errorf("读取 %s 的加密头失败: %w", entry.name, err)
+		}
+	}
+	return nil
+}
+
+func zipAESSaltLen(strength int) int {
+	return 4 + strength*4
+}
+
+// Probe 直接使用解析目录时得到的信息，不需要启动 7z
+func (c *zipCracker) Probe(ctx context.Context) (Encryption, error) {
+	if len(c.entries) == 0 {
+		return EncryptionNone, nil
+	}
+	return EncryptionEntries, nil
+}
+
+// Comment 返回打开时从目录结尾记录中读取的注释，不需要再运行 7z
+func (c *zipCracker) Comment(ctx context.Context) (string, error) {
+	return c.comment, nil
+}
+
+func (c *zipCracker) TryPassword(ctx context.Context, password string) (bool, error) {
+	for _, entry := range c.entries {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		var ok bool
+		var err error
+		if entry.aesStrength > 0 {
+			ok, err = c.checkAES(ctx, entry, []byte(password))
+		} else {
+			ok, err = c.checkZipCrypto(ctx, entry, password)
+		}
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	// 没有任何加密条目时，与 7z t 的行为保持一致，视为任意密码都可以打开
+	return true, nil
+}
+
+// openEntryData 返回条目加密数据的读取器
+func (c *zipCracker) openEntryData(entry *zipEntry) (*os.File, *io.SectionReader, error) {
+	f, err := os.Open(c.filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, io.NewSectionReader(f, entry.offset, entry.packedSize), nil
+}
+
+// checkZipCrypto 校验传统加密：先比对校验字节，通过后再完整解密并校验 CRC32
+func (c *zipCracker) checkZipCrypto(ctx context.Context, entry *zipEntry, password string) (bool, error) {
+	keys := newZipCryptoKeys([]byte(password))
+	var header [zipCryptoHeaderLen]byte
+	copy(header[:], entry.header)
+	keys.decrypt(header[:])
+
+	// 设置了数据描述符时，部分压缩软件用修改时间的高字节作为校验字节
+	check := header[zipCryptoHeaderLen-1]
+	if check != byte(entry.crc32>>24) &&
+		!(entry.flags&zipFlagDataDescriptor != 0 && check == byte(entry.modTime>>8)) {
+		return false, nil
+	}
+
+	if entry.method != zipMethodStore && entry.method != zipMethodDeflate && entry.method != zipMethodBzip2 {
+		// 无法在进程内解压的方法，交给 7z 做最终确认
+		return c.commandCracker.TryPassword(ctx, password)
+	}
+
+	f, data, err := c.openEntryData(entry)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	if _, err := data.Seek(zipCryptoHeaderLen, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	src := newSourceReader(ctx, data)
+	var decompressed io.Reader = &zipCryptoReader{r: src, keys: keys}
+	switch entry.method {
+	case zipMethodDeflate:
+		fr := flate.NewReader(decompressed)
+		defer fr.Close()
+		decompressed = fr
+	case zipMethodBzip2:
+		decompressed = bzip2.NewReader(decompressed)
+	}
+
+	crc := crc32.NewIEEE()
+	n, err := io.Copy(crc, io.LimitReader(decompressed, entry.size+1))
+	if src.err != nil {
+		// 读取文件出错、文件不完整或任务被取消，与密码无关
+		return false, src.err
+	}
+	if err != nil {
+		// 密码错误时解压出的数据是乱码，解压器报错同样说明密码不对
+		return false, nil
+	}
+	return n == entry.size && crc.Sum32() == entry.crc32, nil
+}
+
+// checkAES 校验 WinZip AES：先比对 2 字节的密码校验值，再校验整段密文的 HMAC
+func (c *zipCracker) checkAES(ctx context.Context, entry *zipEntry, password []byte) (bool, error) {
+	saltLen := zipAESSaltLen(entry.aesStrength)
+	keyLen := 8 + entry.aesStrength*8
+	salt := entry.header[:saltLen]
+
+	derived := pbkdf2Key(sha1.New, password, salt, zipAESIterations, 2*keyLen+2)
+	if !bytes.Equal(derived[2*keyLen:], entry.header[saltLen:]) {
+		return false, nil
+	}
+
+	f, data, err := c.openEntryData(entry)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	cipherLen := entry.packedSize - int64(len(entry.header)) - zipAESAuthLen
+	mac := hmac.New(sha1.New, derived[keyLen:2*keyLen])
+	src := newSourceReader(ctx, io.NewSectionReader(data, int64(len(entry.header)), cipherLen))
+	if _, err := io.Copy(mac, src); err != nil {
+		return false, err
+	}
+	if src.err != nil {
+		return false, src.err
+	}
+	stored := make([]byte, zipAESAuthLen)
+	if _, err := data.ReadAt(stored, int64(len(entry.header))+cipherLen); err != nil {
+		return false, err
+	}
+	return hmac.Equal(mac.Sum(nil)[:zipAESAuthLen], stored), nil
+}
+
+// zipCryptoKeys 是传统 PKWARE 加密的三个内部密钥
+type zipCryptoKeys [3]uint32
+
+func newZipCryptoKeys(password []byte) *zipCryptoKeys {
+	keys := &zipCryptoKeys{0x12345678, 0x23456789, 0x34567890}
+	for _, b := range password {
+		keys.update(b)
+	}
+	return keys
+}
+
+func (k *zipCryptoKeys) update(b byte) {
+	k[0] = crc32Update(k[0], b)
+	k[1] = (k[1]+k[0]&0xff)*134775813 + 1
+	k[2] = crc32Update(k[2], byte(k[1]>>24))
+}
+
+func (k *zipCryptoKeys) decrypt(buf []byte) {
+	for i, c := range buf {
+		temp := k[2] | 2
+		p := c ^ byte((temp*(temp^1))>>8)
+		k.update(p)
+		buf[i] = p
+	}
+}
+
+func crc32Update(crc uint32, b byte) uint32 {
+	return (crc >> 8) ^ crc32.IEEETable[byte(crc)^b]
+}
+
+// zipCryptoReader 边读边解密传统加密的数据
+type zipCryptoReader struct {
+	r    io.Reader
+	keys *zipCryptoKeys
+}
+
+func (z *zipCryptoReader) Read(p []byte) (int, error) {
+	n, err := z.r.Read(p)
+	z.keys.decrypt(p[:n])
+	return n, err
+}
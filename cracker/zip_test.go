@@ -0,0 +1,187 @@
+package cracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// zipTestFiles 是 testdata 中的 ZIP 文件，加密的密码都是 password，
+// 每个文件包含 hello.txt (13 字节)、lines.txt (2040 字节) 和空文件 empty.txt
+var zipTestFiles = []struct {
+	name       string
+	encryption Encryption
+}{
+	{"zip_plain.zip", EncryptionNone},             // zip，只有 hello.txt，没有加密
+	{"zip_crypto_store.zip", EncryptionEntries},   // zip -0 -P，ZipCrypto，不压缩
+	{"zip_crypto_deflate.zip", EncryptionEntries}, // zip -P，ZipCrypto，lines.txt 使用 Deflate
+	{"zip_crypto_stream.zip", EncryptionEntries},  // bsdtar，ZipCrypto，Deflate
+	{"zip_aes128_store.zip", EncryptionEntries},   // bsdtar，AES-128，不压缩
+	{"zip_aes256_deflate.zip", EncryptionEntries}, // bsdtar，AES-256，Deflate
+}
+
+func newTestZip(t *testing.T, name string, mode Mode) *zipCracker {
+	t.Helper()
+	c, err := newZipCracker(testArchive(t, filepath.Join("testdata", name)), mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := c.(*zipCracker)
+	no7z(t, z.commandCracker)
+	return z
+}
+
+func TestZipTryPassword(t *testing.T) {
+	for _, tt := range zipTestFiles {
+		for mode, modeName := range testModes {
+			t.Run(tt.name+"/"+modeName, func(t *testing.T) {
+				ctx := context.Background()
+				c := newTestZip(t, tt.name, mode)
+				if enc, err := c.Probe(ctx); err != nil || enc != tt.encryption {
+					t.Fatalf("Probe() = %v, %v，应为 %v", enc, err, tt.encryption)
+				}
+				encrypted := tt.encryption != EncryptionNone
+				for password, want := range map[string]bool{"password": true, "notpassword": !encrypted, "": !encrypted} {
+					ok, err := c.TryPassword(ctx, password)
+					if err != nil {
+						t.Fatalf("TryPassword(%q) 出错: %v", password, err)
+					}
+					if ok != want {
+						t.Errorf("TryPassword(%q) = %v，应为 %v", password, ok, want)
+					}
+				}
+			})
+		}
+	}
+}
+
+// 快速模式只校验体积最小的非空条目，精确模式校验全部加密条目
+func TestZipEntries(t *testing.T) {
+	c := newTestZip(t, "zip_crypto_deflate.zip", QuickMode)
+	if len(c.entries) != 1 || c.entries[0].name != "hello.txt" {
+		t.Errorf("快速模式应当只校验 hello.txt，实际: %v", zipEntryNames(c.entries))
+	}
+	c = newTestZip(t, "zip_crypto_deflate.zip", AccurateMode)
+	if got := zipEntryNames(c.entries); fmt.Sprint(got) != "[hello.txt lines.txt empty.txt]" {
+		t.Errorf("精确模式应当校验全部加密条目，实际: %v", got)
+	}
+}
+
+func zipEntryNames(entries []*zipEntry) []string {
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.name)
+	}
+	return names
+}
+
+// 错误的密码有 1/256 的概率通过 ZipCrypto 的校验字节，需要靠解密后的 CRC32 排除
+func TestZipCryptoCheckByteCollision(t *testing.T) {
+	for _, name := range []string{"zip_crypto_store.zip", "zip_crypto_deflate.zip", "zip_crypto_stream.zip"} {
+		t.Run(name, func(t *testing.T) {
+			c := newTestZip(t, name, AccurateMode)
+			for _, entry := range c.entries {
+				if entry.size == 0 {
+					continue
+				}
+				password := zipCheckByteCollision(t, entry)
+				ok, err := c.checkZipCrypto(context.Background(), entry, password)
+				if err != nil || ok {
+					t.Errorf("%s: 校验字节一致的错误密码 %q: checkZipCrypto() = %v, %v", entry.name, password, ok, err)
+				}
+			}
+		})
+	}
+}
+
+// zipCheckByteCollision 找到一个能通过 entry 校验字节的错误密码
+func zipCheckByteCollision(t *testing.T, entry *zipEntry) string {
+	t.Helper()
+	for i := 0; i < 100000; i++ {
+		password := fmt.Sprintf("wrong%d", i)
+		var header [zipCryptoHeaderLen]byte
+		copy(header[:], entry.header)
+		newZipCryptoKeys([]byte(password)).decrypt(header[:])
+		check := header[zipCryptoHeaderLen-1]
+		if check == byte(entry.crc32>>24) || (entry.flags&zipFlagDataDescriptor != 0 && check == byte(entry.modTime>>8)) {
+			return password
+		}
+	}
+	t.Fatal("没有找到校验字节一致的错误密码")
+	return ""
+}
+
+// copyTestZip 把 testdata 中的 ZIP 复制到临时文件夹并创建破解器，用于之后修改文件
+func copyTestZip(t *testing.T, name string) *zipCracker {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, readTestData(t, name), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := newZipCracker(testArchive(t, path), AccurateMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := c.(*zipCracker)
+	no7z(t, z.commandCracker)
+	return z
+}
+
+// 读取压缩包出错与密码无关，应当作为错误返回，而不是当作密码错误继续查找
+func TestZipReadError(t *testing.T) {
+	for _, name := range []string{"zip_crypto_store.zip", "zip_crypto_deflate.zip", "zip_aes128_store.zip"} {
+		t.Run(name+"/截断", func(t *testing.T) {
+			c := copyTestZip(t, name)
+			// 截断到最大的条目的数据中间，模拟解压过程中文件被截断
+			largest := c.entries[0]
+			for _, e := range c.entries {
+				if e.packedSize > largest.packedSize {
+					largest = e
+				}
+			}
+			if err := os.Truncate(c.filePath, largest.offset+largest.packedSize/2); err != nil {
+				t.Fatal(err)
+			}
+			ok, err := c.TryPassword(context.Background(), "password")
+			if err == nil || ok {
+				t.Errorf("TryPassword() = %v, %v，应当返回错误", ok, err)
+			}
+			if !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("TryPassword() 返回 %v，应当说明数据不完整", err)
+			}
+		})
+		t.Run(name+"/删除", func(t *testing.T) {
+			c := copyTestZip(t, name)
+			if err := os.Remove(c.filePath); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := c.TryPassword(context.Background(), "password"); err == nil {
+				t.Error("文件被删除后 TryPassword() 应当返回错误")
+			}
+		})
+	}
+}
+
+// 解密和解压过程中检查 ctx，较大的条目也能及时中断
+func TestZipCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, name := range []string{"zip_crypto_store.zip", "zip_crypto_deflate.zip", "zip_aes128_store.zip"} {
+		c := newTestZip(t, name, AccurateMode)
+		var err error
+		for _, entry := range c.entries {
+			if entry.aesStrength > 0 {
+				_, err = c.checkAES(ctx, entry, []byte("password"))
+			} else {
+				_, err = c.checkZipCrypto(ctx, entry, "password")
+			}
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("%s/%s: 返回 %v，应为 context.Canceled", name, entry.name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 进度行的刷新间隔，避免频繁重绘拖慢工作协程
+const progressRefreshInterval = 100 * time.Millisecond
+
+// ProgressBoard 在终端底部为每个工作协程维护一行进度，
+// 并允许在进度行上方输出普通的日志内容。
+// 非终端环境下（例如输出被重定向到文件）不会绘制进度行。
+type ProgressBoard struct {
+	mu    sync.Mutex
+	lines []string
+	drawn int  // 当前屏幕上已绘制的进度行数
+	dirty bool // 自上次绘制后进度是否有变化
+	live  bool
+	width int
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// NewProgressBoard 创建一个拥有 slots 个进度行的进度面板
+func NewProgressBoard(slots int) *ProgressBoard {
+	b := &ProgressBoard{
+		lines: make([]string, slots),
+		live:  isColorEnabled(),
+		width: GetTerminalWidth(),
+		done:  make(chan struct{}),
+	}
+	if b.live {
+		b.wg.Add(1)
+		go b.refreshLoop()
+	}
+	return b
+}
+
+// Update 更新指定槽位的进度文本，传入空字符串表示该槽位空闲
+func (b *ProgressBoard) Update(slot int, text string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if slot < 0 || slot >= len(b.lines) || b.lines[slot] == text {
+		return
+	}
+	b.lines[slot] = text
+	b.dirty = true
+}
+
+// Println 在进度行上方输出内容，print 中可以调用任意 Print 系列函数
+func (b *ProgressBoard) Println(print func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.erase()
+	print()
+	b.draw()
+}
+
+// Close 停止刷新并清除所有进度行
+func (b *ProgressBoard) Close() {
+	if b.live {
+		close(b.done)
+		b.wg.Wait()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.erase()
+}
+
+func (b *ProgressBoard) refreshLoop() {
+	defer b.wg.Done()
+	ticker := time.NewTicker(progressRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+			b.mu.Lock()
+			if b.dirty {
+				b.erase()
+				b.draw()
+			}
+			b.mu.Unlock()
+		}
+	}
+}
+
+// erase 将光标移回进度区域的起点并清除其后的全部内容，调用方需持有锁
+func (b *ProgressBoard) erase() {
+	if !b.live || b.drawn == 0 {
+		return
+	}
+	fmt.Printf("\033[%dA\r\033[J", b.drawn)
+	b.drawn = 0
+}
+
+// draw 绘制所有活动中的进度行，调用方需持有锁
+func (b *ProgressBoard) draw() {
+	b.dirty = false
+	if !b.live {
+		return
+	}
+	for _, line := range b.lines {
+		if line == "" {
+			continue
+		}
+		// 进度行不能折行，否则光标回退的行数会对不上
+		fmt.Println(colorize(truncateToWidth(line, b.width-1), Dim))
+		b.drawn++
+	}
+}
+
+// truncateToWidth 按终端显示宽度截断字符串，中文字符按两个字符宽度计算
+func truncateToWidth(s string, width int) string {
+	var sb strings.Builder
+	used := 0
+	for _, r := range s {
+		w := 1
+		if r > 0x7F {
+			w = 2
+		}
+		if used+w > width {
+			break
+		}
+		sb.WriteRune(r)
+		used += w
+	}
+	return sb.String()
+}
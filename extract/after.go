@@ -0,0 +1,224 @@
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// --- 解压后的操作 ---
+//
+// 压缩包解压成功后可以删除源文件或移到其他文件夹，解压失败的压缩包也可以单独移走，便于之后集中处理。
+// 所有操作在整个任务结束后统一执行，执行前先列出计划，预览模式下只列出不执行。
+
+// Action 决定压缩包处理完后如何处理源文件
+type Action int
+
+const (
+	Keep   Action = iota // 保留在原位置
+	Delete               // 删除压缩包，分卷压缩包删除全部分卷
+	Move                 // 移动到指定的文件夹，分卷压缩包移动全部分卷
+)
+
+var actionNames = []string{"keep", "delete", "move"}
+
+// ParseAction 解析命令行中的解压后操作
+func ParseAction(name string) (Action, error) {
+	for i, n := range actionNames {
+		if strings.EqualFold(name, n) {
+			return Action(i), nil
+		}
+	}
+	return 0, fmt.Errorf("无效的解压后操作: %s，可选 %s", name, strings.Join(actionNames, "、"))
+}
+
+// String 返回命令行中使用的名称
+func (a Action) String() string {
+	if a < 0 || int(a) >= len(actionNames) {
+		return fmt.Sprintf("Action(%d)", int(a))
+	}
+	return actionNames[a]
+}
+
+const (
+	// DefaultDoneDir 和 DefaultFailedDir 是目标文件夹中默认的移动目标
+	DefaultDoneDir   = "done"
+	DefaultFailedDir = "failed"
+)
+
+// Cleanup 是解压后对源文件的处理方式
+type Cleanup struct {
+	OnSuccess Action // 解压成功后的操作
+	DoneDir   string // OnSuccess 为 Move 时的目标文件夹，为空时使用目标文件夹中的 done
+	OnFailure Action // 解压失败后的操作，只能是 Keep 或 Move
+	FailedDir string // OnFailure 为 Move 时的目标文件夹，为空时使用目标文件夹中的 failed
+}
+
+// Enabled 判断是否需要对源文件进行操作
+func (c Cleanup) Enabled() bool {
+	return c.OnSuccess != Keep || c.OnFailure != Keep
+}
+
+// Label 返回显示给用户的说明，root 为任务的目标文件夹
+func (c Cleanup) Label(root string) string {
+	var parts []string
+	switch c.OnSuccess {
+	case Delete:
+		parts = append(parts, "解压成功后删除压缩包")
+	case Move:
+		parts = append(parts, "解压成功后移动到 "+resolveDir(root, c.DoneDir, DefaultDoneDir))
+	}
+	if c.OnFailure == Move {
+		parts = append(parts, "解压失败后移动到 "+resolveDir(root, c.FailedDir, DefaultFailedDir))
+	}
+	if len(parts) == 0 {
+		return "保留压缩包"
+	}
+	return strings.Join(parts, "，")
+}
+
+// Step 是对一个压缩包的源文件的操作
+type Step struct {
+	Archive string   // 压缩包的路径，用于显示
+	Action  Action   // Delete 或 Move，Keep 表示按设置应当操作，但因为 Reason 保留了压缩包
+	Files   []string // 压缩包的全部分卷
+	Targets []string // Action 为 Move 时每个分卷的目标路径，与 Files 一一对应
+	Reason  string   // Action 为 Keep 时保留压缩包的原因
+}
+
+func (s Step) String() string {
+	switch s.Action {
+	case Keep:
+		return fmt.Sprintf("保留 %s%s: %s", s.Archive, volumeCount(s.Files), s.Reason)
+	case Delete:
+		return "删除 " + s.Archive + volumeCount(s.Files)
+	}
+	return fmt.Sprintf("移动 %s%s -> %s", s.Archive, volumeCount(s.Files), filepath.Dir(s.Targets[0]))
+}
+
+func volumeCount(files []string) string {
+	if len(files) > 1 {
+		return fmt.Sprintf(" (%d 个分卷)", len(files))
+	}
+	return ""
+}
+
+// Plan 返回压缩包处理完后的操作，不需要操作时返回 false。
+// root 为任务的目标文件夹，移动时保留压缩包相对于 root 的子文件夹，避免不同文件夹中的同名压缩包互相冲突。
+// skipped 为因同名文件跳过、没有解压的条目数量，不为 0 时压缩包没有完整解压，返回 Action 为 Keep 的操作说明原因
+func (c Cleanup) Plan(root, archive string, volumes []string, success bool, skipped int) (Step, bool) {
+	if success && skipped > 0 && c.OnSuccess != Keep {
+		// 删除或移走后，被跳过的文件只能从压缩包中找回
+		reason := fmt.Sprintf("有 %d 个条目因同名文件被跳过，没有完整解压", skipped)
+		return Step{Archive: archive, Action: Keep, Files: volumes, Reason: reason}, true
+	}
+	action, dir := c.OnFailure, resolveDir(root, c.FailedDir, DefaultFailedDir)
+	if success {
+		action, dir = c.OnSuccess, resolveDir(root, c.DoneDir, DefaultDoneDir)
+	}
+	step := Step{Archive: archive, Action: action, Files: volumes}
+	switch action {
+	case Delete:
+		return step, true
+	case Move:
+		for _, v := range volumes {
+			if inside(dir, v) {
+				// 已经在目标文件夹中，例如上次移动后又被递归扫描到
+				return Step{}, false
+			}
+			rel, err := filepath.Rel(root, v)
+			if err != nil || !inside(root, v) {
+				rel = filepath.Base(v)
+			}
+			step.Targets = append(step.Targets, filepath.Join(dir, rel))
+		}
+		return step, true
+	}
+	return Step{}, false
+}
+
+// resolveDir 返回移动的目标文件夹，dir 为空时使用 root 中的 name
+func resolveDir(root, dir, name string) string {
+	if dir == "" {
+		return filepath.Join(root, name)
+	}
+	return dir
+}
+
+// inside 判断 path 是否位于文件夹 dir 中
+func inside(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && !escapes(filepath.ToSlash(rel))
+}
+
+// Apply 执行操作。移动前先确认所有目标路径都不存在，有同名文件时不移动任何分卷，避免分卷被拆散
+func (s Step) Apply() error {
+	switch s.Action {
+	case Keep:
+		return nil
+	case Delete:
+		var errs []error
+		for _, f := range s.Files {
+			if err := os.Remove(f); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	for _, t := range s.Targets {
+		if _, err := os.Lstat(t); err == nil {
+			return fmt.Errorf("目标位置已存在同名文件 '%s'", t)
+		}
+	}
+	for i, f := range s.Files {
+		if err := os.MkdirAll(filepath.Dir(s.Targets[i]), 0755); err != nil {
+			return err
+		}
+		if err := moveFile(f, s.Targets[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveFile 移动文件，无法直接重命名时 (例如移动到其他磁盘) 先复制再删除原文件
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+	if copyFile(src, dst) != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// copyFile 把 src 复制为新文件 dst，复制失败时删除不完整的 dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
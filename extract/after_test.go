@@ -0,0 +1,92 @@
+package extract
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCleanupPlan(t *testing.T) {
+	root := filepath.FromSlash("/data")
+	path := func(p string) string { return filepath.Join(root, filepath.FromSlash(p)) }
+	volumes := []string{path("sub/a.part1.rar"), path("sub/a.part2.rar")}
+
+	tests := []struct {
+		name    string
+		cleanup Cleanup
+		volumes []string
+		success bool
+		skipped int
+		want    Step
+		ok      bool
+	}{
+		{
+			name:    "保留",
+			cleanup: Cleanup{},
+			volumes: volumes,
+			success: true,
+		},
+		{
+			name:    "成功后删除",
+			cleanup: Cleanup{OnSuccess: Delete},
+			volumes: volumes,
+			success: true,
+			want:    Step{Archive: "a", Action: Delete, Files: volumes},
+			ok:      true,
+		},
+		{
+			name:    "成功后移动，保留子文件夹",
+			cleanup: Cleanup{OnSuccess: Move},
+			volumes: volumes,
+			success: true,
+			want: Step{Archive: "a", Action: Move, Files: volumes,
+				Targets: []string{path("done/sub/a.part1.rar"), path("done/sub/a.part2.rar")}},
+			ok: true,
+		},
+		{
+			name:    "失败后移动到指定文件夹",
+			cleanup: Cleanup{OnSuccess: Delete, OnFailure: Move, FailedDir: path("bad")},
+			volumes: volumes,
+			want: Step{Archive: "a", Action: Move, Files: volumes,
+				Targets: []string{path("bad/sub/a.part1.rar"), path("bad/sub/a.part2.rar")}},
+			ok: true,
+		},
+		{
+			name:    "失败后保留",
+			cleanup: Cleanup{OnSuccess: Delete},
+			volumes: volumes,
+		},
+		{
+			name:    "已经在目标文件夹中",
+			cleanup: Cleanup{OnSuccess: Move},
+			volumes: []string{path("done/a.zip")},
+			success: true,
+		},
+		{
+			name:    "有跳过的同名文件时不删除",
+			cleanup: Cleanup{OnSuccess: Delete},
+			volumes: volumes,
+			success: true,
+			skipped: 2,
+			want:    Step{Archive: "a", Action: Keep, Files: volumes, Reason: "有 2 个条目因同名文件被跳过，没有完整解压"},
+			ok:      true,
+		},
+		{
+			name:    "有跳过的同名文件时不移动",
+			cleanup: Cleanup{OnSuccess: Move},
+			volumes: volumes,
+			success: true,
+			skipped: 1,
+			want:    Step{Archive: "a", Action: Keep, Files: volumes, Reason: "有 1 个条目因同名文件被跳过，没有完整解压"},
+			ok:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := tt.cleanup.Plan(root, "a", tt.volumes, tt.success, tt.skipped)
+			if ok != tt.ok || !reflect.DeepEqual(step, tt.want) {
+				t.Errorf("Plan() = %+v, %v，应为 %+v, %v", step, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
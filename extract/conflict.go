@@ -0,0 +1,194 @@
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Policy 决定解压出的文件与目标位置已有的同名文件冲突时的处理方式
+type Policy int
+
+const (
+	Overwrite      Policy = iota // 用压缩包中的文件覆盖已有的文件
+	Skip                         // 保留已有的文件，不解压压缩包中的同名文件
+	RenameNew                    // 压缩包中的文件改名为 "name (1).ext" 后解压
+	RenameExisting               // 已有的文件改名为 "name (1).ext"，再解压压缩包中的文件
+	Abort                        // 存在同名文件时不解压整个压缩包
+)
+
+var policyNames = []string{"overwrite", "skip", "rename-new", "rename-existing", "abort"}
+
+// ParsePolicy 解析命令行中的冲突处理方式
+func ParsePolicy(name string) (Policy, error) {
+	for i, n := range policyNames {
+		if strings.EqualFold(name, n) {
+			return Policy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("无效的同名文件处理方式: %s，可选 %s", name, strings.Join(policyNames, "、"))
+}
+
+// String 返回命令行中使用的名称
+func (p Policy) String() string {
+	if p < 0 || int(p) >= len(policyNames) {
+		return fmt.Sprintf("Policy(%d)", int(p))
+	}
+	return policyNames[p]
+}
+
+// Label 返回显示给用户的名称
+func (p Policy) Label() string {
+	switch p {
+	case Overwrite:
+		return "覆盖已有文件"
+	case Skip:
+		return "跳过同名文件"
+	case RenameNew:
+		return "重命名解压出的文件"
+	case RenameExisting:
+		return "重命名已有文件"
+	case Abort:
+		return "跳过整个压缩包"
+	}
+	return p.String()
+}
+
+// ErrConflict 表示目标位置存在同名文件，按 Abort 的设置没有解压
+var ErrConflict = errors.New("目标位置存在同名文件")
+
+// Conflicts 统计一个压缩包解压时遇到的同名文件及其处理结果
+type Conflicts struct {
+	Total       int // 同名文件的数量
+	Overwritten int
+	Skipped     int
+	Renamed     int // 解压出的文件或已有的文件被改名
+}
+
+// String 返回供显示的统计，例如 "同名文件 3 个: 覆盖 2 个, 重命名 1 个"
+func (c Conflicts) String() string {
+	var parts []string
+	for _, p := range []struct {
+		label string
+		n     int
+	}{{"覆盖", c.Overwritten}, {"跳过", c.Skipped}, {"重命名", c.Renamed}} {
+		if p.n > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d 个", p.label, p.n))
+		}
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("同名文件 %d 个", c.Total)
+	}
+	return fmt.Sprintf("同名文件 %d 个: %s", c.Total, strings.Join(parts, ", "))
+}
+
+// Add 累加另一个压缩包的统计
+func (c *Conflicts) Add(o Conflicts) {
+	c.Total += o.Total
+	c.Overwritten += o.Overwritten
+	c.Skipped += o.Skipped
+	c.Renamed += o.Renamed
+}
+
+// countConflicts 统计 src 中与 dst 同名的文件，两边都是文件夹时不算冲突，继续比较其中的内容
+func countConflicts(src, dst string, c *Conflicts) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		target := filepath.Join(dst, e.Name())
+		info, err := os.Lstat(target)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return err
+		case e.IsDir() && info.IsDir():
+			if err := countConflicts(filepath.Join(src, e.Name()), target, c); err != nil {
+				return err
+			}
+		default:
+			c.Total++
+		}
+	}
+	return nil
+}
+
+// merge 把 src 中的内容移动到 dst，按 policy 处理同名文件，移动后的路径记入 r.Extracted
+func merge(src, dst string, policy Policy, r *Result) error {
+	c := &r.Conflicts
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		from := filepath.Join(src, e.Name())
+		to := filepath.Join(dst, e.Name())
+		info, err := os.Lstat(to)
+		if errors.Is(err, os.ErrNotExist) {
+			if err := os.Rename(from, to); err != nil {
+				return err
+			}
+			r.Extracted = append(r.Extracted, to)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if e.IsDir() && info.IsDir() {
+			if err := merge(from, to, policy, r); err != nil {
+				return err
+			}
+			continue
+		}
+
+		c.Total++
+		switch {
+		case policy == Skip:
+			c.Skipped++
+		case policy == Overwrite && !e.IsDir() && !info.IsDir():
+			if err := os.Rename(from, to); err != nil {
+				return err
+			}
+			r.Extracted = append(r.Extracted, to)
+			c.Overwritten++
+		case policy == RenameExisting:
+			if err := os.Rename(to, freeName(to)); err != nil {
+				return err
+			}
+			if err := os.Rename(from, to); err != nil {
+				return err
+			}
+			r.Extracted = append(r.Extracted, to)
+			c.Renamed++
+		default:
+			// RenameNew，以及文件与文件夹同名、无法直接覆盖的情况，都保留已有的内容并改名解压
+			renamed := freeName(to)
+			if err := os.Rename(from, renamed); err != nil {
+				return err
+			}
+			r.Extracted = append(r.Extracted, renamed)
+			c.Renamed++
+		}
+	}
+	return nil
+}
+
+// freeName 返回与 path 同目录、尚未被使用的 "name (n).ext" 形式的路径
+func freeName(path string) string {
+	dir, base := filepath.Split(path)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	if stem == "" {
+		// ".gitignore" 这类以点开头的名称整体作为主名
+		stem, ext = base, ""
+	}
+	for n := 1; ; n++ {
+		candidate := filepath.Join(dir, fmt.Sprintf("%s (%d)%s", stem, n, ext))
+		if _, err := os.Lstat(candidate); errors.Is(err, os.ErrNotExist) {
+			return candidate
+		}
+	}
+}
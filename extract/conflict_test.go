@@ -0,0 +1,222 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeTree 在 root 中创建 files 中的文件，键为使用 / 分隔的相对路径，值为文件内容
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readTree 返回 root 中所有文件的相对路径和内容
+func readTree(t *testing.T, root string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		files[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestFreeName(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a (1).txt": "", "b (1)": "", "b (2)": ""})
+	tests := map[string]string{
+		"a.txt":      "a (2).txt",
+		"b":          "b (3)",
+		"c.txt":      "c (1).txt",
+		".gitignore": ".gitignore (1)",
+		"x.tar.gz":   "x.tar (1).gz",
+	}
+	for name, want := range tests {
+		if got := freeName(filepath.Join(dir, name)); got != filepath.Join(dir, want) {
+			t.Errorf("freeName(%q) = %q，应为 %q", name, filepath.Base(got), want)
+		}
+	}
+}
+
+// 压缩包中的内容与目标目录中已有的内容：同名的文件、同名的文件夹 (逐层合并)、文件与文件夹同名
+var (
+	conflictSrc = map[string]string{
+		"new.txt":       "new",
+		"same.txt":      "same-new",
+		"dir/inner.txt": "inner-new",
+		"dir/added.txt": "added",
+		"clash":         "clash-file-new",
+		"folder/x":      "x",
+	}
+	conflictDst = map[string]string{
+		"same.txt":      "same-old",
+		"same (1).txt":  "taken",
+		"dir/inner.txt": "inner-old",
+		"clash/keep":    "keep",
+		"folder":        "folder-file-old",
+	}
+)
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		policy    Policy
+		want      map[string]string
+		conflicts Conflicts
+		extracted []string
+	}{
+		{
+			policy: Overwrite,
+			want: map[string]string{
+				"new.txt": "new", "same.txt": "same-new", "same (1).txt": "taken",
+				"dir/inner.txt": "inner-new", "dir/added.txt": "added",
+				"clash/keep": "keep", "clash (1)": "clash-file-new",
+				"folder": "folder-file-old", "folder (1)/x": "x",
+			},
+			// 文件与文件夹同名时无法覆盖，改名解压
+			conflicts: Conflicts{Total: 4, Overwritten: 2, Renamed: 2},
+			extracted: []string{"clash (1)", "dir/added.txt", "dir/inner.txt", "folder (1)", "new.txt", "same.txt"},
+		},
+		{
+			policy: Skip,
+			want: map[string]string{
+				"new.txt": "new", "same.txt": "same-old", "same (1).txt": "taken",
+				"dir/inner.txt": "inner-old", "dir/added.txt": "added",
+				"clash/keep": "keep", "folder": "folder-file-old",
+			},
+			conflicts: Conflicts{Total: 4, Skipped: 4},
+			extracted: []string{"dir/added.txt", "new.txt"},
+		},
+		{
+			policy: RenameNew,
+			want: map[string]string{
+				"new.txt": "new", "same.txt": "same-old", "same (1).txt": "taken", "same (2).txt": "same-new",
+				"dir/inner.txt": "inner-old", "dir/inner (1).txt": "inner-new", "dir/added.txt": "added",
+				"clash/keep": "keep", "clash (1)": "clash-file-new",
+				"folder": "folder-file-old", "folder (1)/x": "x",
+			},
+			conflicts: Conflicts{Total: 4, Renamed: 4},
+			extracted: []string{"clash (1)", "dir/added.txt", "dir/inner (1).txt", "folder (1)", "new.txt", "same (2).txt"},
+		},
+		{
+			policy: RenameExisting,
+			want: map[string]string{
+				"new.txt": "new", "same.txt": "same-new", "same (1).txt": "taken", "same (2).txt": "same-old",
+				"dir/inner.txt": "inner-new", "dir/inner (1).txt": "inner-old", "dir/added.txt": "added",
+				"clash": "clash-file-new", "clash (1)/keep": "keep",
+				"folder (1)": "folder-file-old", "folder/x": "x",
+			},
+			conflicts: Conflicts{Total: 4, Renamed: 4},
+			extracted: []string{"clash", "dir/added.txt", "dir/inner.txt", "folder", "new.txt", "same.txt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			src, dst := t.TempDir(), t.TempDir()
+			writeTree(t, src, conflictSrc)
+			writeTree(t, dst, conflictDst)
+
+			var r Result
+			if err := merge(src, dst, tt.policy, &r); err != nil {
+				t.Fatal(err)
+			}
+			if got := readTree(t, dst); !maps.Equal(got, tt.want) {
+				t.Errorf("合并后的内容为 %v\n应为 %v", got, tt.want)
+			}
+			if r.Conflicts != tt.conflicts {
+				t.Errorf("Conflicts = %+v，应为 %+v", r.Conflicts, tt.conflicts)
+			}
+			var extracted []string
+			for _, p := range r.Extracted {
+				rel, _ := filepath.Rel(dst, p)
+				extracted = append(extracted, filepath.ToSlash(rel))
+			}
+			slices.Sort(extracted)
+			if !slices.Equal(extracted, tt.extracted) {
+				t.Errorf("Extracted = %q，应为 %q", extracted, tt.extracted)
+			}
+		})
+	}
+}
+
+func TestCountConflicts(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	writeTree(t, src, conflictSrc)
+	writeTree(t, dst, conflictDst)
+	var c Conflicts
+	if err := countConflicts(src, dst, &c); err != nil {
+		t.Fatal(err)
+	}
+	if c != (Conflicts{Total: 4}) {
+		t.Errorf("countConflicts() = %+v，应为 4 个同名文件", c)
+	}
+	// 只统计，不移动任何内容
+	if got := readTree(t, src); !maps.Equal(got, conflictSrc) {
+		t.Errorf("统计后 src 的内容变为 %v", got)
+	}
+}
+
+// Abort 时先统计同名文件，有同名文件时目标目录保持不变，临时文件夹也被删除
+func TestRunAbort(t *testing.T) {
+	extractTo := func(files map[string]string) func(dir string) error {
+		return func(dir string) error {
+			writeTree(t, dir, files)
+			return nil
+		}
+	}
+	opts := Options{Conflict: Abort}
+
+	dest := t.TempDir()
+	writeTree(t, dest, conflictDst)
+	r, err := Run(context.Background(), dest, nil, opts, extractTo(conflictSrc))
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("Run() 返回 %v，应为 ErrConflict", err)
+	}
+	if r.Conflicts != (Conflicts{Total: 4, Skipped: 4}) || len(r.Extracted) != 0 {
+		t.Errorf("Run() = %+v，应当跳过 4 个同名文件且没有解压任何内容", r)
+	}
+	if got := readTree(t, dest); !maps.Equal(got, conflictDst) {
+		t.Errorf("目标目录的内容变为 %v", got)
+	}
+	entries, _ := os.ReadDir(dest)
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), stagingPrefix) {
+			t.Errorf("临时文件夹 %s 没有删除", e.Name())
+		}
+	}
+
+	// 没有同名文件时照常合并
+	r, err = Run(context.Background(), dest, nil, opts, extractTo(map[string]string{"other/file": "f"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readTree(t, dest)["other/file"]; got != "f" || r.Conflicts.Total != 0 {
+		t.Errorf("没有同名文件时 Run() = %+v，解压出的内容为 %q", r, got)
+	}
+}
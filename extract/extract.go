@@ -0,0 +1,99 @@
+package extract
+
+import (
+	"ArchiveTools/cracker"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Options 控制解压到目标位置的方式
+type Options struct {
+	Conflict Policy       // 解压出的文件与已有文件同名时的处理方式
+	Unsafe   UnsafePolicy // 压缩包中存在不安全路径时的处理方式
+	Space    SpacePolicy  // 目标磁盘的剩余空间不足时的处理方式
+	// MaxRatio 是标记为可能的压缩炸弹的压缩比，0 表示不检查。
+	// 整体的压缩比需要压缩包文件的大小，由调用方使用 Bombs 检查
+	MaxRatio float64
+}
+
+// Result 是一次解压的结果
+type Result struct {
+	Conflicts Conflicts
+	Unsafe    []Issue  // 不安全的条目，以及解压后删除的链接和文件
+	Extracted []string // 解压出的内容在目标位置的路径，新建的文件夹只记录文件夹本身
+	NoSpace   error    // 按 SpaceWarn 的设置在剩余空间不足时照常解压，记录空间不足的说明
+}
+
+// stagingPrefix 是临时文件夹的名称前缀，临时文件夹建在目标目录中，移动文件时不需要跨磁盘复制
+const stagingPrefix = ".archivetools-"
+
+// Run 调用 extract 把压缩包解压到 dest。entries 是压缩包的文件列表，用于在解压前检查不安全的路径。
+//
+// dest 不存在时直接解压到 dest，不会有冲突；dest 已存在时先解压到 dest 中的临时文件夹，
+// 再把其中的内容合并到 dest，同名的文件夹会逐层合并，同名的文件按 opts.Conflict 处理。
+// 解压前按 opts.Space 检查目标磁盘的剩余空间，解压后删除指向目标目录之外的链接，并确认没有文件被写到目标目录之外。
+// 解压出错时，如果 ctx 已被取消则丢弃临时文件夹中的内容，否则仍然合并已经解压出的文件，与直接解压的行为一致
+func Run(ctx context.Context, dest string, entries []cracker.Entry, opts Options, extract func(dir string) error) (Result, error) {
+	var r Result
+	r.Unsafe = Check(entries)
+	if len(r.Unsafe) > 0 && opts.Unsafe == Refuse {
+		return r, fmt.Errorf("%w，没有解压: %s", ErrUnsafe, formatIssues(r.Unsafe, 3))
+	}
+	if opts.Space != SpaceOff {
+		size, _ := Size(entries)
+		if err := checkSpace(dest, size); err != nil {
+			if opts.Space == SpaceRefuse {
+				return r, fmt.Errorf("%w，没有解压", err)
+			}
+			r.NoSpace = err
+		}
+	}
+
+	dir := dest
+	if _, err := os.Stat(dest); !errors.Is(err, os.ErrNotExist) {
+		staging, err := os.MkdirTemp(dest, stagingPrefix)
+		if err != nil {
+			return r, fmt.Errorf("无法创建临时文件夹: %w", err)
+		}
+		defer os.RemoveAll(staging)
+		dir = staging
+	}
+
+	targets := escapeTargets(dir, dest, entries)
+	extractErr := extract(dir)
+	if escaped := checkEscaped(targets); len(escaped) > 0 {
+		r.Unsafe = append(r.Unsafe, escaped...)
+		return r, fmt.Errorf("%w: %s", ErrEscaped, formatIssues(escaped, 3))
+	}
+	if extractErr != nil && ctx.Err() != nil {
+		return r, extractErr
+	}
+	links, escaped, err := removeUnsafeLinks(dir, dest, entries, targets)
+	r.Unsafe = append(append(r.Unsafe, links...), escaped...)
+	if err != nil {
+		return r, fmt.Errorf("检查解压出的链接失败: %w", err)
+	}
+	if len(escaped) > 0 {
+		return r, fmt.Errorf("%w: %s", ErrEscaped, formatIssues(escaped, 3))
+	}
+	if dir == dest {
+		r.Extracted = []string{dest}
+		return r, extractErr
+	}
+
+	if opts.Conflict == Abort {
+		if err := countConflicts(dir, dest, &r.Conflicts); err != nil {
+			return r, err
+		}
+		if r.Conflicts.Total > 0 {
+			r.Conflicts.Skipped = r.Conflicts.Total
+			return r, fmt.Errorf("%w (%d 个)，没有解压", ErrConflict, r.Conflicts.Total)
+		}
+	}
+	if err := merge(dir, dest, opts.Conflict, &r); err != nil {
+		return r, err
+	}
+	return r, extractErr
+}
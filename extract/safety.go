@@ -0,0 +1,324 @@
+package extract
+
+import (
+	"ArchiveTools/cracker"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// --- 不安全的路径 ---
+//
+// 恶意构造的压缩包可以在条目路径中使用 ".."、绝对路径或盘符，或者放入指向目标目录之外的链接，
+// 把文件写到目标目录之外 (zip-slip)。解压前根据文件列表检查每个条目，解压后再确认没有文件被写到目标目录之外。
+
+// UnsafePolicy 决定压缩包中存在不安全路径时的处理方式
+type UnsafePolicy int
+
+const (
+	Refuse   UnsafePolicy = iota // 不解压整个压缩包
+	Sanitize                     // 照常解压，由 7z 去掉路径中的 ".." 和盘符，并删除指向目标目录之外的链接
+)
+
+var unsafePolicyNames = []string{"refuse", "sanitize"}
+
+// ParseUnsafePolicy 解析命令行或配置文件中的不安全路径处理方式
+func ParseUnsafePolicy(name string) (UnsafePolicy, error) {
+	for i, n := range unsafePolicyNames {
+		if strings.EqualFold(name, n) {
+			return UnsafePolicy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("无效的不安全路径处理方式: %s，可选 %s", name, strings.Join(unsafePolicyNames, "、"))
+}
+
+// String 返回命令行中使用的名称
+func (p UnsafePolicy) String() string {
+	if p < 0 || int(p) >= len(unsafePolicyNames) {
+		return fmt.Sprintf("UnsafePolicy(%d)", int(p))
+	}
+	return unsafePolicyNames[p]
+}
+
+// Label 返回显示给用户的名称
+func (p UnsafePolicy) Label() string {
+	if p == Sanitize {
+		return "清理后解压"
+	}
+	return "拒绝解压"
+}
+
+var (
+	// ErrUnsafe 表示压缩包中有不安全的路径，按 Refuse 的设置没有解压
+	ErrUnsafe = errors.New("压缩包中有不安全的路径")
+	// ErrEscaped 表示解压后发现有文件被写到了目标目录之外
+	ErrEscaped = errors.New("有文件被解压到了目标目录之外")
+)
+
+// Issue 是一个不安全的条目
+type Issue struct {
+	Path   string // 压缩包中的路径，或解压后相对于目标目录的路径
+	Reason string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s (%s)", i.Path, i.Reason)
+}
+
+// formatIssues 返回最多 limit 个问题的说明
+func formatIssues(issues []Issue, limit int) string {
+	parts := make([]string, 0, limit+1)
+	for _, issue := range issues[:min(len(issues), limit)] {
+		parts = append(parts, issue.String())
+	}
+	if len(issues) > limit {
+		parts = append(parts, fmt.Sprintf("等 %d 个", len(issues)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// windowsDevices 是 Windows 保留的设备名，不区分大小写，带扩展名时同样无法作为文件名
+var windowsDevices = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// Check 根据文件列表检查压缩包中不安全的条目：绝对路径、盘符和网络路径、跳出目标目录的 ".."、
+// Windows 设备名、经过链接的路径，以及指向目标目录之外的链接
+func Check(entries []cracker.Entry) []Issue {
+	links := make(map[string]bool)
+	for _, e := range entries {
+		if e.Link != "" {
+			links[path.Clean(toSlash(e.Path))] = true
+		}
+	}
+
+	var issues []Issue
+	for _, e := range entries {
+		if reason := checkEntry(e, links); reason != "" {
+			issues = append(issues, Issue{Path: e.Path, Reason: reason})
+		}
+	}
+	return issues
+}
+
+func checkEntry(e cracker.Entry, links map[string]bool) string {
+	p := toSlash(e.Path)
+	switch {
+	case isAbsolute(p):
+		return "绝对路径"
+	case escapes(p):
+		return "路径跳出目标目录"
+	}
+	if runtime.GOOS == "windows" {
+		// 在其他系统上 aux.c 这类文件名没有问题，只在 Windows 上检查
+		for _, name := range strings.Split(p, "/") {
+			base, _, _ := strings.Cut(strings.TrimRight(name, " ."), ".")
+			if windowsDevices[strings.ToUpper(base)] {
+				return "Windows 设备名"
+			}
+		}
+	}
+	// 先解压一个指向外部的链接，再通过它写入文件，同样可以跳出目标目录
+	cleaned := path.Clean(p)
+	for dir := path.Dir(cleaned); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if links[dir] {
+			return "路径经过链接 " + dir
+		}
+	}
+	if e.Link != "" {
+		target := toSlash(e.Link)
+		if isAbsolute(target) {
+			return "链接指向绝对路径 " + e.Link
+		}
+		if escapes(path.Join(path.Dir(cleaned), target)) {
+			return "链接指向目标目录之外 " + e.Link
+		}
+	}
+	return ""
+}
+
+// toSlash 统一使用 / 分隔路径。压缩包可能在其他系统上制作，\ 在 Windows 上同样是分隔符
+func toSlash(p string) string {
+	return strings.ReplaceAll(p, `\`, "/")
+}
+
+// isAbsolute 判断使用 / 分隔的路径是否为绝对路径、带盘符的路径 (包括 "C:a" 这种相对于盘符当前目录的路径) 或网络路径
+func isAbsolute(p string) bool {
+	if strings.HasPrefix(p, "/") {
+		return true
+	}
+	return len(p) >= 2 && p[1] == ':' && ('a' <= p[0]|0x20 && p[0]|0x20 <= 'z')
+}
+
+// escapes 判断相对路径在去掉 "." 和 ".." 后是否跳出了目标目录
+func escapes(p string) bool {
+	cleaned := path.Clean(p)
+	return cleaned == ".." || strings.HasPrefix(cleaned, "../")
+}
+
+// fileState 是解压前目标目录之外某个路径的状态，用于确认解压没有写入该位置
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func statFile(p string) fileState {
+	info, err := os.Lstat(p)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
+
+func (s fileState) same(o fileState) bool {
+	return s.exists == o.exists && s.size == o.size && s.modTime.Equal(o.modTime)
+}
+
+// escapeTargets 返回不安全的条目在 7z 不做处理时会被写入的位置及其当前状态。
+// 位置按最终的目标目录 dest 计算，dest 已存在时实际解压到其中的临时文件夹 dir，因此同时按 dir 计算；
+// 只记录 dest 之外的位置，dest 中的同名文件可能是同时解压到这里的其他压缩包写入的，不能当作跳出目标目录。
+// 经过链接的条目按文件列表中链接的目标计算，文件列表中没有链接目标的由 removeUnsafeLinks 在解压后检查
+func escapeTargets(dir, dest string, entries []cracker.Entry) map[string]fileState {
+	links := make(map[string]string)
+	for _, e := range entries {
+		if e.Link != "" {
+			links[path.Clean(toSlash(e.Path))] = toSlash(e.Link)
+		}
+	}
+
+	targets := make(map[string]fileState)
+	for _, e := range entries {
+		p, ok := escapeTarget(toSlash(e.Path), links, 0)
+		if !ok {
+			continue
+		}
+		for _, root := range []string{dest, dir} {
+			target := filepath.FromSlash(p)
+			if !isAbsolute(p) {
+				target = filepath.Join(root, target)
+			}
+			if !within(dest, target) {
+				targets[target] = statFile(target)
+			}
+		}
+	}
+	return targets
+}
+
+// maxLinkDepth 是解析链接的最大层数，避免链接互相指向时无限循环
+const maxLinkDepth = 8
+
+// escapeTarget 返回条目跳出目标目录时，相对于目标目录 (或绝对) 的写入位置，使用 / 分隔。
+// links 是文件列表中链接的路径及其目标，路径经过其中的链接时按链接的目标继续解析
+func escapeTarget(p string, links map[string]string, depth int) (string, bool) {
+	if isAbsolute(p) {
+		return p, true
+	}
+	cleaned := path.Clean(p)
+	if escapes(cleaned) {
+		return cleaned, true
+	}
+	if depth >= maxLinkDepth {
+		return "", false
+	}
+	parts := strings.Split(cleaned, "/")
+	for i := 1; i < len(parts); i++ {
+		dir := strings.Join(parts[:i], "/")
+		target, ok := links[dir]
+		if !ok {
+			continue
+		}
+		rest := strings.Join(parts[i:], "/")
+		if isAbsolute(target) {
+			return path.Join(target, rest), true
+		}
+		return escapeTarget(path.Join(path.Dir(dir), target, rest), links, depth+1)
+	}
+	return "", false
+}
+
+// within 判断 p 是否为 root 或位于 root 之中
+func within(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	return err == nil && !escapes(filepath.ToSlash(rel))
+}
+
+// checkEscaped 确认 escapeTargets 记录的位置在解压后没有变化。
+// 解压前不存在、解压后出现的文件会被删除；已有的文件被修改时无法恢复，只报告问题
+func checkEscaped(targets map[string]fileState) []Issue {
+	var issues []Issue
+	for target, before := range targets {
+		after := statFile(target)
+		switch {
+		case !after.exists || after.same(before):
+		case !before.exists:
+			os.RemoveAll(target)
+			issues = append(issues, Issue{Path: target, Reason: "已删除"})
+		default:
+			issues = append(issues, Issue{Path: target, Reason: "已有的文件被修改"})
+		}
+	}
+	return issues
+}
+
+// removeUnsafeLinks 按 Lstat 遍历解压出的 root，删除其中指向 root 之外的符号链接。
+// 部分格式 (例如 ZIP) 的文件列表中没有链接的目标，只能在解压后检查。
+// 文件列表中有条目经过被删除的链接时，它可能已经被写到链接的目标中：
+// 写入位置在 dest 之外、又不在 known (解压前已记录状态) 中时，无法确认是否为解压写入的，作为 escaped 返回
+func removeUnsafeLinks(root, dest string, entries []cracker.Entry, known map[string]fileState) (removed, escaped []Issue, err error) {
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.Type()&fs.ModeSymlink == 0 {
+			return err
+		}
+		target, err := os.Readlink(p)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		reason := ""
+		if filepath.IsAbs(target) || filepath.VolumeName(target) != "" {
+			reason = "链接指向绝对路径 " + target
+		} else if r, err := filepath.Rel(root, filepath.Join(filepath.Dir(p), target)); err != nil || escapes(filepath.ToSlash(r)) {
+			reason = "链接指向目标目录之外 " + target
+		}
+		if reason == "" {
+			return nil
+		}
+		escaped = append(escaped, linkedWrites(p, filepath.ToSlash(rel), target, dest, entries, known)...)
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		removed = append(removed, Issue{Path: filepath.ToSlash(rel), Reason: reason + "，已删除"})
+		return nil
+	})
+	return removed, escaped, err
+}
+
+// linkedWrites 返回经过链接 link (相对路径为 rel，指向 target) 写到 dest 之外、解压后确实存在的条目
+func linkedWrites(link, rel, target, dest string, entries []cracker.Entry, known map[string]fileState) []Issue {
+	if !filepath.IsAbs(target) && filepath.VolumeName(target) == "" {
+		target = filepath.Join(filepath.Dir(link), target)
+	}
+	var issues []Issue
+	for _, e := range entries {
+		rest, ok := strings.CutPrefix(path.Clean(toSlash(e.Path)), rel+"/")
+		if !ok {
+			continue
+		}
+		written := filepath.Join(target, filepath.FromSlash(rest))
+		if _, ok := known[written]; ok || within(dest, written) || !statFile(written).exists {
+			continue
+		}
+		issues = append(issues, Issue{Path: written, Reason: "可能经过链接 " + rel + " 写入，无法确认"})
+	}
+	return issues
+}
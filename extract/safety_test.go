@@ -0,0 +1,313 @@
+package extract
+
+import (
+	"ArchiveTools/cracker"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestIsAbsolute(t *testing.T) {
+	tests := map[string]bool{
+		"/etc/passwd":       true,
+		"//server/share/x":  true,
+		"C:/Windows":        true,
+		"c:relative":        true,
+		"a/b":               false,
+		"./a":               false,
+		"1:/x":              false,
+		"":                  false,
+		"a:b/../../c":       true,
+		"dir/C:/not-a-root": false,
+	}
+	for p, want := range tests {
+		if got := isAbsolute(p); got != want {
+			t.Errorf("isAbsolute(%q) = %v，应为 %v", p, got, want)
+		}
+	}
+}
+
+func TestEscapes(t *testing.T) {
+	tests := map[string]bool{
+		"..":        true,
+		"../x":      true,
+		"a/../../x": true,
+		"./../x":    true,
+		"a/../b":    false,
+		"a/..":      false,
+		"..x/y":     false,
+		"a/b..":     false,
+		".":         false,
+	}
+	for p, want := range tests {
+		if got := escapes(p); got != want {
+			t.Errorf("escapes(%q) = %v，应为 %v", p, got, want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	entries := []cracker.Entry{
+		{Path: "a/b.txt"},
+		{Path: "a/../b.txt"},
+		{Path: "/etc/passwd"},
+		{Path: "C:/Windows/x.dll"},
+		{Path: `\\server\share\x`},
+		{Path: "../x"},
+		{Path: `a\..\..\x`},
+		{Path: "link", Link: "/etc"},
+		{Path: "link/cron", Size: 10},
+		{Path: "up", Link: "../outside"},
+		{Path: "d/inside", Link: "../a/b.txt"},
+		{Path: "d/inside/x"},
+	}
+	want := []Issue{
+		{Path: "/etc/passwd", Reason: "绝对路径"},
+		{Path: "C:/Windows/x.dll", Reason: "绝对路径"},
+		{Path: `\\server\share\x`, Reason: "绝对路径"},
+		{Path: "../x", Reason: "路径跳出目标目录"},
+		{Path: `a\..\..\x`, Reason: "路径跳出目标目录"},
+		{Path: "link", Reason: "链接指向绝对路径 /etc"},
+		{Path: "link/cron", Reason: "路径经过链接 link"},
+		{Path: "up", Reason: "链接指向目标目录之外 ../outside"},
+		{Path: "d/inside/x", Reason: "路径经过链接 d/inside"},
+	}
+	if runtime.GOOS == "windows" {
+		entries = append(entries, cracker.Entry{Path: "dir/aux.c"})
+		want = append(want, Issue{Path: "dir/aux.c", Reason: "Windows 设备名"})
+	}
+	if got := Check(entries); !slices.Equal(got, want) {
+		t.Errorf("Check() = %v\n应为 %v", got, want)
+	}
+}
+
+func TestEscapeTarget(t *testing.T) {
+	links := map[string]string{
+		"abs":     "/etc",
+		"up":      "../../outside",
+		"a":       "b",
+		"b":       "../../chain",
+		"inside":  "sub",
+		"loop1":   "loop2",
+		"loop2":   "loop1",
+		"dir/rel": "../x",
+	}
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"plain/file", "", false},
+		{"/abs/file", "/abs/file", true},
+		{"../x", "../x", true},
+		{"abs/cron", "/etc/cron", true},
+		{"up/f", "../../outside/f", true},
+		{"a/f", "../../chain/f", true},
+		{"inside/f", "", false},
+		{"loop1/f", "", false},
+		{"dir/rel/f", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := escapeTarget(tt.path, links, 0)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("escapeTarget(%q) = %q, %v，应为 %q, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// 位置按最终的目标目录计算，临时文件夹中的 "../x" 只会写到目标目录中，不应记录
+func TestEscapeTargets(t *testing.T) {
+	parent := t.TempDir()
+	dest := filepath.Join(parent, "out")
+	dir := filepath.Join(dest, stagingPrefix+"1")
+	abs := filepath.Join(parent, "abs")
+
+	entries := []cracker.Entry{
+		{Path: "ok.txt"},
+		{Path: "../x"},
+		{Path: "../../y"},
+		{Path: "link", Link: filepath.ToSlash(abs)},
+		{Path: "link/z"},
+	}
+	got := escapeTargets(dir, dest, entries)
+	var keys []string
+	for k := range got {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	want := []string{
+		filepath.Join(parent, "x"),
+		filepath.Join(parent, "y"),
+		filepath.Join(abs, "z"),
+		filepath.Join(filepath.Dir(parent), "y"),
+	}
+	sort.Strings(want)
+	if !slices.Equal(keys, want) {
+		t.Errorf("escapeTargets() = %q\n应为 %q", keys, want)
+	}
+	if got := escapeTargets(dest, dest, entries[:2]); len(got) != 1 {
+		t.Errorf("直接解压时 escapeTargets() = %v，应只有 %s", got, filepath.Join(parent, "x"))
+	}
+}
+
+func TestCheckEscaped(t *testing.T) {
+	dir := t.TempDir()
+	p := func(name string) string { return filepath.Join(dir, name) }
+	write := func(name, content string) {
+		if err := os.WriteFile(p(name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("unchanged", "a")
+	write("modified", "a")
+	targets := make(map[string]fileState)
+	for _, name := range []string{"created", "unchanged", "modified", "absent"} {
+		targets[p(name)] = statFile(p(name))
+	}
+
+	write("created", "new")
+	write("modified", "longer")
+	issues := checkEscaped(targets)
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Path < issues[j].Path })
+	want := []Issue{
+		{Path: p("created"), Reason: "已删除"},
+		{Path: p("modified"), Reason: "已有的文件被修改"},
+	}
+	if !slices.Equal(issues, want) {
+		t.Errorf("checkEscaped() = %v，应为 %v", issues, want)
+	}
+	if _, err := os.Stat(p("created")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("解压后出现的文件应当被删除")
+	}
+	if _, err := os.Stat(p("modified")); err != nil {
+		t.Error("已有的文件不应被删除")
+	}
+}
+
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("无法创建符号链接: %v", err)
+	}
+}
+
+func TestRemoveUnsafeLinks(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "out")
+	outside := filepath.Join(parent, "outside")
+	if err := os.MkdirAll(outside, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "written"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	symlink(t, "a.txt", filepath.Join(root, "ok"))
+	symlink(t, "../a.txt", filepath.Join(root, "sub", "up"))
+	symlink(t, outside, filepath.Join(root, "abs"))
+	symlink(t, "../outside", filepath.Join(root, "rel"))
+
+	// ZIP 的文件列表中没有链接的目标，只能在解压后发现经过链接写入的文件
+	entries := []cracker.Entry{
+		{Path: "abs"},
+		{Path: "abs/written"},
+		{Path: "rel/missing"},
+		{Path: "rel/known"},
+	}
+	known := map[string]fileState{filepath.Join(outside, "known"): {}}
+	if err := os.WriteFile(filepath.Join(outside, "known"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, escaped, err := removeUnsafeLinks(root, root, entries, known)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRemoved := []Issue{
+		{Path: "abs", Reason: "链接指向绝对路径 " + outside + "，已删除"},
+		{Path: "rel", Reason: "链接指向目标目录之外 ../outside，已删除"},
+	}
+	if !slices.Equal(removed, wantRemoved) {
+		t.Errorf("删除的链接为 %v，应为 %v", removed, wantRemoved)
+	}
+	wantEscaped := []Issue{{Path: filepath.Join(outside, "written"), Reason: "可能经过链接 abs 写入，无法确认"}}
+	if !slices.Equal(escaped, wantEscaped) {
+		t.Errorf("写到目标目录之外的文件为 %v，应为 %v", escaped, wantEscaped)
+	}
+	for name, exists := range map[string]bool{"ok": true, "sub/up": true, "abs": false, "rel": false} {
+		if _, err := os.Lstat(filepath.Join(root, filepath.FromSlash(name))); (err == nil) != exists {
+			t.Errorf("%s 存在: %v，应为 %v", name, err == nil, exists)
+		}
+	}
+}
+
+func TestRunEscaped(t *testing.T) {
+	parent := t.TempDir()
+	dest := filepath.Join(parent, "out")
+	outside := filepath.Join(parent, "outside")
+	for _, d := range []string{dest, outside} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	create := func(p string) {
+		if err := os.WriteFile(p, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := Options{Unsafe: Sanitize}
+	ctx := context.Background()
+
+	t.Run("目标目录中新出现的同名文件不受影响", func(t *testing.T) {
+		entries := []cracker.Entry{{Path: "../x"}}
+		_, err := Run(ctx, dest, entries, opts, func(dir string) error {
+			create(filepath.Join(dest, "x")) // 例如同时解压到这里的其他压缩包
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(dest, "x")); err != nil {
+			t.Error("目标目录中的文件被删除了")
+		}
+	})
+
+	t.Run("写到目标目录之外", func(t *testing.T) {
+		entries := []cracker.Entry{{Path: "../escaped"}}
+		r, err := Run(ctx, dest, entries, opts, func(dir string) error {
+			create(filepath.Join(parent, "escaped"))
+			return nil
+		})
+		if !errors.Is(err, ErrEscaped) {
+			t.Fatalf("Run() 返回 %v，应为 ErrEscaped", err)
+		}
+		if _, err := os.Stat(filepath.Join(parent, "escaped")); !errors.Is(err, os.ErrNotExist) {
+			t.Error("写到目标目录之外的文件应当被删除")
+		}
+		if len(r.Unsafe) != 2 {
+			t.Errorf("Unsafe = %v，应为检查出的条目和删除的文件", r.Unsafe)
+		}
+	})
+
+	t.Run("经过链接写到目标目录之外", func(t *testing.T) {
+		entries := []cracker.Entry{{Path: "link", Link: filepath.ToSlash(outside)}, {Path: "link/cron"}}
+		_, err := Run(ctx, dest, entries, opts, func(dir string) error {
+			symlink(t, outside, filepath.Join(dir, "link"))
+			create(filepath.Join(dir, "link", "cron"))
+			return nil
+		})
+		if !errors.Is(err, ErrEscaped) {
+			t.Fatalf("Run() 返回 %v，应为 ErrEscaped", err)
+		}
+		if _, err := os.Stat(filepath.Join(outside, "cron")); !errors.Is(err, os.ErrNotExist) {
+			t.Error("经过链接写入的文件应当被删除")
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package extract
+
+import (
+	"ArchiveTools/cracker"
+	"ArchiveTools/utils"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// --- 磁盘空间 ---
+//
+// 解压到一半磁盘写满时会留下不完整的文件。解压前根据文件列表中解压后的大小检查目标磁盘的剩余空间，
+// 并标记压缩比异常高、可能是压缩炸弹的条目。
+
+// SpacePolicy 决定解压后的大小超过剩余空间时的处理方式
+type SpacePolicy int
+
+const (
+	SpaceWarn   SpacePolicy = iota // 给出警告，照常解压
+	SpaceRefuse                    // 不解压
+	SpaceOff                       // 不检查剩余空间
+)
+
+var spacePolicyNames = []string{"warn", "refuse", "off"}
+
+// ParseSpacePolicy 解析命令行中的磁盘空间检查方式
+func ParseSpacePolicy(name string) (SpacePolicy, error) {
+	for i, n := range spacePolicyNames {
+		if strings.EqualFold(name, n) {
+			return SpacePolicy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("无效的磁盘空间检查方式: %s，可选 %s", name, strings.Join(spacePolicyNames, "、"))
+}
+
+// String 返回命令行中使用的名称
+func (p SpacePolicy) String() string {
+	if p < 0 || int(p) >= len(spacePolicyNames) {
+		return fmt.Sprintf("SpacePolicy(%d)", int(p))
+	}
+	return spacePolicyNames[p]
+}
+
+// Label 返回显示给用户的名称
+func (p SpacePolicy) Label() string {
+	switch p {
+	case SpaceRefuse:
+		return "空间不足时不解压"
+	case SpaceOff:
+		return "不检查"
+	}
+	return "空间不足时警告"
+}
+
+// ErrNoSpace 表示目标磁盘的剩余空间不足以解压整个压缩包
+var ErrNoSpace = errors.New("磁盘空间不足")
+
+// Size 返回文件列表中所有文件解压后的总大小，known 为 false 表示有条目的大小未知，总大小只是下限
+func Size(entries []cracker.Entry) (size int64, known bool) {
+	known = true
+	for _, e := range entries {
+		switch {
+		case e.IsDir:
+		case e.Size < 0:
+			known = false
+		default:
+			size += e.Size
+		}
+	}
+	return size, known
+}
+
+// checkSpace 确认 dest 所在磁盘的剩余空间足以容纳 size 字节，无法查询剩余空间时不做判断
+func checkSpace(dest string, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+	_, free, err := utils.DiskSpace(dest)
+	if err != nil || uint64(size) <= free {
+		return nil
+	}
+	return fmt.Errorf("%w: 需要 %s，剩余 %s", ErrNoSpace, utils.FormatSize(size), utils.FormatSize(int64(free)))
+}
+
+// bombMinSize 是检查压缩比的最小解压后大小，小文件的压缩比再高也不会占用多少空间
+const bombMinSize = 1 << 20
+
+// Bombs 返回压缩比超过 limit 的条目，limit 为 0 时不检查。
+// 条目有单独的压缩后大小时逐个计算；固实压缩等没有单独大小的情况，用压缩包文件的总大小 packed 计算整体的压缩比
+func Bombs(entries []cracker.Entry, packed int64, limit float64) []Issue {
+	if limit <= 0 {
+		return nil
+	}
+	var issues []Issue
+	for _, e := range entries {
+		if e.IsDir || e.Size < bombMinSize || e.PackedSize <= 0 {
+			continue
+		}
+		if ratio := float64(e.Size) / float64(e.PackedSize); ratio > limit {
+			issues = append(issues, Issue{Path: e.Path, Reason: ratioReason(ratio, e.Size)})
+		}
+	}
+	if len(issues) > 0 || packed <= 0 {
+		return issues
+	}
+	size, _ := Size(entries)
+	if ratio := float64(size) / float64(packed); size >= bombMinSize && ratio > limit {
+		issues = append(issues, Issue{Path: "整个压缩包", Reason: ratioReason(ratio, size)})
+	}
+	return issues
+}
+
+func ratioReason(ratio float64, size int64) string {
+	return fmt.Sprintf("压缩比 %.0f:1，解压后 %s，可能是压缩炸弹", ratio, utils.FormatSize(size))
+}
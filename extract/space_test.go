@@ -0,0 +1,126 @@
+package extract
+
+import (
+	"ArchiveTools/cracker"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []cracker.Entry
+		size    int64
+		known   bool
+	}{
+		{"空列表", nil, 0, true},
+		{"普通文件", []cracker.Entry{{Size: 10}, {Size: 20}}, 30, true},
+		{"文件夹不计入", []cracker.Entry{{Size: 10}, {IsDir: true, Size: 4096}, {IsDir: true, Size: -1}}, 10, true},
+		{"大小未知", []cracker.Entry{{Size: 10}, {Size: -1}, {Size: 5}}, 15, false},
+		{"全部未知", []cracker.Entry{{Size: -1}}, 0, false},
+	}
+	for _, tt := range tests {
+		size, known := Size(tt.entries)
+		if size != tt.size || known != tt.known {
+			t.Errorf("%s: Size() = %d, %v，应为 %d, %v", tt.name, size, known, tt.size, tt.known)
+		}
+	}
+}
+
+func TestBombs(t *testing.T) {
+	const mb = 1 << 20
+	tests := []struct {
+		name    string
+		entries []cracker.Entry
+		packed  int64
+		limit   float64
+		want    []string
+	}{
+		{"不检查", []cracker.Entry{{Path: "a", Size: 1000 * mb, PackedSize: 1}}, 1, 0, nil},
+		{"逐个条目", []cracker.Entry{
+			{Path: "bomb", Size: 200 * mb, PackedSize: mb},
+			{Path: "normal", Size: 2 * mb, PackedSize: mb},
+		}, 2 * mb, 100, []string{"bomb"}},
+		{"刚好等于上限", []cracker.Entry{{Path: "a", Size: 100 * mb, PackedSize: mb}}, mb, 100, nil},
+		{"小于检查的最小大小", []cracker.Entry{{Path: "small", Size: bombMinSize - 1, PackedSize: 1}}, 1, 100, nil},
+		{"达到检查的最小大小", []cracker.Entry{{Path: "a", Size: bombMinSize, PackedSize: 1}}, 1, 100, []string{"a"}},
+		{"文件夹不检查", []cracker.Entry{{Path: "d", IsDir: true, Size: 100 * mb, PackedSize: 1}}, 0, 100, nil},
+		{"固实压缩按整体计算", []cracker.Entry{
+			{Path: "a", Size: 60 * mb, PackedSize: 0},
+			{Path: "b", Size: 60 * mb, PackedSize: -1},
+		}, mb, 100, []string{"整个压缩包"}},
+		{"整体压缩比正常", []cracker.Entry{{Path: "a", Size: 60 * mb}, {Path: "b", Size: 60 * mb}}, 10 * mb, 100, nil},
+		{"整体小于检查的最小大小", []cracker.Entry{{Path: "a", Size: bombMinSize - 1}}, 1, 100, nil},
+		{"不知道压缩包大小", []cracker.Entry{{Path: "a", Size: 1000 * mb}}, 0, 100, nil},
+		{"已有条目超出时不再计算整体", []cracker.Entry{
+			{Path: "bomb", Size: 200 * mb, PackedSize: mb},
+			{Path: "solid", Size: 1000 * mb},
+		}, mb, 100, []string{"bomb"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, issue := range Bombs(tt.entries, tt.packed, tt.limit) {
+			got = append(got, issue.Path)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Bombs() = %v，应为 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckSpace(t *testing.T) {
+	dir := t.TempDir()
+	if err := checkSpace(dir, 0); err != nil {
+		t.Errorf("大小为 0 时 checkSpace() = %v", err)
+	}
+	if err := checkSpace(filepath.Join(dir, "new", "dest"), 1); err != nil {
+		t.Errorf("不存在的目标目录按上级文件夹检查，checkSpace() = %v", err)
+	}
+	if err := checkSpace(dir, 1<<62); !errors.Is(err, ErrNoSpace) {
+		t.Errorf("checkSpace() = %v，应为 ErrNoSpace", err)
+	}
+}
+
+// 剩余空间不足时，SpaceRefuse 不调用 extract，SpaceWarn 照常解压并记录原因，SpaceOff 不检查
+func TestRunSpacePolicy(t *testing.T) {
+	entries := []cracker.Entry{{Path: "huge", Size: 1 << 62}}
+	tests := []struct {
+		policy  SpacePolicy
+		called  bool
+		noSpace bool
+	}{
+		{SpaceRefuse, false, false},
+		{SpaceWarn, true, true},
+		{SpaceOff, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "out")
+			called := false
+			r, err := Run(context.Background(), dest, entries, Options{Space: tt.policy}, func(dir string) error {
+				called = true
+				return os.MkdirAll(dir, 0755)
+			})
+			if called != tt.called {
+				t.Errorf("extract 被调用: %v，应为 %v", called, tt.called)
+			}
+			if (r.NoSpace != nil) != tt.noSpace {
+				t.Errorf("NoSpace = %v", r.NoSpace)
+			}
+			if tt.policy == SpaceRefuse {
+				if !errors.Is(err, ErrNoSpace) {
+					t.Errorf("Run() 返回 %v，应为 ErrNoSpace", err)
+				}
+				if _, err := os.Stat(dest); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("拒绝解压时不应创建目标目录: %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("Run() 返回 %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package format
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"os"
+)
+
+// signature 是出现在文件固定位置的特征字节
+type signature struct {
+	offset int64
+	magic  []byte
+	format *Format
+}
+
+var signatures = []signature{
+	{0, []byte("PK\x03\x04"), ZIP},
+	{0, []byte("PK\x05\x06"), ZIP}, // 空的 ZIP
+	{0, []byte("PK\x07\x08"), ZIP}, // 分卷 ZIP 的第一个分卷
+	{0, []byte("Rar!\x1a\x07\x00"), RAR},
+	{0, []byte("Rar!\x1a\x07\x01\x00"), RAR},
+	{0, []byte("7z\xbc\xaf\x27\x1c"), SevenZip},
+	{0, []byte("\x1f\x8b\x08"), Gzip},
+	{0, []byte("BZh"), Bzip2},
+	{0, []byte("\xfd7zXZ\x00"), Xz},
+	{0, []byte("\x28\xb5\x2f\xfd"), Zstd},
+	{0, []byte("MSCF\x00\x00\x00\x00"), CAB},
+	{257, []byte("ustar"), Tar},
+	{0x8001, []byte("CD001"), ISO},
+}
+
+// tarMagicOffset 是 tar 头中 "ustar" 标记的位置
+const tarMagicOffset = 257
+
+// DetectFile 读取文件开头的特征字节识别格式，无法识别时返回 nil
+func DetectFile(path string) (*Format, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Detect(f, path), nil
+}
+
+// Detect 根据特征字节识别格式，name 仅用于区分 xz/zstd 压缩的是否为 tar 包
+func Detect(r io.ReaderAt, name string) *Format {
+	for _, sig := range signatures {
+		buf := make([]byte, len(sig.magic))
+		if _, err := r.ReadAt(buf, sig.offset); err != nil || !bytes.Equal(buf, sig.magic) {
+			continue
+		}
+		return refineTar(r, name, sig.format)
+	}
+	return nil
+}
+
+// refineTar 判断 gzip/bzip2/xz/zstd 压缩的内容是否为 tar 包
+// gzip 和 bzip2 直接解压开头检查 tar 头，标准库不支持的 xz/zstd 只能依据扩展名
+func refineTar(r io.ReaderAt, name string, f *Format) *Format {
+	var tarFormat *Format
+	var open func(io.Reader) (io.Reader, error)
+	switch f {
+	case Gzip:
+		tarFormat = TarGz
+		open = func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }
+	case Bzip2:
+		tarFormat = TarBz2
+		open = func(r io.Reader) (io.Reader, error) { return bzip2.NewReader(r), nil }
+	case Xz, Zstd:
+		if found, _ := Lookup(name); found != nil && found.Tar {
+			return found
+		}
+		return f
+	default:
+		return f
+	}
+
+	dr, err := open(io.NewSectionReader(r, 0, 1<<62))
+	if err != nil {
+		return f
+	}
+	header := make([]byte, tarMagicOffset+5)
+	if _, err := io.ReadFull(dr, header); err != nil {
+		return f
+	}
+	if string(header[tarMagicOffset:]) == "ustar" {
+		return tarFormat
+	}
+	return f
+}
@@ -0,0 +1,119 @@
+package format
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func tarData(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	content := []byte("hello\n")
+	if err := tw.WriteHeader(&tar.Header{Name: "hello.txt", Mode: 0644, Size: int64(len(content)), Format: tar.FormatUSTAR}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(content)
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func gzipData(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func readTestData(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// at 返回在 offset 处放置 magic、总长度为 size 的数据
+func at(offset int, magic string, size int) []byte {
+	buf := make([]byte, max(size, offset+len(magic)))
+	copy(buf[offset:], magic)
+	return buf
+}
+
+func TestDetect(t *testing.T) {
+	text := []byte(strings.Repeat("hello world\n", 100))
+	tests := []struct {
+		name string
+		file string // 用于区分 xz/zstd 压缩的是否为 tar 包
+		data []byte
+		want *Format
+	}{
+		{"ZIP", "", at(0, "PK\x03\x04", 64), ZIP},
+		{"空的 ZIP", "", at(0, "PK\x05\x06", 22), ZIP},
+		{"分卷 ZIP", "", at(0, "PK\x07\x08PK\x03\x04", 64), ZIP},
+		{"RAR4", "", at(0, "Rar!\x1a\x07\x00", 64), RAR},
+		{"RAR5", "", at(0, "Rar!\x1a\x07\x01\x00", 64), RAR},
+		{"7z", "", at(0, "7z\xbc\xaf\x27\x1c\x00\x04", 64), SevenZip},
+		{"gzip", "", gzipData(t, text), Gzip},
+		{"gzip 压缩的 tar", "", gzipData(t, tarData(t)), TarGz},
+		{"损坏的 gzip", "", at(0, "\x1f\x8b\x08", 64), Gzip},
+		{"bzip2", "", readTestData(t, "hello.txt.bz2"), Bzip2},
+		{"bzip2 压缩的 tar", "", readTestData(t, "hello.tar.bz2"), TarBz2},
+		{"损坏的 bzip2", "", at(0, "BZh9", 64), Bzip2},
+		{"xz", "a.xz", at(0, "\xfd7zXZ\x00", 64), Xz},
+		{"xz 压缩的 tar", "a.tar.xz", at(0, "\xfd7zXZ\x00", 64), TarXz},
+		{"xz 压缩的 tar (.txz)", "a.TXZ", at(0, "\xfd7zXZ\x00", 64), TarXz},
+		{"扩展名错误的 xz", "a.zip", at(0, "\xfd7zXZ\x00", 64), Xz},
+		{"zstd", "a.zst", at(0, "\x28\xb5\x2f\xfd", 64), Zstd},
+		{"zstd 压缩的 tar", "a.tar.zst", at(0, "\x28\xb5\x2f\xfd", 64), TarZst},
+		{"CAB", "", at(0, "MSCF\x00\x00\x00\x00", 64), CAB},
+		{"tar", "", tarData(t), Tar},
+		{"ISO", "", at(0x8001, "CD001", 0x8800), ISO},
+		{"空文件", "", nil, nil},
+		{"只有 ZIP 标记的一部分", "", []byte("PK\x03"), nil},
+		{"只有 RAR 标记的一部分", "", []byte("Rar!\x1a\x07"), nil},
+		{"tar 标记之前的内容不足", "", at(250, "us", 256), nil},
+		{"ISO 标记之前的内容不足", "", make([]byte, 0x8000), nil},
+		{"普通文本", "", text, nil},
+		{"未知格式", "", at(0, "MZ\x90\x00", 512), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect(bytes.NewReader(tt.data), tt.file); got != tt.want {
+				t.Errorf("Detect() = %v，应为 %v", formatName(got), formatName(tt.want))
+			}
+		})
+	}
+}
+
+func formatName(f *Format) string {
+	if f == nil {
+		return "nil"
+	}
+	return f.Name
+}
+
+func TestDetectFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.bin")
+	if err := os.WriteFile(path, gzipData(t, tarData(t)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if f, err := DetectFile(path); err != nil || f != TarGz {
+		t.Errorf("DetectFile() = %v, %v，应为 tar.gz", formatName(f), err)
+	}
+	if _, err := DetectFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("文件不存在时应当返回错误")
+	}
+}
@@ -0,0 +1,60 @@
+package format
+
+import "strings"
+
+// Format 描述一种 7z 能够处理的压缩格式
+type Format struct {
+	Name        string   // 显示名称
+	Extensions  []string // 对应的扩展名 (小写)，可以包含多段，例如 ".tar.gz"
+	Encryptable bool     // 是否可能带有密码，不支持密码的格式跳过匹配直接解压
+	Tar         bool     // 压缩的 tar 包，需要先解出 tar 再展开
+	Type        string   // 7z -t 参数使用的格式名，压缩的 tar 包为外层的压缩格式
+}
+
+// 所有支持的格式
+var (
+	ZIP      = &Format{Name: "ZIP", Extensions: []string{".zip"}, Encryptable: true, Type: "zip"}
+	RAR      = &Format{Name: "RAR", Extensions: []string{".rar"}, Encryptable: true, Type: "rar"}
+	SevenZip = &Format{Name: "7z", Extensions: []string{".7z"}, Encryptable: true, Type: "7z"}
+	Tar      = &Format{Name: "tar", Extensions: []string{".tar"}, Type: "tar"}
+	TarGz    = &Format{Name: "tar.gz", Extensions: []string{".tar.gz", ".tgz"}, Tar: true, Type: "gzip"}
+	TarBz2   = &Format{Name: "tar.bz2", Extensions: []string{".tar.bz2", ".tbz2", ".tbz"}, Tar: true, Type: "bzip2"}
+	TarXz    = &Format{Name: "tar.xz", Extensions: []string{".tar.xz", ".txz"}, Tar: true, Type: "xz"}
+	TarZst   = &Format{Name: "tar.zst", Extensions: []string{".tar.zst", ".tzst"}, Tar: true, Type: "zstd"}
+	Gzip     = &Format{Name: "gzip", Extensions: []string{".gz"}, Type: "gzip"}
+	Bzip2    = &Format{Name: "bzip2", Extensions: []string{".bz2"}, Type: "bzip2"}
+	Xz       = &Format{Name: "xz", Extensions: []string{".xz"}, Type: "xz"}
+	Zstd     = &Format{Name: "zstd", Extensions: []string{".zst"}, Type: "zstd"}
+	CAB      = &Format{Name: "CAB", Extensions: []string{".cab"}, Type: "cab"}
+	ISO      = &Format{Name: "ISO", Extensions: []string{".iso"}, Type: "iso"}
+)
+
+var registry = []*Format{ZIP, RAR, SevenZip, Tar, TarGz, TarBz2, TarXz, TarZst, Gzip, Bzip2, Xz, Zstd, CAB, ISO}
+
+// Lookup 根据文件名查找格式 (不区分大小写)，返回格式和文件名中实际的扩展名
+// 多段扩展名优先，例如 "a.tar.gz" 匹配 ".tar.gz" 而不是 ".gz"；未知格式返回 nil
+func Lookup(name string) (*Format, string) {
+	var found *Format
+	var foundLen int
+	for _, f := range registry {
+		for _, ext := range f.Extensions {
+			if len(ext) > foundLen && len(name) > len(ext) && strings.EqualFold(name[len(name)-len(ext):], ext) {
+				found, foundLen = f, len(ext)
+			}
+		}
+	}
+	if found == nil {
+		return nil, ""
+	}
+	return found, name[len(name)-foundLen:]
+}
+
+// ByName 根据显示名称查找格式，用于从保存的任务中恢复格式；未知名称返回 nil
+func ByName(name string) *Format {
+	for _, f := range registry {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
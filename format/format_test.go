@@ -0,0 +1,59 @@
+package format
+
+import "testing"
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name   string
+		format *Format
+		ext    string
+	}{
+		{"a.zip", ZIP, ".zip"},
+		{"a.7z", SevenZip, ".7z"},
+		{"a.tar", Tar, ".tar"},
+		{"a.tar.gz", TarGz, ".tar.gz"},
+		{"a.tgz", TarGz, ".tgz"},
+		{"a.tar.bz2", TarBz2, ".tar.bz2"},
+		{"a.tbz", TarBz2, ".tbz"},
+		{"a.tar.xz", TarXz, ".tar.xz"},
+		{"a.tar.zst", TarZst, ".tar.zst"},
+		{"a.tzst", TarZst, ".tzst"},
+		{"a.gz", Gzip, ".gz"},
+		{"a.zst", Zstd, ".zst"},
+		{"backup.2024.tar.gz", TarGz, ".tar.gz"},
+		{"a.txt.gz", Gzip, ".gz"},
+		// 不区分大小写，返回文件名中实际的扩展名
+		{"A.ZIP", ZIP, ".ZIP"},
+		{"a.Tar.Gz", TarGz, ".Tar.Gz"},
+		{"a.TGZ", TarGz, ".TGZ"},
+		{"a.TAR.ZST", TarZst, ".TAR.ZST"},
+		// 文件名只有扩展名时退回较短的扩展名
+		{".tar.gz", Gzip, ".gz"},
+		{".zip", nil, ""},
+		// 分卷编号由 utils 去掉后再查找
+		{"a.tar.gz.001", nil, ""},
+		{"a.zip.txt", nil, ""},
+		{"a", nil, ""},
+		{"", nil, ""},
+	}
+	for _, tt := range tests {
+		f, ext := Lookup(tt.name)
+		if f != tt.format || ext != tt.ext {
+			t.Errorf("Lookup(%q) = %v, %q，应为 %v, %q", tt.name, f, ext, tt.format, tt.ext)
+		}
+	}
+}
+
+func TestByName(t *testing.T) {
+	for _, f := range registry {
+		if got := ByName(f.Name); got != f {
+			t.Errorf("ByName(%q) = %v，应为 %v", f.Name, got, f)
+		}
+	}
+	// 名称来自保存的任务，与显示名称完全一致才能匹配
+	for _, name := range []string{"", "zip", "tar.GZ", "tgz", "unknown"} {
+		if got := ByName(name); got != nil {
+			t.Errorf("ByName(%q) = %v，应为 nil", name, got)
+		}
+	}
+}
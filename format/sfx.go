@@ -0,0 +1,180 @@
+package format
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// --- 自解压程序 (SFX) ---
+//
+// 自解压程序是在可执行文件的程序部分之后直接附加压缩包数据，
+// 因此在文件中搜索压缩包的签名，并校验紧随其后的文件头以排除程序代码中碰巧出现的相同字节。
+
+const (
+	// sfxSearchLimit 是搜索签名的范围，自解压模块本身通常不超过 1MB
+	sfxSearchLimit = 4 << 20
+	sfxChunkSize   = 64 << 10
+)
+
+// sfxCandidate 是可以嵌入自解压程序的格式
+type sfxCandidate struct {
+	magic  []byte
+	format *Format
+	valid  func(r io.ReaderAt, off, size int64) bool
+}
+
+var sfxCandidates = []sfxCandidate{
+	{[]byte("Rar!\x1a\x07\x01\x00"), RAR, validRar5},
+	{[]byte("Rar!\x1a\x07\x00"), RAR, validRar4},
+	{[]byte("7z\xbc\xaf\x27\x1c"), SevenZip, validSevenZip},
+}
+
+// DetectSFXFile 在可执行文件中查找嵌入的压缩包，返回格式和压缩包数据的起始位置
+func DetectSFXFile(path string) (*Format, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	format, offset := DetectSFX(f, info.Size())
+	return format, offset, nil
+}
+
+// DetectSFX 在 r 中查找嵌入的 RAR / 7z / ZIP 压缩包，没有找到时返回 nil
+// 出现多个时取位置最靠前的一个
+func DetectSFX(r io.ReaderAt, size int64) (*Format, int64) {
+	limit := min(size, sfxSearchLimit)
+	zipOffset := int64(-1)
+	if offset, ok := zipPayload(r, size); ok {
+		zipOffset = offset
+	}
+
+	// 相邻的块重叠一个签名的长度，避免签名跨块时被漏掉
+	buf := make([]byte, sfxChunkSize+8)
+	for pos := int64(0); pos < limit; pos += sfxChunkSize {
+		if zipOffset >= 0 && zipOffset < pos {
+			return ZIP, zipOffset
+		}
+		n, _ := r.ReadAt(buf, pos)
+		best := int64(-1)
+		var found *Format
+		for _, c := range sfxCandidates {
+			for i := 0; i < n; {
+				j := bytes.Index(buf[i:n], c.magic)
+				if j < 0 || i+j >= sfxChunkSize {
+					break
+				}
+				off := pos + int64(i+j)
+				if best >= 0 && off >= best {
+					break
+				}
+				if c.valid(r, off, size) {
+					best, found = off, c.format
+					break
+				}
+				i += j + 1
+			}
+		}
+		if found != nil {
+			if zipOffset >= 0 && zipOffset < best {
+				return ZIP, zipOffset
+			}
+			return found, best
+		}
+	}
+	if zipOffset >= 0 {
+		return ZIP, zipOffset
+	}
+	return nil, 0
+}
+
+// validSevenZip 校验 7z 签名头的 CRC，并确认文件头位于文件范围内
+func validSevenZip(r io.ReaderAt, off, size int64) bool {
+	sig := make([]byte, 32)
+	if _, err := r.ReadAt(sig, off); err != nil {
+		return false
+	}
+	if crc32.ChecksumIEEE(sig[12:32]) != binary.LittleEndian.Uint32(sig[8:12]) {
+		return false
+	}
+	nextOffset := binary.LittleEndian.Uint64(sig[12:20])
+	nextSize := binary.LittleEndian.Uint64(sig[20:28])
+	return nextOffset <= uint64(size) && nextSize <= uint64(size) &&
+		uint64(off)+32+nextOffset+nextSize <= uint64(size)
+}
+
+// validRar5 校验签名之后第一个块头的 CRC32
+func validRar5(r io.ReaderAt, off, size int64) bool {
+	prefix := make([]byte, 7) // CRC32 加最多 3 字节的块头大小
+	if _, err := r.ReadAt(prefix, off+8); err != nil {
+		return false
+	}
+	headerSize, n := binary.Uvarint(prefix[4:])
+	if n <= 0 || headerSize == 0 || headerSize > 2<<20 {
+		return false
+	}
+	header := make([]byte, n+int(headerSize))
+	if _, err := r.ReadAt(header, off+12); err != nil {
+		return false
+	}
+	return crc32.ChecksumIEEE(header) == binary.LittleEndian.Uint32(prefix[:4])
+}
+
+// validRar4 确认签名之后是 RAR4 的主块头，并校验它的 CRC
+func validRar4(r io.ReaderAt, off, size int64) bool {
+	head := make([]byte, 7)
+	if _, err := r.ReadAt(head, off+7); err != nil {
+		return false
+	}
+	headerSize := int(binary.LittleEndian.Uint16(head[5:7]))
+	if head[2] != 0x73 || headerSize < 7 {
+		return false
+	}
+	header := make([]byte, headerSize)
+	if _, err := r.ReadAt(header, off+7); err != nil {
+		return false
+	}
+	return uint16(crc32.ChecksumIEEE(header[2:])) == binary.LittleEndian.Uint16(header[:2])
+}
+
+// zipPayload 通过文件末尾的中央目录判断是否嵌入了 ZIP，并返回第一个本地文件头的位置
+func zipPayload(r io.ReaderAt, size int64) (int64, bool) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil || len(zr.File) == 0 {
+		return 0, false
+	}
+	// 中央目录中的第一个条目通常也是数据中的第一个条目，
+	// 它的本地文件头位于数据起始位置之前：30 字节固定部分加文件名和扩展字段
+	first := zr.File[0]
+	dataOffset, err := first.DataOffset()
+	if err != nil {
+		return 0, false
+	}
+	nameLen := int64(len(first.Name))
+	start := max(0, dataOffset-30-nameLen-0xffff)
+	window := make([]byte, dataOffset-start)
+	if _, err := r.ReadAt(window, start); err != nil {
+		return 0, false
+	}
+	for extra := int64(0); extra <= 0xffff; extra++ {
+		i := int64(len(window)) - 30 - nameLen - extra
+		if i < 0 {
+			break
+		}
+		header := window[i : i+30]
+		if bytes.HasPrefix(header, []byte("PK\x03\x04")) &&
+			int64(binary.LittleEndian.Uint16(header[26:28])) == nameLen &&
+			int64(binary.LittleEndian.Uint16(header[28:30])) == extra {
+			return start + i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,117 @@
+package format
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// peStub 返回 size 字节的可执行文件程序部分，其中夹杂着校验不通过的 RAR / 7z 签名，模拟程序代码中碰巧出现的字节
+func peStub(size int) []byte {
+	stub := make([]byte, size)
+	copy(stub, "MZ\x90\x00")
+	binary.LittleEndian.PutUint32(stub[0x3c:], 0x80)
+	copy(stub[0x80:], "PE\x00\x00")
+	for i := 0x200; i < size; i++ {
+		stub[i] = byte(i*7 + i>>8)
+	}
+	copy(stub[0x400:], "Rar!\x1a\x07\x01\x00\x00\x00\x00\x00\x05\x01\x00")
+	copy(stub[0x600:], "Rar!\x1a\x07\x00\x00\x00\x73\x00\x00\x0d\x00")
+	copy(stub[0x800:], "7z\xbc\xaf\x27\x1c\x00\x04\xde\xad\xbe\xef")
+	return stub
+}
+
+func rar5Payload() []byte {
+	header := []byte{3, 1, 0, 0} // 块头大小、类型 (主块)、块标志、归档标志
+	buf := []byte("Rar!\x1a\x07\x01\x00")
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.ChecksumIEEE(header))
+	buf = append(buf, header...)
+	end := []byte{3, 5, 0, 0} // 归档结束块
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.ChecksumIEEE(end))
+	return append(buf, end...)
+}
+
+func rar4Payload() []byte {
+	header := []byte{0, 0, 0x73, 0, 0, 13, 0, 0, 0, 0, 0, 0, 0} // 主块头，前两个字节为 CRC
+	binary.LittleEndian.PutUint16(header, uint16(crc32.ChecksumIEEE(header[2:])))
+	return append([]byte("Rar!\x1a\x07\x00"), header...)
+}
+
+func sevenZipPayload() []byte {
+	next := []byte{0x01, 0x00} // 文件头：Header、End
+	sig := make([]byte, 32)
+	copy(sig, "7z\xbc\xaf\x27\x1c\x00\x04")
+	binary.LittleEndian.PutUint64(sig[12:], 0)
+	binary.LittleEndian.PutUint64(sig[20:], uint64(len(next)))
+	binary.LittleEndian.PutUint32(sig[28:], crc32.ChecksumIEEE(next))
+	binary.LittleEndian.PutUint32(sig[8:], crc32.ChecksumIEEE(sig[12:32]))
+	return append(sig, next...)
+}
+
+// zipPayloadAt 返回 ZIP 数据，offset 不为 0 时中央目录中的偏移量从整个文件的开头算起 (与 zip -A 调整后相同)
+func zipPayloadAt(t *testing.T, offset int64) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	zw.SetOffset(offset)
+	for _, name := range []string{"a.txt", "dir/b.txt"} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Extra: make([]byte, 12)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte("content of " + name))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDetectSFX(t *testing.T) {
+	const stubSize = 100 << 10
+	stub := peStub(stubSize)
+	join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	tests := []struct {
+		name   string
+		data   []byte
+		format *Format
+		offset int64
+	}{
+		{"RAR5", join(stub, rar5Payload()), RAR, stubSize},
+		{"RAR4", join(stub, rar4Payload()), RAR, stubSize},
+		{"7z", join(stub, sevenZipPayload()), SevenZip, stubSize},
+		{"ZIP (偏移量相对于 ZIP 数据)", join(stub, zipPayloadAt(t, 0)), ZIP, stubSize},
+		{"ZIP (偏移量相对于整个文件)", join(stub, zipPayloadAt(t, stubSize)), ZIP, stubSize},
+		{"签名跨越搜索块的边界", join(stub[:sfxChunkSize-3], rar5Payload()), RAR, sfxChunkSize - 3},
+		{"多个压缩包时取最靠前的一个", join(stub, sevenZipPayload(), rar5Payload()), SevenZip, stubSize},
+		{"普通的可执行文件", stub, nil, 0},
+		{"7z 的文件头超出文件范围", join(stub, sevenZipPayload()[:32]), nil, 0},
+		{"空文件", nil, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, offset := DetectSFX(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if f != tt.format || offset != tt.offset {
+				t.Errorf("DetectSFX() = %v, %d，应为 %v, %d", formatName(f), offset, formatName(tt.format), tt.offset)
+			}
+		})
+	}
+}
+
+func TestDetectSFXFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "setup.exe")
+	if err := os.WriteFile(path, append(peStub(4096), rar5Payload()...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if f, offset, err := DetectSFXFile(path); err != nil || f != RAR || offset != 4096 {
+		t.Errorf("DetectSFXFile() = %v, %d, %v，应为 RAR, 4096", formatName(f), offset, err)
+	}
+	if _, _, err := DetectSFXFile(filepath.Join(t.TempDir(), "missing.exe")); err == nil {
+		t.Error("文件不存在时应当返回错误")
+	}
+}
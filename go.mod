@@ -4,7 +4,10 @@ go 1.23.0
 
 toolchain go1.23.12
 
-require github.com/pterm/pterm v0.12.40
+require (
+	github.com/pterm/pterm v0.12.40
+	golang.org/x/text v0.25.0
+)
 
 require (
 	github.com/MarvinJWendt/testza v0.4.2 // indirect
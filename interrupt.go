@@ -0,0 +1,85 @@
+package main
+
+import (
+	"ArchiveTools/display"
+	"ArchiveTools/store"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+// errInterrupted 表示压缩包因为任务被中断而没有处理完
+var errInterrupted = errors.New("任务被中断")
+
+// interruptContext 返回在收到 Ctrl-C (SIGINT) 或 SIGTERM 时取消的 context，并在取消后调用 onInterrupt。
+// 第一次收到信号后恢复默认的信号处理，再次按下 Ctrl-C 会直接结束程序。
+// 任务结束后需要调用返回的 stop，stop 返回后不会再调用 onInterrupt
+func interruptContext(onInterrupt func()) (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-signals:
+			signal.Stop(signals)
+			cancel()
+			onInterrupt()
+		case <-done:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		close(done)
+		<-exited
+		cancel()
+	}
+}
+
+// interruptedError 在任务已被中断时把 err 换成 errInterrupted。
+// 被终止的 7z 进程只会返回普通的错误 (例如 "signal: killed")，因此需要结合 ctx 判断
+func interruptedError(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil && !errors.Is(err, errInterrupted) {
+		return errInterrupted
+	}
+	return err
+}
+
+// printInterruptNotice 在收到中断信号时提示用户，需要在 board.Println 中调用
+func printInterruptNotice() {
+	display.PrintWarning("收到中断信号，正在停止进行中的任务... (再次按下 Ctrl-C 强制退出)")
+}
+
+// printInterrupted 在任务被中断时输出被中断和没有开始的压缩包，保存任务进度并提示如何继续
+func printInterrupted(opts taskOptions, session *store.Session, interrupted []string, skipped int) {
+	display.PrintFieldValue("处理中被中断", fmt.Sprintf("%d 个", len(interrupted)))
+	for _, name := range interrupted {
+		display.PrintInfo(fmt.Sprintf("  └─> %s", name))
+	}
+	display.PrintFieldValue("尚未开始", fmt.Sprintf("%d 个", skipped))
+	display.PrintSectionEnd()
+	display.PrintEmptyLine()
+
+	if session == nil {
+		display.PrintWarning("没有保存任务进度，下次需要重新开始。")
+		return
+	}
+	// 进行中的压缩包的查找进度不一定已经写回，在这里立即保存
+	if err := session.Save(); err != nil {
+		display.PrintWarning(fmt.Sprintf("无法保存任务进度: %v", err))
+		return
+	}
+	if opts.Interactive {
+		display.PrintInfo(fmt.Sprintf("任务进度已保存到 %s，重新启动程序即可从中断的位置继续。", session.Path()))
+	} else {
+		display.PrintInfo(fmt.Sprintf("任务进度已保存到 %s，使用 %s resume 即可从中断的位置继续。", session.Path(), filepath.Base(os.Args[0])))
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"ArchiveTools/extract"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInterruptedError(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	killed := errors.New("signal: killed")
+	wrapped := fmt.Errorf("%w，已丢弃未解压完的文件", errInterrupted)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want error
+	}{
+		{"没有出错", cancelled, nil, nil},
+		{"未中断时保留原错误", context.Background(), killed, killed},
+		{"中断后换成 errInterrupted", cancelled, killed, errInterrupted},
+		{"保留已有的中断说明", cancelled, wrapped, wrapped},
+	}
+	for _, tt := range tests {
+		if got := interruptedError(tt.ctx, tt.err); got != tt.want {
+			t.Errorf("%s: interruptedError() = %v，应为 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// interruptedExtract 返回模拟解压中途被中断的 extract：写入一个文件后取消 ctx 并返回 7z 被终止的错误
+func interruptedExtract(cancel context.CancelFunc) func(dir string) error {
+	return func(dir string) error {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, "partial.bin"), []byte("partial"), 0644); err != nil {
+			return err
+		}
+		cancel()
+		return errors.New("signal: killed")
+	}
+}
+
+// 解压到新建的文件夹时被中断，删除解压了一半的文件夹
+func TestExtractToInterruptedNewFolder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dest := filepath.Join(t.TempDir(), "archive")
+
+	var o extractOutcome
+	extractTo(ctx, &o, dest, nil, extract.Options{}, interruptedExtract(cancel))
+	if o.success || !errors.Is(o.err, errInterrupted) {
+		t.Fatalf("success = %v, err = %v，应为 errInterrupted", o.success, o.err)
+	}
+	if _, err := os.Stat(dest); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("未解压完的文件夹没有被删除: %v", err)
+	}
+}
+
+// 输出目录已存在时被中断，丢弃临时文件夹，目录中只保留原有的内容
+func TestExtractToInterruptedExistingFolder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dest := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dest, "keep.txt"), []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var o extractOutcome
+	extractTo(ctx, &o, dest, nil, extract.Options{}, interruptedExtract(cancel))
+	if o.success || !errors.Is(o.err, errInterrupted) {
+		t.Fatalf("success = %v, err = %v，应为 errInterrupted", o.success, o.err)
+	}
+	entries, err := os.ReadDir(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "keep.txt" {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("输出目录中的内容为 %v，应当只有 keep.txt", names)
+	}
+	if data, err := os.ReadFile(filepath.Join(dest, "keep.txt")); err != nil || string(data) != "original" {
+		t.Errorf("原有的文件被修改: %q, %v", data, err)
+	}
+}
+
+// 没有中断时的解压错误原样保留，不删除输出目录
+func TestExtractToFailed(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "archive")
+	failed := errors.New("数据错误")
+
+	var o extractOutcome
+	extractTo(context.Background(), &o, dest, nil, extract.Options{}, func(dir string) error {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		return failed
+	})
+	if o.success || !errors.Is(o.err, failed) {
+		t.Fatalf("success = %v, err = %v，应为原来的错误", o.success, o.err)
+	}
+	if _, err := os.Stat(dest); err != nil {
+		t.Errorf("没有中断时不应删除输出目录: %v", err)
+	}
+}
@@ -1,59 +1,350 @@
 package main
 
 import (
+	"ArchiveTools/candidate"
+	"ArchiveTools/config"
 	"ArchiveTools/cracker"
 	"ArchiveTools/display"
+	"ArchiveTools/extract"
+	"ArchiveTools/report"
+	"ArchiveTools/store"
 	"ArchiveTools/utils"
 	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"log"
+	"math"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
 
 const (
-	passwordsFile  = "passwords.txt"
-	resultDir      = "result"
-	processTimeout = 500 * time.Millisecond // 快速模式下的超时时间
+	defaultConfigFile    = "config.json" // 不存在时使用默认配置
+	defaultPasswordsFile = "passwords.txt"
+	defaultRulesFile     = "rules.txt" // 不存在时不使用规则
+	defaultStatsFile     = "stats.json"
+	defaultCacheFile     = "cache.json"
+	defaultResultDir     = "result"
+
+	// 估算尝试速度时的试跑时长，以及包括检测加密方式在内的总时长上限
+	speedTestDuration = time.Second
+	speedTestTimeout  = 10 * time.Second
 )
 
-// Result 用于保存破解结果
-type Result struct {
-	FilePath string
-	Password string
+// candidateSet 是一次任务使用的候选密码，每个压缩包按 forArchive 返回的顺序从头遍历
+type candidateSet struct {
+	source candidate.Source  // 密码本、规则和掩码按原始顺序连接，用于估算任务规模
+	words  []string          // 密码本中的密码，按文件中的顺序
+	rules  []candidate.Rule  // 变形规则
+	masks  []*candidate.Mask // 密码本用完后依次尝试的掩码和暴力破解
+	// 密码命中统计，为 nil 时不记录；命中过的密码排在密码本最前面，
+	// byFolder 为 true 时优先按压缩包所在文件夹中的命中次数排序
+	stats    *store.Store
+	byFolder bool
+	// 以压缩包内容为键的密码缓存，为 nil 时不使用缓存
+	// fingerprint 标识本次任务的密码本、规则和掩码，用于缓存和恢复任务
+	cache       *store.Cache
+	fingerprint string
+	label       string // 记入缓存的候选密码说明
+	// 从压缩包名称、上级文件夹、说明文件和注释中提取密码的方式，为 nil 时不提取
+	context *candidate.ContextOptions
+	// 解压嵌套的压缩包时上一层压缩包的密码，最先尝试
+	parent string
+}
+
+// chain 把密码本、变形规则和掩码依次连接成候选来源
+func (s candidateSet) chain(words []string) candidate.Source {
+	sources := []candidate.Source{candidate.WithRules(candidate.List(words), s.rules)}
+	for _, m := range s.masks {
+		sources = append(sources, m)
+	}
+	return candidate.Chain(sources...)
+}
+
+// forArchive 返回某个压缩包使用的候选密码：先尝试上一层压缩包的密码和从它的上下文中提取的密码，
+// 再遍历密码本 (命中过的密码排在最前面)、变形规则和掩码。dictionary 为 false 时只使用上下文中的密码
+func (s candidateSet) forArchive(ctx context.Context, c cracker.Cracker, archive utils.Archive, cp checkpoint, dictionary bool) candidate.Source {
+	// 恢复任务时使用上次提取的密码和密码本的顺序，保证记录的进度仍然有效
+	leading, first := cp.start(func() []string {
+		var words []string
+		if s.parent != "" {
+			words = append(words, s.parent)
+		}
+		if s.context != nil {
+			// 注释只是辅助信息，读取失败时忽略
+			comment, _ := c.Comment(ctx)
+			words = append(words, candidate.FromContext(archive.Path, archive.Name, comment, *s.context)...)
+		}
+		return words
+	}, func() []string { return s.ranked(archive) })
+
+	sources := []candidate.Source{candidate.List(leading)}
+	if dictionary {
+		sources = append(sources, s.chain(store.Prioritize(s.words, first)))
+	}
+	return candidate.Chain(sources...)
+}
+
+// withParent 返回先尝试 password 的候选密码，用于解压嵌套的压缩包
+func (s candidateSet) withParent(password string) candidateSet {
+	s.parent = password
+	return s
+}
+
+// ranked 返回命中过的密码，按命中次数排序，byFolder 为 true 时优先考虑压缩包所在文件夹
+func (s candidateSet) ranked(archive utils.Archive) []string {
+	if s.stats == nil {
+		return nil
+	}
+	folder := ""
+	if s.byFolder {
+		folder = filepath.Dir(archive.Path)
+	}
+	return s.stats.Ranked(folder)
+}
+
+// lookup 在缓存中查找压缩包，返回缓存键 (不使用缓存或读取文件失败时为空)、已知的密码，
+// 以及是否已经用本次任务的密码本尝试过
+func (s candidateSet) lookup(archive utils.Archive) (key, password string, tried bool) {
+	if s.cache == nil {
+		return "", "", false
+	}
+	key, err := store.Key(archive.Volumes)
+	if err != nil {
+		return "", "", false
+	}
+	entry, _ := s.cache.Lookup(key)
+	return key, entry.Password, entry.HasTried(s.fingerprint)
+}
+
+// remember 把查找结果记入缓存：找到时记录密码，否则记录已经用本次任务的密码本尝试过
+func (s candidateSet) remember(key string, archive utils.Archive, password string) {
+	switch {
+	case s.cache == nil || key == "":
+	case password != "":
+		s.cache.SetPassword(key, archive.Path, password)
+	default:
+		s.cache.AddAttempt(key, archive.Path, s.fingerprint, s.label)
+	}
+}
+
+// record 把找到的密码记入命中统计
+func (s candidateSet) record(archive utils.Archive, password string) {
+	if s.stats != nil && password != "" {
+		s.stats.Add(archive.Path, password)
+	}
+}
+
+// save 在任务结束时保存命中统计和缓存，保存失败不影响任务结果
+func (s candidateSet) save() {
+	if s.stats != nil {
+		if err := s.stats.Save(); err != nil {
+			display.PrintWarning(fmt.Sprintf("无法保存密码命中统计: %v", err))
+		}
+	}
+	if s.cache != nil {
+		if err := s.cache.Save(); err != nil {
+			display.PrintWarning(fmt.Sprintf("无法保存密码缓存: %v", err))
+		}
+	}
+}
+
+// matchOutcome 是单个压缩包的匹配结果
+type matchOutcome struct {
+	encrypted bool // 压缩包是否需要密码
+	found     bool
+	password  string
+	cached    bool // 密码来自缓存，或者缓存中记录已用相同的密码本尝试过
+	tried     int  // 实际尝试过的候选密码数量
+	elapsed   time.Duration
+	err       error
+}
+
+// record 把匹配结果转换为结果文件中的记录
+func (o matchOutcome) record(archive utils.Archive) report.Record {
+	r := report.Record{
+		Path:     archive.Path,
+		Format:   archive.Format.Name,
+		Status:   report.NotFound,
+		Password: o.password,
+		Elapsed:  o.elapsed,
+		Tried:    o.tried,
+		Error:    errorText(o.err),
+	}
+	switch {
+	case o.err != nil:
+		r.Status = report.Error
+	case !o.encrypted:
+		r.Status = report.NotEncrypted
+	case o.found:
+		r.Status = report.Found
+	}
+	return r
+}
+
+// extractOutcome 是单个压缩包的解压结果
+type extractOutcome struct {
+	success   bool
+	password  string // 找到的密码，未加密时为空
+	tried     int    // 实际尝试过的候选密码数量
+	conflicts extract.Conflicts
+	unsafe    []extract.Issue // 不安全的路径，以及解压后删除的链接和文件
+	bombs     []extract.Issue // 压缩比异常、可能是压缩炸弹的条目
+	noSpace   error           // 剩余空间不足但按设置仍然解压时的说明
+	extracted []string        // 解压出的文件和新建的文件夹，用于查找嵌套的压缩包
+	nested    nestedOutcomes  // 嵌套的压缩包的解压结果
+	elapsed   time.Duration
+	err       error
+}
+
+// printDetails 在压缩包的结果下方输出同名文件、不安全的路径、磁盘空间和压缩比的情况，indent 为额外的缩进
+func (o extractOutcome) printDetails(indent string) {
+	if o.conflicts.Total > 0 {
+		display.PrintInfo(fmt.Sprintf("%s  └─> %s", indent, o.conflicts))
+	}
+	for _, issue := range o.unsafe {
+		display.PrintWarning(fmt.Sprintf("%s  └─> 不安全的路径: %s", indent, issue))
+	}
+	if o.noSpace != nil {
+		display.PrintWarning(fmt.Sprintf("%s  └─> %v，仍然解压", indent, o.noSpace))
+	}
+	for _, issue := range o.bombs {
+		display.PrintWarning(fmt.Sprintf("%s  └─> %s", indent, issue))
+	}
+}
+
+// record 把解压结果转换为结果文件中的记录
+func (o extractOutcome) record(archive utils.Archive) report.Record {
+	r := report.Record{
+		Path:     archive.Path,
+		Format:   archive.Format.Name,
+		Status:   report.Error,
+		Password: o.password,
+		Elapsed:  o.elapsed,
+		Tried:    o.tried,
+		Error:    errorText(o.err),
+	}
+	switch {
+	case o.success && o.password == "":
+		r.Status = report.NotEncrypted
+	case o.success:
+		r.Status = report.Found
+	case errors.Is(o.err, errNoMatch) || errors.Is(o.err, errCachedNoMatch):
+		r.Status = report.NotFound
+	}
+	return r
 }
 
 func main() {
+	if err := config.Load(defaultConfigFile); err != nil {
+		display.PrintError(fmt.Sprintf("加载配置失败: %v", err))
+		os.Exit(exitFailure)
+	}
+
+	// 带参数启动时进入命令行模式，便于脚本和计划任务调用
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+	os.Exit(runInteractive())
+}
+
+// runInteractive 运行交互式流程，返回进程退出码
+func runInteractive() int {
 	// 1. 打印通用标题
-	display.PrintDivider()
-	display.PrintCenteredTitle("Archive Tools - Go Version")
-	display.PrintDivider()
-	display.PrintEmptyLine()
+	printTitle()
+
+	// 2. 有未完成的任务时询问是否继续
+	if session := promptResume(); session != nil {
+		code := resumeSession(session)
+		display.PrintEmptyLine()
+		display.PrintInfo("感谢使用，程序已退出。")
+		return code
+	}
 
-	// 2. 首先获取用户需要处理的路径
+	// 3. 获取用户需要处理的路径
 	targetPath := getUserInput("请输入要处理的压缩包或文件夹路径 (留空使用当前目录): ")
 
-	// 3. 获取扫描选项
+	// 4. 获取扫描选项
 	scanOptions := showScanOptionsMenu()
 
-	// 4. 显示主菜单并获取选择
+	// 5. 显示主菜单并获取选择
 	choice := showMainMenu()
 
-	// 5. 根据选择执行不同的功能
+	opts := defaultTaskOptions()
+	opts.TargetPath = targetPath
+	opts.Scan = scanOptions
+	opts.Interactive = true
+
+	// 6. 根据选择执行不同的功能
+	code := exitFailure
 	switch choice {
 	case "1":
-		runPasswordMatcher(targetPath, scanOptions)
+		code = runPasswordMatcher(opts, nil)
 	case "2":
-		runExtractor(targetPath, scanOptions)
+		code = runExtractor(opts, nil)
 	default:
 		display.PrintWarning("无效的选择，程序退出。")
 	}
 
 	display.PrintEmptyLine()
 	display.PrintInfo("感谢使用，程序已退出。")
+	return code
+}
+
+// promptResume 发现未完成的任务时询问用户是否继续，返回要继续的会话
+// 用户选择不继续时删除会话文件，重新开始新的任务
+func promptResume() *store.Session {
+	if !store.SessionExists(defaultSessionFile) {
+		return nil
+	}
+	session, err := store.OpenSession(defaultSessionFile)
+	if err != nil {
+		display.PrintWarning(fmt.Sprintf("无法读取未完成的任务: %v", err))
+		return nil
+	}
+
+	archives := session.Archives()
+	done := 0
+	for _, a := range archives {
+		if a.Done {
+			done++
+		}
+	}
+	task := "密码匹配"
+	if session.Command() == "extract" {
+		task = "批量解压"
+	}
+	display.PrintSection("未完成的任务")
+	display.PrintFieldValue("任务类型", task)
+	display.PrintFieldValue("处理进度", fmt.Sprintf("%d / %d 个压缩包", done, len(archives)))
+	display.PrintSectionEnd()
+	display.PrintInputPrompt("是否继续上次的任务? (Y/n): ")
+	reader := bufio.NewReader(os.Stdin)
+	choice, _ := reader.ReadString('\n')
+	if strings.TrimSpace(strings.ToLower(choice)) != "n" {
+		return session
+	}
+	if err := session.Remove(); err != nil {
+		display.PrintWarning(fmt.Sprintf("无法删除任务进度文件: %v", err))
+	}
+	display.PrintInfo("已放弃上次的任务。")
+	display.PrintEmptyLine()
+	return nil
+}
+
+// printTitle 打印程序标题
+func printTitle() {
+	display.PrintDivider()
+	display.PrintCenteredTitle("Archive Tools - Go Version")
+	display.PrintDivider()
+	display.PrintEmptyLine()
 }
 
 // showMainMenu 显示主菜单并返回用户的选择
@@ -85,165 +376,602 @@ func showScanOptionsMenu() utils.ScanOptions {
 	excludeChoice, _ := reader.ReadString('\n')
 	exclude := strings.TrimSpace(strings.ToLower(excludeChoice)) != "n"
 
+	// 询问是否按文件头识别格式
+	display.PrintInputPrompt("是否按文件头识别格式 (收录扩展名错误或缺失的压缩包)? (y/N): ")
+	sniffChoice, _ := reader.ReadString('\n')
+	sniff := strings.TrimSpace(strings.ToLower(sniffChoice)) == "y"
+
+	// 询问是否识别自解压程序
+	display.PrintInputPrompt("是否识别自解压程序 (.exe 中嵌入的 RAR/7z/ZIP)? (y/N): ")
+	sfxChoice, _ := reader.ReadString('\n')
+	sfx := strings.TrimSpace(strings.ToLower(sfxChoice)) == "y"
+
 	display.PrintSectionEnd()
 	return utils.ScanOptions{
 		Recursive:     recursive,
 		ExcludePacked: exclude,
+		Sniff:         sniff,
+		SFX:           sfx,
 	}
 }
 
-// runPasswordMatcher 运行密码匹配功能的完整流程
-func runPasswordMatcher(targetPath string, scanOpts utils.ScanOptions) {
+// runPasswordMatcher 运行密码匹配功能的完整流程，返回进程退出码
+// session 不为 nil 时从中断的位置继续上次的任务
+func runPasswordMatcher(opts taskOptions, session *store.Session) int {
 	display.PrintHeader("--- 密码匹配器 ---")
 
 	// 1. 加载密码和扫描文件
-	passwords, archives, err := prepareTask(targetPath, scanOpts)
+	candidates, archives, err := prepareTask(&opts, session)
 	if err != nil {
 		display.PrintError(fmt.Sprintf("任务准备失败: %v", err))
-		return
+		return exitFailure
 	}
 
-	// 2. 显示摘要并获取用户选择的模式
-	mode := showSummaryAndGetMode(targetPath, passwords, archives)
+	// 2. 显示摘要，交互模式下由用户选择匹配模式
+	showSummary(opts, candidates, archives)
+	if opts.Interactive {
+		opts.Mode = promptMatchMode()
+	} else {
+		display.PrintInfo(fmt.Sprintf("匹配模式: %s", modeName(opts.Mode)))
+	}
 
-	// 3. 创建结果文件
-	resultFile, err := setupResultFile()
+	// 3. 创建结果文件，继续上次的任务时追加到原来的结果文件
+	results, err := openResults(opts, session)
 	if err != nil {
 		display.PrintError(fmt.Sprintf("无法创建结果文件: %v", err))
-		return
+		return exitFailure
 	}
-	defer resultFile.Close()
+	defer results.Close()
 
-	// 4. 开始处理
-	display.PrintSection("开始匹配")
-	ctx := context.Background()
-	var foundResult bool
-
-	for i, archivePath := range archives {
-		fileName := filepath.Base(archivePath)
-		progressPrefix := fmt.Sprintf("[%03d/%03d]", i+1, len(archives))
-		truncatedName := truncateString(fileName, 40)
-
-		found, password := processFile(ctx, archivePath, passwords, mode, progressPrefix, truncatedName)
-
-		if found {
-			foundResult = true
-			result := Result{FilePath: archivePath, Password: password}
-			clearLine()
-			display.PrintSuccess(fmt.Sprintf("%s %s -> 密码: %s", progressPrefix, truncatedName, password))
-			writeResult(resultFile, result)
-		} else {
-			clearLine()
-			display.PrintWarning(fmt.Sprintf("%s %s -> 未找到密码或无需密码", progressPrefix, truncatedName))
+	if session == nil {
+		if session, err = startSession(opts, "match", candidates.fingerprint, results.Name(), archives); err != nil {
+			display.PrintWarning(fmt.Sprintf("无法保存任务进度，中断后需要重新开始: %v", err))
+		}
+	}
+
+	// 4. 开始处理，上次已经处理完的压缩包直接计入结果
+	var foundCount, plainCount int
+	pending := pendingArchives(session, len(archives))
+	if done := len(archives) - len(pending); done > 0 {
+		for _, a := range session.Archives() {
+			switch {
+			case !a.Done || !a.Success:
+			case a.Encrypted:
+				foundCount++
+			default:
+				plainCount++
+			}
 		}
+		display.PrintInfo(fmt.Sprintf("上次已处理 %d 个压缩包，继续处理剩余的 %d 个", done, len(pending)))
+	}
+
+	display.PrintSection("开始匹配")
+	board := display.NewProgressBoard(opts.Workers)
+	ctx, stop := interruptContext(func() { board.Println(printInterruptNotice) })
+
+	var finished int
+	var interrupted []string
+	runPool(ctx, opts.Workers, len(pending),
+		func(ctx context.Context, worker, j int) matchOutcome {
+			i := pending[j]
+			prefix, name := progressLabel(i, len(archives), archives[i])
+			progress := func(text string) {
+				board.Update(worker, fmt.Sprintf("%s %s %s", prefix, name, text))
+			}
+			defer board.Update(worker, "")
+
+			start := time.Now()
+			o := processFile(ctx, archives[i], candidates, checkpoint{session, i}, opts.Mode, opts.Threads, progress)
+			o.elapsed = time.Since(start)
+			o.err = interruptedError(ctx, o.err)
+			return o
+		},
+		func(j int, o matchOutcome) {
+			i := pending[j]
+			prefix, name := progressLabel(i, len(archives), archives[i])
+			board.Println(func() {
+				if errors.Is(o.err, errInterrupted) {
+					// 没有处理完的压缩包不记入会话，继续任务时从记录的进度接着查找
+					interrupted = append(interrupted, archives[i].Path)
+					display.PrintWarning(fmt.Sprintf("%s %s -> 已中断", prefix, name))
+					return
+				}
+				finished++
+				switch {
+				case o.err != nil:
+					display.PrintError(fmt.Sprintf("%s %s -> %v", prefix, name, o.err))
+				case !o.encrypted:
+					plainCount++
+					display.PrintInfo(fmt.Sprintf("%s %s -> 未加密，无需密码", prefix, name))
+				case o.found:
+					foundCount++
+					source := ""
+					if o.cached {
+						source = " (来自缓存)"
+					}
+					display.PrintSuccess(fmt.Sprintf("%s %s -> 密码: %s%s", prefix, name, o.password, source))
+					candidates.record(archives[i], o.password)
+				case o.cached:
+					display.PrintWarning(fmt.Sprintf("%s %s -> 未找到密码 (缓存中记录已用相同的密码本尝试过)", prefix, name))
+				default:
+					display.PrintWarning(fmt.Sprintf("%s %s -> 未找到密码", prefix, name))
+				}
+				writeResult(results, o.record(archives[i]))
+				checkpoint{session, i}.finish(store.SessionArchive{
+					Success:   o.err == nil && (o.found || !o.encrypted),
+					Encrypted: o.encrypted,
+					Password:  o.password,
+					Error:     errorText(o.err),
+				})
+			})
+		})
+	stop()
+	board.Close()
+	candidates.save()
+
+	if skipped := len(pending) - finished - len(interrupted); skipped > 0 || len(interrupted) > 0 {
+		display.PrintSectionEnd()
+		display.PrintEmptyLine()
+		display.PrintSection("任务已中断")
+		display.PrintFieldValue("本次完成", fmt.Sprintf("%d 个", finished))
+		display.PrintFieldValue("找到密码", fmt.Sprintf("%d 个", foundCount))
+		display.PrintFieldValue("无需密码", fmt.Sprintf("%d 个", plainCount))
+		printInterrupted(opts, session, interrupted, skipped)
+		return exitInterrupted
 	}
+	finishSession(session)
 
 	display.PrintSectionEnd()
 	display.PrintEmptyLine()
 
-	if !foundResult {
+	if foundCount == 0 {
 		display.PrintWarning("所有任务已完成，但未找到任何密码。")
 	} else {
 		display.PrintSuccess("所有任务已完成。")
 	}
+	if plainCount > 0 {
+		display.PrintInfo(fmt.Sprintf("其中 %d 个压缩包未加密，无需密码。", plainCount))
+	}
+	// 未加密的压缩包不需要匹配，同样视为成功
+	return exitCodeFor(foundCount+plainCount, len(archives))
 }
 
-// runExtractor 运行批量解压功能的流程
-func runExtractor(targetPath string, scanOpts utils.ScanOptions) {
+// runExtractor 运行批量解压功能的流程，返回进程退出码
+// session 不为 nil 时从中断的位置继续上次的任务
+func runExtractor(opts taskOptions, session *store.Session) int {
 	display.PrintHeader("--- 批量解压器 ---")
 
-	// 1. 显示解压选项菜单
-	extractMode := showExtractorMenu()
-	if extractMode == 0 {
+	// 1. 交互模式下显示解压选项菜单
+	if opts.Interactive {
+		opts.ExtractMode = showExtractorMenu()
+		if opts.ExtractMode != 0 {
+			opts.Conflict = showConflictMenu()
+			opts.Nested = promptNested()
+			opts.Cleanup = showCleanupMenu()
+		}
+	}
+	if opts.ExtractMode == 0 {
 		display.PrintWarning("未选择解压模式，操作取消。")
-		return
+		return exitFailure
+	}
+	if !opts.Interactive {
+		display.PrintInfo(fmt.Sprintf("同名文件: %s", opts.Conflict.Label()))
+	}
+	display.PrintInfo(fmt.Sprintf("不安全的路径: %s", opts.Unsafe.Label()))
+	display.PrintInfo(fmt.Sprintf("磁盘空间: %s", opts.Space.Label()))
+	if opts.Nested > 0 {
+		display.PrintInfo(fmt.Sprintf("嵌套的压缩包: 最多解压 %d 层", opts.Nested))
+	}
+	if opts.Cleanup.Enabled() {
+		label := opts.Cleanup.Label(targetFolder(opts.TargetPath))
+		if opts.DryRun {
+			label += " (预览，不实际执行)"
+		}
+		display.PrintInfo(fmt.Sprintf("解压后的操作: %s", label))
 	}
+	extractOpts := extract.Options{Conflict: opts.Conflict, Unsafe: opts.Unsafe, Space: opts.Space, MaxRatio: opts.MaxRatio}
 
 	// 2. 加载密码和扫描文件
-	passwords, archives, err := prepareTask(targetPath, scanOpts)
+	candidates, archives, err := prepareTask(&opts, session)
 	if err != nil {
 		display.PrintError(fmt.Sprintf("任务准备失败: %v", err))
-		return
+		return exitFailure
+	}
+
+	// 3. 检查磁盘空间和压缩比，空间不足或被中断时在创建结果文件和任务进度之前退出
+	if err := preflight(opts, candidates, archives, pendingArchives(session, len(archives))); err != nil {
+		if errors.Is(err, errInterrupted) {
+			return exitInterrupted
+		}
+		return exitFailure
+	}
+
+	results, err := openResults(opts, session)
+	if err != nil {
+		display.PrintError(fmt.Sprintf("无法创建结果文件: %v", err))
+		return exitFailure
+	}
+	defer results.Close()
+
+	if session == nil {
+		if session, err = startSession(opts, "extract", candidates.fingerprint, results.Name(), archives); err != nil {
+			display.PrintWarning(fmt.Sprintf("无法保存任务进度，中断后需要重新开始: %v", err))
+		}
+	}
+
+	var extractedCount, nestedCount int
+	processed := make(map[int]store.SessionArchive) // 已处理完的压缩包的结果，用于解压后的操作
+	pending := pendingArchives(session, len(archives))
+	if done := len(archives) - len(pending); done > 0 {
+		for i, a := range session.Archives() {
+			if a.Done {
+				processed[i] = a
+			}
+			if a.Done && a.Success {
+				extractedCount++
+			}
+		}
+		display.PrintInfo(fmt.Sprintf("上次已处理 %d 个压缩包，继续处理剩余的 %d 个", done, len(pending)))
 	}
 
 	display.PrintSection("开始解压")
-	ctx := context.Background()
-	var extractedCount int
+	board := display.NewProgressBoard(opts.Workers)
+	ctx, stop := interruptContext(func() { board.Println(printInterruptNotice) })
 
-	for i, archivePath := range archives {
-		fileName := filepath.Base(archivePath)
-		progressPrefix := fmt.Sprintf("[%03d/%03d]", i+1, len(archives))
-		truncatedName := truncateString(fileName, 40)
+	var finished int
+	var interrupted []string
+	var conflicts extract.Conflicts // 所有压缩包的同名文件统计
+	aborted := 0                    // 因为同名文件没有解压的压缩包数量
+	refused := 0                    // 因为不安全的路径没有解压的压缩包数量
+	noSpace := 0                    // 因为磁盘空间不足没有解压的压缩包数量
+	runPool(ctx, opts.Workers, len(pending),
+		func(ctx context.Context, worker, j int) extractOutcome {
+			i := pending[j]
+			prefix, name := progressLabel(i, len(archives), archives[i])
+			progress := func(text string) {
+				board.Update(worker, fmt.Sprintf("%s %s %s", prefix, name, text))
+			}
+			defer board.Update(worker, "")
 
-		// 尝试用密码本解压
-		success, password, err := extractFile(ctx, archivePath, passwords, extractMode, progressPrefix, truncatedName)
+			// 尝试用密码本解压
+			start := time.Now()
+			o := extractFile(ctx, archives[i], candidates, checkpoint{session, i}, opts.ExtractMode, extractOpts, opts.Threads, progress)
+			o.elapsed = time.Since(start)
+			o.err = interruptedError(ctx, o.err)
 
-		if success {
-			extractedCount++
-			clearLine()
-			display.PrintSuccess(fmt.Sprintf("%s %s -> 解压成功, 密码: %s", progressPrefix, truncatedName, password))
-		} else {
-			clearLine()
-			display.PrintWarning(fmt.Sprintf("%s %s -> 解压失败", progressPrefix, truncatedName))
-			if err != nil {
-				display.PrintError(fmt.Sprintf("  └─> 错误详情: %v", err))
+			nested := nestedExtractor{
+				candidates: candidates,
+				scan:       opts.Scan,
+				maxDepth:   opts.Nested,
+				progress:   progress,
+				extractFile: func(ctx context.Context, archive utils.Archive, candidates candidateSet, progress func(string)) extractOutcome {
+					return extractFile(ctx, archive, candidates, checkpoint{}, opts.ExtractMode, extractOpts, opts.Threads, progress)
+				},
 			}
+			o.nested = nested.run(ctx, archives[i], o)
+			return o
+		},
+		func(j int, o extractOutcome) {
+			i := pending[j]
+			// 解压失败时密码也已经验证过，同样计入统计
+			candidates.record(archives[i], o.password)
+			prefix, name := progressLabel(i, len(archives), archives[i])
+			board.Println(func() {
+				if errors.Is(o.err, errInterrupted) {
+					interrupted = append(interrupted, archives[i].Path)
+					display.PrintWarning(fmt.Sprintf("%s %s -> 已中断", prefix, name))
+					if o.err != errInterrupted {
+						display.PrintWarning(fmt.Sprintf("  └─> %v", o.err))
+					}
+					return
+				}
+				finished++
+				conflicts.Add(o.conflicts)
+				switch {
+				case errors.Is(o.err, extract.ErrConflict):
+					aborted++
+				case errors.Is(o.err, extract.ErrUnsafe):
+					refused++
+				case errors.Is(o.err, extract.ErrNoSpace):
+					noSpace++
+				}
+				writeResult(results, o.record(archives[i]))
+				result := store.SessionArchive{
+					Success:   o.success,
+					Encrypted: o.password != "",
+					Password:  o.password,
+					Error:     errorText(o.err),
+					Skipped:   o.conflicts.Skipped,
+				}
+				processed[i] = result
+				defer checkpoint{session, i}.finish(result)
+				if o.success {
+					extractedCount++
+					if o.password == "" {
+						display.PrintSuccess(fmt.Sprintf("%s %s -> 解压成功, 未加密", prefix, name))
+					} else {
+						display.PrintSuccess(fmt.Sprintf("%s %s -> 解压成功, 密码: %s", prefix, name, o.password))
+					}
+					o.printDetails("")
+					o.nested.print(archives[i])
+					o.nested.record(candidates, results)
+					nestedCount += o.nested.success()
+					for _, n := range o.nested.list {
+						conflicts.Add(n.conflicts)
+					}
+					return
+				}
+				display.PrintWarning(fmt.Sprintf("%s %s -> 解压失败", prefix, name))
+				if o.err != nil {
+					display.PrintError(fmt.Sprintf("  └─> 错误详情: %v", o.err))
+				}
+				for _, issue := range o.bombs {
+					display.PrintWarning(fmt.Sprintf("  └─> %s", issue))
+				}
+			})
+		})
+	stop()
+	board.Close()
+	candidates.save()
+
+	if skipped := len(pending) - finished - len(interrupted); skipped > 0 || len(interrupted) > 0 {
+		display.PrintSectionEnd()
+		display.PrintEmptyLine()
+		display.PrintSection("任务已中断")
+		display.PrintFieldValue("本次完成", fmt.Sprintf("%d 个", finished))
+		display.PrintFieldValue("成功解压", fmt.Sprintf("%d 个", extractedCount))
+		printInterrupted(opts, session, interrupted, skipped)
+		if opts.Cleanup.Enabled() {
+			display.PrintInfo("任务没有完成，尚未移动或删除任何压缩包，全部完成后再统一处理。")
 		}
+		return exitInterrupted
 	}
+	finishSession(session)
 
 	display.PrintSectionEnd()
 	display.PrintEmptyLine()
 	display.PrintSuccess(fmt.Sprintf("所有任务已完成，成功解压 %d 个文件。", extractedCount))
+	if nestedCount > 0 {
+		display.PrintInfo(fmt.Sprintf("另外解压了其中嵌套的 %d 个压缩包。", nestedCount))
+	}
+	if conflicts.Total > 0 {
+		display.PrintInfo(fmt.Sprintf("共遇到%s (%s)。", conflicts, opts.Conflict.Label()))
+	}
+	if aborted > 0 {
+		display.PrintWarning(fmt.Sprintf("其中 %d 个压缩包因目标位置存在同名文件而没有解压。", aborted))
+	}
+	if refused > 0 {
+		display.PrintWarning(fmt.Sprintf("其中 %d 个压缩包含有不安全的路径，已拒绝解压。", refused))
+	}
+	if noSpace > 0 {
+		display.PrintWarning(fmt.Sprintf("其中 %d 个压缩包因磁盘空间不足没有解压。", noSpace))
+	}
+	runCleanup(opts, archives, processed)
+	return exitCodeFor(extractedCount, len(archives))
+}
+
+// errorText 返回错误信息，err 为 nil 时返回空字符串
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
 }
 
-// extractFile 尝试用密码列表解压单个文件
-func extractFile(ctx context.Context, filePath string, passwords []string, extractMode int, prefix, name string) (bool, string, error) {
-	c, err := cracker.NewCracker(filePath, cracker.AccurateMode, time.Hour)
+var (
+	// errNoMatch 表示候选密码都不正确
+	errNoMatch = errors.New("密码本中没有可用的密码")
+	// errCachedNoMatch 表示缓存中记录已用相同的密码本尝试过这个压缩包，没有找到密码
+	errCachedNoMatch = errors.New("缓存中记录已用相同的密码本尝试过，没有可用的密码")
+)
+
+// extractFile 先用密码列表找出正确的密码，再用该密码解压单个文件 (分卷压缩包作为一个整体解压)
+// 压缩包未加密时直接解压，返回的密码为空
+func extractFile(ctx context.Context, archive utils.Archive, candidates candidateSet, cp checkpoint, extractMode int, extractOpts extract.Options, threads int, progress func(string)) extractOutcome {
+	if err := checkVolumes(archive); err != nil {
+		return extractOutcome{err: err}
+	}
+	key, password, tried := candidates.lookup(archive)
+	if password == "" && tried && candidates.context == nil {
+		return extractOutcome{err: errCachedNoMatch}
+	}
+
+	c, err := cracker.NewCracker(archive, cracker.AccurateMode)
 	if err != nil {
-		return false, "", fmt.Errorf("创建解压器失败: %w", err)
+		return extractOutcome{err: fmt.Errorf("创建解压器失败: %w", err)}
 	}
 
-	var lastErr error
-	for _, password := range passwords {
-		fmt.Printf("\r%s %s 正在尝试密码: %s", prefix, name, password)
+	progress("正在检测加密方式...")
+	encryption, err := c.Probe(ctx)
+	if err != nil {
+		return extractOutcome{err: fmt.Errorf("检测加密方式失败: %w", err)}
+	}
 
-		finalExtractMode := extractMode
-		// 如果是智能模式，需要先检查文件列表来决定最终模式
-		if extractMode == 1 { // 1 是智能模式
-			rootItems, listErr := c.ListRootItems(ctx, password)
-			if listErr != nil {
-				// 如果列表失败（可能是密码错误），则继续尝试下一个密码
-				lastErr = listErr
-				continue
-			}
+	var o extractOutcome
+	switch {
+	case encryption == cracker.EncryptionNone:
+		password = ""
+		progress("正在解压...")
+	case password != "":
+		progress(fmt.Sprintf("正在解压, 缓存中的密码: %s", password))
+	default:
+		result, err := cracker.Search(ctx, c, candidates.forArchive(ctx, c, archive, cp, !tried).Iter(), cracker.SearchOptions{
+			Concurrency:  threads,
+			Skip:         cp.next(),
+			OnCheckpoint: cp.save,
+			OnAttempt: func(password string) {
+				progress(fmt.Sprintf("正在尝试密码: %s", password))
+			},
+		})
+		o.tried = result.Tried
+		if err != nil {
+			o.err = fmt.Errorf("尝试密码时出错: %w", err)
+			return o
+		}
+		if result.Found || !tried {
+			candidates.remember(key, archive, result.Password)
+		}
+		switch {
+		case !result.Found && tried:
+			o.err = errCachedNoMatch
+			return o
+		case !result.Found:
+			o.err = errNoMatch
+			return o
+		}
+		password = result.Password
+		progress(fmt.Sprintf("正在解压, 密码: %s", password))
+	}
 
-			// 智能判断逻辑
-			archiveNameNoExt := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
-			// 检查根目录下是否只有一个项目，并且该项目的名称与压缩包名称（不含扩展名）相同
-			if len(rootItems) == 1 && rootItems[0] == archiveNameNoExt {
-				finalExtractMode = 2 // 判定为：应该解压到当前目录
-			} else {
-				finalExtractMode = 3 // 其他所有情况，都解压到同名文件夹
-			}
+	o.password = password
+
+	// 解压前先列出文件，用于智能解压的判断和检查不安全的路径
+	entries, err := c.ListEntries(ctx, password)
+	if err != nil {
+		o.err = err
+		return o
+	}
+	o.bombs = extract.Bombs(entries, archiveSize(archive), extractOpts.MaxRatio)
+
+	finalExtractMode := extractMode
+	// 如果是智能模式，需要先检查文件列表来决定最终模式
+	if extractMode == 1 { // 1 是智能模式
+		// 智能判断逻辑
+		// 检查根目录下是否只有一个文件夹，并且该文件夹的名称与压缩包名称（不含扩展名和分卷编号）相同
+		if hasSingleRootFolder(entries, archive.Name) {
+			finalExtractMode = 2 // 判定为：应该解压到当前目录
+		} else {
+			finalExtractMode = 3 // 其他所有情况，都解压到同名文件夹
+		}
+	}
+
+	// 确定输出目录
+	var destPath string
+	if finalExtractMode == 2 { // 解压到当前目录
+		destPath = filepath.Dir(archive.Path)
+	} else { // 解压到同名文件夹 (模式3 和 智能模式的默认情况)
+		destPath = filepath.Join(filepath.Dir(archive.Path), archive.Name)
+	}
+
+	extractTo(ctx, &o, destPath, entries, extractOpts, func(dir string) error {
+		return c.Extract(ctx, password, dir)
+	})
+	return o
+}
+
+// extractTo 调用 run 把压缩包解压到 destPath，并把解压结果记入 o。
+// 输出目录已存在时先解压到其中的临时文件夹，再按设置处理同名文件，中断时临时文件夹会被删除；
+// 解压到新建的文件夹时，中断后删除解压了一半的文件夹
+func extractTo(ctx context.Context, o *extractOutcome, destPath string, entries []cracker.Entry, extractOpts extract.Options, run func(dir string) error) {
+	_, statErr := os.Stat(destPath)
+	created := errors.Is(statErr, os.ErrNotExist)
+	result, err := extract.Run(ctx, destPath, entries, extractOpts, run)
+	o.conflicts, o.unsafe, o.extracted, o.noSpace = result.Conflicts, result.Unsafe, result.Extracted, result.NoSpace
+	switch {
+	case err == nil:
+		o.success = true
+	case ctx.Err() == nil:
+		o.err = err
+	case !created:
+		o.err = fmt.Errorf("%w，已丢弃未解压完的文件", errInterrupted)
+	default:
+		if err := os.RemoveAll(destPath); err != nil {
+			o.err = fmt.Errorf("%w，无法删除未解压完的文件夹 '%s': %v", errInterrupted, destPath, err)
+		} else {
+			o.err = fmt.Errorf("%w，已删除未解压完的文件夹 '%s'", errInterrupted, destPath)
 		}
+	}
+}
 
-		// 确定输出目录
-		var destPath string
-		if finalExtractMode == 2 { // 解压到当前目录
-			destPath = filepath.Dir(filePath)
-		} else { // 解压到同名文件夹 (模式3 和 智能模式的默认情况)
-			ext := filepath.Ext(filePath)
-			destPath = filepath.Join(filepath.Dir(filePath), strings.TrimSuffix(filepath.Base(filePath), ext))
+// hasSingleRootFolder 判断压缩包根目录下是否只有一个名为 name 的文件夹
+// 压缩包中不一定有文件夹本身的条目，因此同时根据子条目的路径来判断
+func hasSingleRootFolder(entries []cracker.Entry, name string) bool {
+	if len(entries) == 0 {
+		return false
+	}
+	for _, e := range entries {
+		root, _, nested := strings.Cut(strings.TrimPrefix(e.Path, "./"), "/")
+		if root != name || (!nested && !e.IsDir) {
+			return false
 		}
+	}
+	return true
+}
+
+// showConflictMenu 询问解压出的文件与已有文件同名时的处理方式
+func showConflictMenu() extract.Policy {
+	display.PrintSection("同名文件")
+	display.PrintInfo("1. 覆盖已有文件 (默认)")
+	display.PrintInfo("2. 跳过同名文件 (保留已有文件)")
+	display.PrintInfo("3. 重命名解压出的文件, 例如 file (1).txt")
+	display.PrintInfo("4. 重命名已有文件")
+	display.PrintInfo("5. 跳过整个压缩包")
+	display.PrintSectionEnd()
+	display.PrintEmptyLine()
+
+	display.PrintInputPrompt("请选择同名文件的处理方式 [默认为1]: ")
+	reader := bufio.NewReader(os.Stdin)
+	choice, _ := reader.ReadString('\n')
+	switch strings.TrimSpace(choice) {
+	case "2":
+		return extract.Skip
+	case "3":
+		return extract.RenameNew
+	case "4":
+		return extract.RenameExisting
+	case "5":
+		return extract.Abort
+	default:
+		return extract.Overwrite
+	}
+}
 
-		err := c.Extract(ctx, password, destPath)
-		if err == nil {
-			return true, password, nil
+// promptNested 询问是否继续解压压缩包中嵌套的压缩包，返回最多解压的层数，0 表示不解压
+func promptNested() int {
+	display.PrintInputPrompt("解压后是否继续解压其中的压缩包? 输入最多解压的层数 [默认为0, 不解压]: ")
+	reader := bufio.NewReader(os.Stdin)
+	choice, _ := reader.ReadString('\n')
+	depth, err := strconv.Atoi(strings.TrimSpace(choice))
+	if err != nil || depth < 0 {
+		return 0
+	}
+	return depth
+}
+
+// showCleanupMenu 询问任务完成后如何处理源压缩包
+func showCleanupMenu() extract.Cleanup {
+	display.PrintSection("解压后的操作")
+	display.PrintInfo("1. 保留压缩包")
+	display.PrintInfo("2. 删除压缩包 (包括全部分卷)")
+	display.PrintInfo(fmt.Sprintf("3. 移动到目标文件夹中的 %s 文件夹", extract.DefaultDoneDir))
+	display.PrintInfo("4. 移动到指定的文件夹")
+	display.PrintSectionEnd()
+	display.PrintEmptyLine()
+
+	var cleanup extract.Cleanup
+	display.PrintInputPrompt("请选择解压成功后如何处理压缩包 [默认为1]: ")
+	reader := bufio.NewReader(os.Stdin)
+	choice, _ := reader.ReadString('\n')
+	switch strings.TrimSpace(choice) {
+	case "2":
+		cleanup.OnSuccess = extract.Delete
+	case "3":
+		cleanup.OnSuccess = extract.Move
+	case "4":
+		display.PrintInputPrompt("请输入要移动到的文件夹: ")
+		dir, _ := reader.ReadString('\n')
+		if dir = strings.Trim(strings.TrimSpace(dir), "\""); dir != "" {
+			cleanup.OnSuccess = extract.Move
+			cleanup.DoneDir = absPath(dir)
 		}
-		lastErr = err
 	}
 
-	return false, "", lastErr
+	display.PrintInputPrompt(fmt.Sprintf("是否把解压失败的压缩包移动到目标文件夹中的 %s 文件夹? (y/N): ", extract.DefaultFailedDir))
+	choice, _ = reader.ReadString('\n')
+	if strings.TrimSpace(strings.ToLower(choice)) == "y" {
+		cleanup.OnFailure = extract.Move
+	}
+	return cleanup
 }
 
 // showExtractorMenu 显示解压器子菜单并返回用户的选择
@@ -272,32 +1000,73 @@ func showExtractorMenu() int {
 	}
 }
 
-func processFile(ctx context.Context, filePath string, passwords []string, mode cracker.Mode, prefix, name string) (bool, string) {
-	c, err := cracker.NewCracker(filePath, mode, processTimeout)
+// processFile 先探测单个文件的加密方式，再使用密码列表查找密码
+// threads 为同时尝试的密码数量，progress 用于汇报当前进度
+func processFile(ctx context.Context, archive utils.Archive, candidates candidateSet, cp checkpoint, mode cracker.Mode, threads int, progress func(string)) matchOutcome {
+	if err := checkVolumes(archive); err != nil {
+		return matchOutcome{err: err}
+	}
+	key, password, tried := candidates.lookup(archive)
+	switch {
+	case password != "":
+		return matchOutcome{encrypted: true, found: true, password: password, cached: true}
+	case tried && candidates.context == nil:
+		return matchOutcome{encrypted: true, cached: true}
+	}
+
+	c, err := cracker.NewCracker(archive, mode)
 	if err != nil {
-		clearLine()
-		display.PrintError(fmt.Sprintf("创建破解器失败 (%s): %v", name, err))
-		return false, ""
+		return matchOutcome{err: fmt.Errorf("创建破解器失败: %w", err)}
 	}
 
-	for _, password := range passwords {
-		fmt.Printf("\r%s %s 正在尝试: %s", prefix, name, password)
+	progress("正在检测加密方式...")
+	encryption, err := c.Probe(ctx)
+	if err != nil {
+		return matchOutcome{err: fmt.Errorf("检测加密方式失败: %w", err)}
+	}
+	if encryption == cracker.EncryptionNone {
+		return matchOutcome{}
+	}
 
-		ok, err := c.TryPassword(ctx, password)
-		if err != nil {
-			clearLine()
-			display.PrintError(fmt.Sprintf("尝试密码时出错 (%s): %v", name, err))
-			return false, ""
-		}
-		if ok {
-			return true, password
-		}
+	// 已经用相同的密码本尝试过时，只尝试上下文中的密码
+	result, err := cracker.Search(ctx, c, candidates.forArchive(ctx, c, archive, cp, !tried).Iter(), cracker.SearchOptions{
+		Concurrency:  threads,
+		Skip:         cp.next(),
+		OnCheckpoint: cp.save,
+		OnAttempt: func(password string) {
+			progress(fmt.Sprintf("正在尝试: %s", password))
+		},
+	})
+	if err != nil {
+		return matchOutcome{encrypted: true, tried: result.Tried, err: fmt.Errorf("尝试密码时出错: %w", err)}
+	}
+	if result.Found || !tried {
+		candidates.remember(key, archive, result.Password)
 	}
-	return false, ""
+	return matchOutcome{encrypted: true, found: result.Found, password: result.Password, cached: tried && !result.Found, tried: result.Tried}
 }
 
 // --- 辅助函数 ---
 
+// checkVolumes 检查分卷压缩包是否完整，缺少分卷时无法匹配或解压
+func checkVolumes(archive utils.Archive) error {
+	if len(archive.Missing) > 0 {
+		return fmt.Errorf("缺少分卷: %s", strings.Join(archive.Missing, ", "))
+	}
+	return nil
+}
+
+// countMultiVolume 统计分卷压缩包的组数
+func countMultiVolume(archives []utils.Archive) int {
+	count := 0
+	for _, archive := range archives {
+		if archive.IsMultiVolume() {
+			count++
+		}
+	}
+	return count
+}
+
 func getUserInput(prompt string) string {
 	display.PrintInputPrompt(prompt)
 	reader := bufio.NewReader(os.Stdin)
@@ -312,38 +1081,260 @@ func getUserInput(prompt string) string {
 	return strings.Trim(input, "\"")
 }
 
-func prepareTask(path string, scanOpts utils.ScanOptions) ([]string, []string, error) {
+// prepareTask 加载候选密码并扫描压缩包，交互模式下询问的设置会写回 opts
+// session 不为 nil 时使用其中保存的压缩包列表，并确认候选密码与上次相同
+func prepareTask(opts *taskOptions, session *store.Session) (candidateSet, []utils.Archive, error) {
 	display.PrintInfo("正在加载密码文件...")
-	passwords, err := utils.LoadPasswords(passwordsFile)
+	passwords, err := utils.LoadPasswords(opts.PasswordsFile)
 	if err != nil {
-		return nil, nil, err
+		return candidateSet{}, nil, err
 	}
 	if len(passwords) == 0 {
-		return nil, nil, fmt.Errorf("密码文件 '%s' 为空", passwordsFile)
+		return candidateSet{}, nil, fmt.Errorf("密码文件 '%s' 为空", opts.PasswordsFile)
 	}
 	display.PrintSuccess(fmt.Sprintf("加载了 %d 个唯一密码", len(passwords)))
 
+	rules, err := loadRules(opts.RulesFile)
+	if err != nil {
+		return candidateSet{}, nil, err
+	}
+	if len(rules) > 0 {
+		display.PrintSuccess(fmt.Sprintf("加载了 %d 条变形规则", len(rules)))
+	}
+	candidates := candidateSet{words: passwords, rules: rules, byFolder: opts.StatsByFolder}
+	if candidates.masks, err = loadMasks(opts); err != nil {
+		return candidateSet{}, nil, err
+	}
+	candidates.source = candidates.chain(candidates.words)
+	candidates.fingerprint = candidateFingerprint(passwords, rules, candidates.masks)
+	if session != nil && session.Fingerprint() != candidates.fingerprint {
+		return candidateSet{}, nil, errors.New("密码本、变形规则或掩码与上次不同，无法从中断的位置继续")
+	}
+	if opts.StatsFile != "" {
+		if candidates.stats, err = store.Open(opts.StatsFile); err != nil {
+			return candidateSet{}, nil, err
+		}
+	}
+	if opts.CacheFile != "" {
+		if candidates.cache, err = store.OpenCache(opts.CacheFile); err != nil {
+			return candidateSet{}, nil, err
+		}
+		candidates.label = fmt.Sprintf("%s (%d 个密码, %d 条规则", filepath.Base(opts.PasswordsFile), len(passwords), len(rules))
+		for _, m := range candidates.masks {
+			candidates.label += ", " + m.String()
+		}
+		candidates.label += ")"
+	}
+	if opts.Context {
+		if candidates.context, err = loadContextOptions(config.Cfg.Context); err != nil {
+			return candidateSet{}, nil, err
+		}
+	}
+
+	if session != nil {
+		archives, err := restoreArchives(session)
+		return candidates, archives, err
+	}
+
 	display.PrintInfo("正在扫描压缩文件...")
-	archives, err := utils.ScanArchives(path, scanOpts)
+	archives, err := utils.ScanArchives(opts.TargetPath, opts.Scan)
 	if err != nil {
-		return nil, nil, err
+		return candidateSet{}, nil, err
 	}
 	if len(archives) == 0 {
-		return nil, nil, fmt.Errorf("在 '%s' 下未找到支持的压缩文件", path)
+		return candidateSet{}, nil, fmt.Errorf("在 '%s' 下未找到支持的压缩文件", opts.TargetPath)
 	}
 	display.PrintSuccess(fmt.Sprintf("扫描到 %d 个待匹配文件", len(archives)))
 
-	return passwords, archives, nil
+	return candidates, archives, nil
+}
+
+// candidateFingerprint 计算候选密码的指纹：密码本的内容相同 (与顺序无关)、规则和掩码也相同时指纹相同
+// 上下文中的密码和历史命中的密码不计入指纹
+func candidateFingerprint(passwords []string, rules []candidate.Rule, masks []*candidate.Mask) string {
+	sorted := slices.Clone(passwords)
+	slices.Sort(sorted)
+	h := sha256.New()
+	for _, p := range sorted {
+		fmt.Fprintf(h, "p%d:%s\n", len(p), p)
+	}
+	for _, rule := range rules {
+		fmt.Fprintf(h, "r%d:%s\n", len(rule.String()), rule)
+	}
+	for _, m := range masks {
+		fmt.Fprintf(h, "m%d:%s\n", len(m.String()), m)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// loadRules 加载变形规则文件，默认的规则文件不存在时不使用规则
+func loadRules(path string) ([]candidate.Rule, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if path == defaultRulesFile {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil, nil
+		}
+	}
+	display.PrintInfo("正在加载规则文件...")
+	return candidate.LoadRules(path)
+}
+
+// loadContextOptions 编译配置文件中提取上下文密码的正则表达式
+func loadContextOptions(cfg config.ContextConfig) (*candidate.ContextOptions, error) {
+	opts := &candidate.ContextOptions{ParentDepth: cfg.ParentDepth}
+	for _, pattern := range cfg.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("配置中的密码正则 '%s' 无效: %w", pattern, err)
+		}
+		opts.Patterns = append(opts.Patterns, re)
+	}
+	for _, pattern := range cfg.NoteFiles {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("配置中的说明文件正则 '%s' 无效: %w", pattern, err)
+		}
+		opts.NoteFiles = append(opts.NoteFiles, re)
+	}
+	return opts, nil
+}
+
+// loadMasks 解析密码本之后使用的掩码和暴力破解设置，交互模式下询问掩码
+func loadMasks(opts *taskOptions) ([]*candidate.Mask, error) {
+	if opts.Interactive && opts.MaskOptions.Mask == "" {
+		display.PrintInputPrompt("密码本用完后尝试的掩码 (例如 ?d?d?d?d?d?d，留空不使用): ")
+		reader := bufio.NewReader(os.Stdin)
+		input, _ := reader.ReadString('\n')
+		opts.MaskOptions.Mask = strings.TrimSpace(input)
+	}
+
+	var masks []*candidate.Mask
+	if opts.MaskOptions.Mask != "" {
+		m, err := candidate.NewMask(opts.MaskOptions)
+		if err != nil {
+			return nil, fmt.Errorf("掩码无效: %w", err)
+		}
+		masks = append(masks, m)
+	}
+	if opts.BruteCharset != "" {
+		m, err := candidate.NewBruteForce(opts.BruteCharset, opts.BruteMin, opts.BruteMax)
+		if err != nil {
+			return nil, err
+		}
+		masks = append(masks, m)
+	}
+	return masks, nil
 }
 
-func showSummaryAndGetMode(path string, passwords, archives []string) cracker.Mode {
+// showSummary 显示任务摘要
+func showSummary(opts taskOptions, candidates candidateSet, archives []utils.Archive) {
 	display.PrintSection("任务摘要")
-	display.PrintFieldValue("目标路径", path)
-	display.PrintFieldValue("密码数量", fmt.Sprintf("%d 个", len(passwords)))
+	display.PrintFieldValue("目标路径", opts.TargetPath)
+	display.PrintFieldValue("密码数量", fmt.Sprintf("%d 个", len(candidates.words)))
+	if candidates.stats != nil {
+		if hits := candidates.stats.Hits(); hits > 0 {
+			order := "按命中次数"
+			if candidates.byFolder {
+				order = "按所在文件夹和总的命中次数"
+			}
+			display.PrintFieldValue("历史命中", fmt.Sprintf("%d 个密码%s优先尝试", hits, order))
+		}
+	}
+	if candidates.context != nil {
+		display.PrintFieldValue("上下文密码", "优先尝试文件名、文件夹名、说明文件和注释中的密码")
+	}
+	if len(candidates.rules) > 0 {
+		display.PrintFieldValue("变形规则", fmt.Sprintf("%d 条", len(candidates.rules)))
+	}
+	for _, m := range candidates.masks {
+		display.PrintFieldValue("追加尝试", fmt.Sprintf("%s, %s 个组合", m, formatCount(m.Size())))
+	}
+	if len(candidates.rules) > 0 || len(candidates.masks) > 0 {
+		total := candidates.source.Size()
+		display.PrintFieldValue("候选密码", fmt.Sprintf("最多 %s 个", formatCount(total)))
+		if len(candidates.masks) > 0 {
+			// 掩码的组合数可能非常大，试跑一小段时间估算需要多久
+			display.PrintInfo("正在估算尝试速度...")
+			if speed := measureSpeed(archives, opts.Mode, opts.Threads); speed > 0 {
+				display.PrintFieldValue("尝试速度", fmt.Sprintf("约 %s 个/秒", formatCount(int64(speed))))
+				display.PrintFieldValue("预计耗时", fmt.Sprintf("每个压缩包最多 %s", formatSeconds(float64(total)/speed)))
+			}
+		}
+	}
 	display.PrintFieldValue("待匹配文件", fmt.Sprintf("%d 个", len(archives)))
+	if volumeSets := countMultiVolume(archives); volumeSets > 0 {
+		display.PrintFieldValue("其中分卷压缩包", fmt.Sprintf("%d 组", volumeSets))
+	}
+	display.PrintFieldValue("并发数", fmt.Sprintf("%d 个", opts.Workers))
+	display.PrintFieldValue("单包并发尝试", fmt.Sprintf("%d 个", opts.Threads))
 	display.PrintSectionEnd()
 	display.PrintEmptyLine()
+}
+
+// measureSpeed 用前几个压缩包中第一个加密的压缩包试跑一小段时间，估算每秒能尝试的密码数量
+// 没有可用于测速的压缩包时返回 0
+func measureSpeed(archives []utils.Archive, mode cracker.Mode, threads int) float64 {
+	ctx, cancel := context.WithTimeout(context.Background(), speedTestTimeout)
+	defer cancel()
+
+	for _, archive := range archives[:min(len(archives), 3)] {
+		c, err := cracker.NewCracker(archive, mode)
+		if err != nil || checkVolumes(archive) != nil {
+			continue
+		}
+		if encryption, err := c.Probe(ctx); err != nil || encryption == cracker.EncryptionNone {
+			continue
+		}
+		start := time.Now()
+		tried := 0
+		for time.Since(start) < speedTestDuration {
+			if _, err := c.TryPassword(ctx, fmt.Sprintf("speed-test-%d", tried)); err != nil {
+				break
+			}
+			tried++
+		}
+		if tried == 0 {
+			return 0
+		}
+		return float64(tried) / time.Since(start).Seconds() * float64(threads)
+	}
+	return 0
+}
+
+// formatCount 把数量格式化为便于阅读的形式，例如 "1.2亿"
+func formatCount(n int64) string {
+	switch {
+	case n == math.MaxInt64:
+		return "922亿亿+"
+	case n >= 1e8:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1e8), ".0") + "亿"
+	case n >= 1e4:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1e4), ".0") + "万"
+	}
+	return fmt.Sprintf("%d", n)
+}
+
+// formatSeconds 把秒数格式化为便于阅读的时长
+func formatSeconds(seconds float64) string {
+	switch {
+	case seconds < 60:
+		return fmt.Sprintf("%.0f 秒", max(seconds, 1))
+	case seconds < 3600:
+		return fmt.Sprintf("%.0f 分钟", seconds/60)
+	case seconds < 86400:
+		return fmt.Sprintf("%.1f 小时", seconds/3600)
+	case seconds < 365*86400:
+		return fmt.Sprintf("%.1f 天", seconds/86400)
+	case seconds < 10000*365*86400:
+		return fmt.Sprintf("%.1f 年", seconds/(365*86400))
+	}
+	return "超过 1 万年"
+}
 
+// promptMatchMode 询问用户选择匹配模式
+func promptMatchMode() cracker.Mode {
 	display.PrintInputPrompt("请选择匹配模式 (1.快速, 2.精确) [默认为1]: ")
 	reader := bufio.NewReader(os.Stdin)
 	choice, _ := reader.ReadString('\n')
@@ -357,27 +1348,29 @@ func showSummaryAndGetMode(path string, passwords, archives []string) cracker.Mo
 	return cracker.QuickMode
 }
 
-func setupResultFile() (*os.File, error) {
-	if err := os.MkdirAll(resultDir, 0755); err != nil {
+// openResults 创建本次任务的结果文件，继续上次的任务时追加到原来的结果文件
+func openResults(opts taskOptions, session *store.Session) (*report.Writer, error) {
+	format, err := report.ParseFormat(opts.ResultFormat)
+	if err != nil {
+		return nil, err
+	}
+	if session != nil && session.ResultFile() != "" {
+		display.PrintInfo(fmt.Sprintf("结果将追加到上次的结果文件: %s", session.ResultFile()))
+		return report.Open(session.ResultFile(), format)
+	}
+	if err := os.MkdirAll(opts.ResultDir, 0755); err != nil {
 		return nil, err
 	}
 	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	fileName := filepath.Join(resultDir, fmt.Sprintf("results_%s.txt", timestamp))
+	fileName := filepath.Join(opts.ResultDir, fmt.Sprintf("results_%s%s", timestamp, format.Ext()))
 	display.PrintInfo(fmt.Sprintf("本次任务的结果将记录在: %s", fileName))
-	return os.Create(fileName)
+	return report.Open(fileName, format)
 }
 
-func writeResult(file *os.File, result Result) {
-	if file == nil {
-		return
-	}
-	content := fmt.Sprintf("文件: %s\n密码: %s\n%s\n",
-		result.FilePath,
-		result.Password,
-		strings.Repeat("-", 20))
-
-	if _, err := file.WriteString(content); err != nil {
-		log.Printf("写入结果文件失败: %v", err)
+// writeResult 写入一条结果，写入失败时只给出警告，需要在输出结果的位置调用
+func writeResult(results *report.Writer, r report.Record) {
+	if err := results.Write(r); err != nil {
+		display.PrintWarning(fmt.Sprintf("写入结果文件失败: %v", err))
 	}
 }
 
@@ -392,6 +1385,14 @@ func truncateString(s string, num int) string {
 	return string(runes[:num]) + "..."
 }
 
-func clearLine() {
-	fmt.Printf("\r%s\r", strings.Repeat(" ", display.GetTerminalWidth()))
+// progressLabel 返回第 i 个任务的进度前缀和截断后的文件名，按文件头识别的格式附在文件名后
+func progressLabel(i, total int, archive utils.Archive) (string, string) {
+	name := truncateString(filepath.Base(archive.Path), 40)
+	switch {
+	case archive.IsSFX():
+		name += fmt.Sprintf(" [%s 自解压]", archive.Format.Name)
+	case archive.Sniffed():
+		name += fmt.Sprintf(" [%s]", archive.Format.Name)
+	}
+	return fmt.Sprintf("[%03d/%03d]", i+1, total), name
 }
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"ArchiveTools/display"
+	"ArchiveTools/report"
+	"ArchiveTools/store"
+	"ArchiveTools/utils"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxNestedArchives 是一个压缩包中最多解压的嵌套压缩包数量 (包括各层)，防止压缩炸弹无限展开
+const maxNestedArchives = 100
+
+// errRepeatedArchive 表示嵌套的压缩包与已经解压过的压缩包内容相同，没有再次解压
+var errRepeatedArchive = errors.New("与已解压的压缩包内容相同 (可能是循环嵌套)")
+
+// nestedOutcome 是一个嵌套压缩包的解压结果
+type nestedOutcome struct {
+	archive utils.Archive
+	depth   int // 1 表示直接包含在任务中的压缩包里
+	extractOutcome
+}
+
+// nestedOutcomes 是一个压缩包中各层嵌套压缩包的解压结果，按解压的顺序排列
+type nestedOutcomes struct {
+	list    []nestedOutcome
+	limited bool // 数量超过 maxNestedArchives，其余的没有解压
+}
+
+// nestedExtractor 在压缩包解压成功后查找解压出的压缩包并继续解压，
+// 一层一层深入，直到达到层数上限或没有新的压缩包
+type nestedExtractor struct {
+	candidates candidateSet
+	scan       utils.ScanOptions
+	maxDepth   int // 最多解压的层数，0 表示不解压嵌套的压缩包
+	progress   func(string)
+	// extractFile 查找密码并解压一个嵌套的压缩包，candidates 中已经设置了上一层的密码
+	extractFile func(ctx context.Context, archive utils.Archive, candidates candidateSet, progress func(string)) extractOutcome
+
+	seen    map[string]bool // 已经解压过的压缩包，以内容为键，防止压缩包包含自身造成的循环
+	results nestedOutcomes
+}
+
+// run 解压 archive 中嵌套的压缩包，o 为 archive 本身的解压结果
+func (n *nestedExtractor) run(ctx context.Context, archive utils.Archive, o extractOutcome) nestedOutcomes {
+	if n.maxDepth <= 0 || !o.success {
+		return nestedOutcomes{}
+	}
+	n.seen = make(map[string]bool)
+	if key, err := store.Key(archive.Volumes); err == nil {
+		n.seen[key] = true
+	}
+	n.results = nestedOutcomes{}
+	n.extract(ctx, o.extracted, o.password, 1)
+	return n.results
+}
+
+// extract 在 paths 中查找压缩包并依次解压，每解压一个就立即深入下一层。
+// password 为上一层的密码，上一层没有加密时沿用更外层的密码
+func (n *nestedExtractor) extract(ctx context.Context, paths []string, password string, depth int) {
+	if depth > n.maxDepth {
+		return
+	}
+	for _, archive := range utils.ScanFiles(listFiles(paths), n.scan) {
+		if ctx.Err() != nil {
+			return
+		}
+		if len(n.results.list) >= maxNestedArchives {
+			n.results.limited = true
+			return
+		}
+		if key, err := store.Key(archive.Volumes); err == nil {
+			if n.seen[key] {
+				n.results.list = append(n.results.list, nestedOutcome{archive: archive, depth: depth, extractOutcome: extractOutcome{err: errRepeatedArchive}})
+				continue
+			}
+			n.seen[key] = true
+		}
+
+		progress := func(text string) {
+			n.progress(fmt.Sprintf("└─ %s: %s", archive.Name, text))
+		}
+		start := time.Now()
+		o := n.extractFile(ctx, archive, n.candidates.withParent(password), progress)
+		o.elapsed = time.Since(start)
+		o.err = interruptedError(ctx, o.err)
+		n.results.list = append(n.results.list, nestedOutcome{archive: archive, depth: depth, extractOutcome: o})
+
+		if o.success {
+			inner := o.password
+			if inner == "" {
+				inner = password
+			}
+			n.extract(ctx, o.extracted, inner, depth+1)
+		}
+	}
+}
+
+// listFiles 返回 paths 中的文件以及其中的文件夹里的所有文件，不跟随符号链接
+func listFiles(paths []string) []string {
+	var files []string
+	for _, root := range paths {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && d.Type().IsRegular() {
+				files = append(files, path)
+			}
+			return nil
+		})
+	}
+	return files
+}
+
+// success 返回成功解压的嵌套压缩包数量
+func (r nestedOutcomes) success() int {
+	count := 0
+	for _, n := range r.list {
+		if n.success {
+			count++
+		}
+	}
+	return count
+}
+
+// record 把嵌套压缩包的密码记入命中统计，并把解压结果写入结果文件，需要在输出结果的位置调用
+func (r nestedOutcomes) record(candidates candidateSet, results *report.Writer) {
+	for _, n := range r.list {
+		candidates.record(n.archive, n.password)
+		if !errors.Is(n.err, errInterrupted) && !errors.Is(n.err, errRepeatedArchive) {
+			writeResult(results, n.record(n.archive))
+		}
+	}
+}
+
+// print 在外层压缩包的结果下方逐个输出嵌套压缩包的结果，路径相对于外层压缩包所在的文件夹
+func (r nestedOutcomes) print(parent utils.Archive) {
+	dir := filepath.Dir(parent.Path)
+	name := func(a utils.Archive) string {
+		if rel, err := filepath.Rel(dir, a.Path); err == nil {
+			return rel
+		}
+		return a.Path
+	}
+
+	for _, n := range r.list {
+		indent := strings.Repeat("  ", n.depth)
+		label := fmt.Sprintf("%s└─> 嵌套 %s", indent, name(n.archive))
+		switch {
+		case n.success && n.password == "":
+			display.PrintSuccess(fmt.Sprintf("%s -> 解压成功, 未加密", label))
+		case n.success:
+			display.PrintSuccess(fmt.Sprintf("%s -> 解压成功, 密码: %s", label, n.password))
+		case errors.Is(n.err, errInterrupted):
+			display.PrintWarning(fmt.Sprintf("%s -> 已中断", label))
+		case errors.Is(n.err, errRepeatedArchive):
+			display.PrintWarning(fmt.Sprintf("%s -> 跳过: %v", label, n.err))
+		default:
+			display.PrintWarning(fmt.Sprintf("%s -> 解压失败: %v", label, n.err))
+		}
+		n.printDetails(indent + "  ")
+	}
+	if r.limited {
+		display.PrintWarning(fmt.Sprintf("  └─> 嵌套的压缩包超过 %d 个，其余的没有解压", maxNestedArchives))
+	}
+}
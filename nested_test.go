@@ -0,0 +1,156 @@
+package main
+
+import (
+	"ArchiveTools/utils"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// fakeNested 代替真实的解压，文件名为 name 的压缩包解压出 children 中的文件 (文件名 -> 内容)，
+// 并记录每次解压时先尝试的上一层密码
+type fakeNested struct {
+	t        *testing.T
+	root     string
+	archives map[string]fakeArchive
+	parents  map[string]string
+}
+
+type fakeArchive struct {
+	password string
+	children map[string]string
+}
+
+func newFakeNested(t *testing.T, archives map[string]fakeArchive) *fakeNested {
+	return &fakeNested{t: t, root: t.TempDir(), archives: archives, parents: make(map[string]string)}
+}
+
+// write 在 dir 中写入 files，返回写入的文件夹
+func (f *fakeNested) write(dir string, files map[string]string) string {
+	f.t.Helper()
+	dir = filepath.Join(f.root, dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		f.t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			f.t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func (f *fakeNested) extractor(maxDepth int) *nestedExtractor {
+	return &nestedExtractor{
+		maxDepth: maxDepth,
+		progress: func(string) {},
+		extractFile: func(ctx context.Context, archive utils.Archive, candidates candidateSet, progress func(string)) extractOutcome {
+			name := filepath.Base(archive.Path)
+			f.parents[name] = candidates.parent
+			a := f.archives[name]
+			dir := f.write(name+".out", a.children)
+			return extractOutcome{success: true, password: a.password, extracted: []string{dir}}
+		},
+	}
+}
+
+// run 解压 outer.zip (内容为 content，密码为 password) 中嵌套的压缩包
+func (f *fakeNested) run(maxDepth int, content, password string) nestedOutcomes {
+	f.t.Helper()
+	outer := filepath.Join(f.write("outer", map[string]string{"outer.zip": content}), "outer.zip")
+	archive := utils.ScanFiles([]string{outer}, utils.ScanOptions{})[0]
+	dir := f.write("outer.zip.out", f.archives["outer.zip"].children)
+	return f.extractor(maxDepth).run(context.Background(), archive, extractOutcome{success: true, password: password, extracted: []string{dir}})
+}
+
+func names(r nestedOutcomes) []string {
+	var list []string
+	for _, n := range r.list {
+		list = append(list, fmt.Sprintf("%s@%d", filepath.Base(n.archive.Path), n.depth))
+	}
+	return list
+}
+
+func TestNestedDepthLimit(t *testing.T) {
+	archives := map[string]fakeArchive{
+		"outer.zip": {children: map[string]string{"a.zip": "a"}},
+		"a.zip":     {children: map[string]string{"b.zip": "b", "readme.txt": "x"}},
+		"b.zip":     {children: map[string]string{"c.zip": "c"}},
+		"c.zip":     {children: map[string]string{"d.zip": "d"}},
+	}
+	tests := []struct {
+		depth int
+		want  []string
+	}{
+		{0, nil},
+		{1, []string{"a.zip@1"}},
+		{2, []string{"a.zip@1", "b.zip@2"}},
+		{5, []string{"a.zip@1", "b.zip@2", "c.zip@3", "d.zip@4"}},
+	}
+	for _, tt := range tests {
+		f := newFakeNested(t, archives)
+		if got := names(f.run(tt.depth, "outer", "")); !slices.Equal(got, tt.want) {
+			t.Errorf("层数上限 %d: 解压了 %v，应为 %v", tt.depth, got, tt.want)
+		}
+	}
+}
+
+// 压缩包包含自身的副本，或不同层中出现内容相同的压缩包时，只解压一次
+func TestNestedLoop(t *testing.T) {
+	f := newFakeNested(t, map[string]fakeArchive{
+		"outer.zip": {children: map[string]string{"copy.zip": "outer", "a.zip": "a"}},
+		"a.zip":     {children: map[string]string{"again.zip": "a"}},
+	})
+	r := f.run(10, "outer", "")
+	want := []string{"a.zip@1", "again.zip@2", "copy.zip@1"}
+	if got := names(r); !slices.Equal(got, want) {
+		t.Fatalf("解压结果 %v，应为 %v", got, want)
+	}
+	for _, n := range r.list {
+		name := filepath.Base(n.archive.Path)
+		if repeated := errors.Is(n.err, errRepeatedArchive); repeated != (name != "a.zip") {
+			t.Errorf("%s: err = %v", name, n.err)
+		}
+	}
+	if _, ok := f.parents["copy.zip"]; ok {
+		t.Error("与外层内容相同的压缩包不应再次解压")
+	}
+	if r.success() != 1 {
+		t.Errorf("success() = %d，应为 1", r.success())
+	}
+}
+
+func TestNestedLimit(t *testing.T) {
+	children := make(map[string]string)
+	for i := range maxNestedArchives + 5 {
+		children[fmt.Sprintf("%03d.zip", i)] = fmt.Sprint(i)
+	}
+	f := newFakeNested(t, map[string]fakeArchive{"outer.zip": {children: children}})
+	r := f.run(1, "outer", "")
+	if len(r.list) != maxNestedArchives || !r.limited {
+		t.Errorf("解压了 %d 个，limited = %v，应为 %d 个且 limited", len(r.list), r.limited, maxNestedArchives)
+	}
+	if len(f.parents) != maxNestedArchives {
+		t.Errorf("调用解压 %d 次，应为 %d 次", len(f.parents), maxNestedArchives)
+	}
+}
+
+// 先尝试上一层的密码，上一层没有加密时沿用更外层的密码
+func TestNestedParentPassword(t *testing.T) {
+	f := newFakeNested(t, map[string]fakeArchive{
+		"outer.zip":  {children: map[string]string{"plain.zip": "plain"}},
+		"plain.zip":  {children: map[string]string{"locked.zip": "locked"}},
+		"locked.zip": {password: "inner", children: map[string]string{"last.zip": "last"}},
+	})
+	f.run(10, "outer", "outer-password")
+	want := map[string]string{"plain.zip": "outer-password", "locked.zip": "outer-password", "last.zip": "inner"}
+	for name, parent := range want {
+		if got := f.parents[name]; got != parent {
+			t.Errorf("%s: 先尝试的密码为 %q，应为 %q", name, got, parent)
+		}
+	}
+}
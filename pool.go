@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// runPool 使用 workers 个协程并发处理 total 个任务。
+// work 在工作协程中执行，worker 为协程编号 (0 ~ workers-1)，index 为任务序号；
+// emit 在调用方协程中按任务序号从小到大依次调用，保证输出顺序与任务顺序一致。
+// ctx 被取消后不再分配新的任务，已经开始的任务照常输出结果，因此输出的总是序号最小的若干个任务。
+func runPool[R any](ctx context.Context, workers, total int,
+	work func(ctx context.Context, worker, index int) R,
+	emit func(index int, result R)) {
+
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > total {
+		workers = total
+	}
+
+	type outcome struct {
+		index  int
+		result R
+	}
+
+	jobs := make(chan int)
+	outcomes := make(chan outcome, workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for index := range jobs {
+				outcomes <- outcome{index: index, result: work(ctx, worker, index)}
+			}
+		}(w)
+	}
+
+	go func() {
+	feed:
+		for i := 0; i < total && ctx.Err() == nil; i++ {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	// 先完成的任务暂存起来，等到前面的任务都输出后再按顺序输出
+	pending := make(map[int]R)
+	next := 0
+	for o := range outcomes {
+		pending[o.index] = o.result
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			emit(next, r)
+			next++
+		}
+	}
+}
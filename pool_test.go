@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 各任务耗时随机，完成的顺序与序号不同，emit 仍然按序号依次调用
+func TestRunPoolOrder(t *testing.T) {
+	const total, workers = 40, 6
+	rng := rand.New(rand.NewPCG(1, 2))
+	delays := make([]time.Duration, total)
+	for i := range delays {
+		delays[i] = time.Duration(rng.IntN(5000)) * time.Microsecond
+	}
+	delays[0] = 20 * time.Millisecond // 保证第一个任务最后完成
+
+	var mu sync.Mutex
+	var finished []int
+	var emitted []int
+	runPool(context.Background(), workers, total,
+		func(ctx context.Context, worker, index int) int {
+			if worker < 0 || worker >= workers {
+				t.Errorf("协程编号 %d 超出范围", worker)
+			}
+			time.Sleep(delays[index])
+			mu.Lock()
+			finished = append(finished, index)
+			mu.Unlock()
+			return index * 10
+		},
+		func(index int, result int) {
+			if result != index*10 {
+				t.Errorf("任务 %d 的结果为 %d，应为 %d", index, result, index*10)
+			}
+			emitted = append(emitted, index)
+		})
+
+	if len(emitted) != total {
+		t.Fatalf("输出了 %d 个结果，应为 %d 个", len(emitted), total)
+	}
+	for i, index := range emitted {
+		if index != i {
+			t.Fatalf("第 %d 个输出的是任务 %d: %v", i, index, emitted)
+		}
+	}
+	if finished[0] == 0 {
+		t.Error("任务 0 应当最后完成，测试没有覆盖乱序完成的情况")
+	}
+}
+
+// ctx 被取消后不再分配新的任务，已经开始的任务照常输出，输出的是序号最小的若干个任务
+func TestRunPoolCancel(t *testing.T) {
+	const total, workers, stopAt = 100, 3, 5
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	started := make(map[int]bool)
+	var emitted []int
+	runPool(ctx, workers, total,
+		func(ctx context.Context, worker, index int) int {
+			mu.Lock()
+			started[index] = true
+			mu.Unlock()
+			if index == stopAt {
+				cancel()
+			}
+			time.Sleep(time.Millisecond)
+			return index
+		},
+		func(index int, result int) {
+			emitted = append(emitted, index)
+		})
+
+	// 取消时每个协程最多还有一个任务在执行，分配任务的协程最多再送出一个
+	if len(started) > stopAt+1+workers {
+		t.Errorf("取消后仍然开始了 %d 个任务", len(started))
+	}
+	if len(emitted) != len(started) {
+		t.Errorf("开始了 %d 个任务，输出了 %d 个", len(started), len(emitted))
+	}
+	for i, index := range emitted {
+		if index != i {
+			t.Fatalf("输出的不是序号最小的任务: %v", emitted)
+		}
+	}
+}
+
+func TestRunPoolEdgeCases(t *testing.T) {
+	for _, tt := range []struct{ workers, total int }{{4, 0}, {0, 3}, {10, 2}} {
+		count := 0
+		runPool(context.Background(), tt.workers, tt.total,
+			func(ctx context.Context, worker, index int) int { return index },
+			func(index int, result int) { count++ })
+		if count != tt.total {
+			t.Errorf("workers = %d, total = %d: 输出了 %d 个结果", tt.workers, tt.total, count)
+		}
+	}
+}
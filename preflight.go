@@ -0,0 +1,167 @@
+package main
+
+import (
+	"ArchiveTools/cracker"
+	"ArchiveTools/display"
+	"ArchiveTools/extract"
+	"ArchiveTools/utils"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// archiveSize 返回压缩包全部分卷的总大小，自解压程序不计入前面的程序部分，用于计算整体的压缩比
+func archiveSize(archive utils.Archive) int64 {
+	var size int64
+	for _, v := range archive.Volumes {
+		if info, err := os.Stat(v); err == nil {
+			size += info.Size()
+		}
+	}
+	return max(size-archive.Offset, 0)
+}
+
+// listing 是解压前检查时一个压缩包的文件列表汇总
+type listing struct {
+	listed bool  // 是否成功列出了文件
+	size   int64 // 解压后的总大小
+	known  bool  // 所有条目的大小都已知
+	bombs  []extract.Issue
+}
+
+// diskUsage 是解压前检查时一个磁盘上所有压缩包的汇总
+type diskUsage struct {
+	dir      string // 第一个解压到该磁盘的压缩包所在的文件夹，用于显示
+	size     int64
+	archives int
+	free     uint64
+}
+
+// preflight 在开始解压前列出各个压缩包中的文件，按目标磁盘汇总解压后的总大小并与剩余空间比较，
+// 同时标记压缩比异常、可能是压缩炸弹的压缩包。解压到的位置总是在压缩包所在的文件夹中，因此按压缩包所在的磁盘汇总。
+// 加密了文件名的压缩包在缓存中没有密码时无法列出文件，压缩的 tar 包需要完整解压一遍才能列出文件，
+// 这些压缩包留到解压时再逐个检查。空间不足且设置为 SpaceRefuse 时返回 extract.ErrNoSpace，
+// 列出文件时按下 Ctrl-C 返回 errInterrupted，这两种情况下任务都不再开始
+func preflight(opts taskOptions, candidates candidateSet, archives []utils.Archive, pending []int) error {
+	if opts.Space == extract.SpaceOff && opts.MaxRatio <= 0 {
+		return nil
+	}
+
+	board := display.NewProgressBoard(opts.Workers)
+	ctx, stop := interruptContext(func() { board.Println(printInterruptNotice) })
+	listings := make([]listing, len(pending))
+	runPool(ctx, opts.Workers, len(pending),
+		func(ctx context.Context, worker, j int) listing {
+			archive := archives[pending[j]]
+			if archive.Format.Tar || checkVolumes(archive) != nil {
+				return listing{}
+			}
+			prefix, name := progressLabel(pending[j], len(archives), archive)
+			board.Update(worker, fmt.Sprintf("%s %s 正在列出文件...", prefix, name))
+			defer board.Update(worker, "")
+
+			c, err := cracker.NewCracker(archive, cracker.AccurateMode)
+			if err != nil {
+				return listing{}
+			}
+			_, password, _ := candidates.lookup(archive)
+			entries, err := c.ListEntries(ctx, password)
+			if err != nil {
+				return listing{}
+			}
+			size, known := extract.Size(entries)
+			return listing{
+				listed: true,
+				size:   size,
+				known:  known,
+				bombs:  extract.Bombs(entries, archiveSize(archive), opts.MaxRatio),
+			}
+		},
+		func(j int, l listing) {
+			listings[j] = l
+		})
+	interrupted := ctx.Err() != nil // stop 会取消 ctx，需要在调用 stop 之前判断
+	stop()
+	board.Close()
+	if interrupted {
+		display.PrintWarning("解压前检查被中断，任务没有开始。")
+		return errInterrupted
+	}
+
+	display.PrintSection("解压前检查")
+	var total int64
+	listed, unknown := 0, 0
+	disks := make(map[string]*diskUsage)
+	var order []string
+	for j, l := range listings {
+		if !l.listed {
+			unknown++
+			continue
+		}
+		listed++
+		total += l.size
+		if !l.known {
+			unknown++
+		}
+		dir := filepath.Dir(archives[pending[j]].Path)
+		volume, free, err := utils.DiskSpace(dir)
+		if err != nil {
+			continue
+		}
+		d := disks[volume]
+		if d == nil {
+			d = &diskUsage{dir: dir, free: free}
+			disks[volume] = d
+			order = append(order, volume)
+		}
+		d.size += l.size
+		d.archives++
+	}
+	display.PrintFieldValue("已列出文件", fmt.Sprintf("%d 个压缩包，解压后共 %s", listed, utils.FormatSize(total)))
+	if unknown > 0 {
+		display.PrintFieldValue("无法预先估算", fmt.Sprintf("%d 个 (加密了文件名、压缩的 tar 包或大小未知，解压时再逐个检查)", unknown))
+	}
+
+	short := 0
+	if opts.Space != extract.SpaceOff {
+		for _, volume := range order {
+			d := disks[volume]
+			text := fmt.Sprintf("%s 所在的磁盘: %d 个压缩包需要 %s，剩余 %s", d.dir, d.archives, utils.FormatSize(d.size), utils.FormatSize(int64(d.free)))
+			if uint64(d.size) > d.free {
+				short++
+				display.PrintWarning(text + "，空间不足")
+			} else {
+				display.PrintInfo(text)
+			}
+		}
+	}
+
+	suspicious := 0
+	for j, l := range listings {
+		if len(l.bombs) == 0 {
+			continue
+		}
+		suspicious++
+		prefix, name := progressLabel(pending[j], len(archives), archives[pending[j]])
+		display.PrintWarning(fmt.Sprintf("%s %s -> 压缩比异常", prefix, name))
+		for _, issue := range l.bombs {
+			display.PrintWarning(fmt.Sprintf("  └─> %s", issue))
+		}
+	}
+	display.PrintSectionEnd()
+	display.PrintEmptyLine()
+
+	if suspicious > 0 {
+		display.PrintWarning(fmt.Sprintf("%d 个压缩包的压缩比超过 %g:1，可能是压缩炸弹，请确认后再解压。", suspicious, opts.MaxRatio))
+	}
+	if short == 0 {
+		return nil
+	}
+	if opts.Space == extract.SpaceRefuse {
+		display.PrintError("目标磁盘的剩余空间不足，任务没有开始。请清理磁盘后重试，或使用 -space warn 照常解压。")
+		return extract.ErrNoSpace
+	}
+	display.PrintWarning("目标磁盘的剩余空间可能不足，仍然开始解压，解压每个压缩包前会再次检查。")
+	return nil
+}
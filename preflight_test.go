@@ -0,0 +1,39 @@
+package main
+
+import (
+	"ArchiveTools/utils"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 整体的压缩比按全部分卷的大小计算，自解压程序不计入前面的程序部分
+func TestArchiveSize(t *testing.T) {
+	dir := t.TempDir()
+	var volumes []string
+	for i, size := range []int{100, 50} {
+		p := filepath.Join(dir, "a.7z.00"+string(rune('1'+i)))
+		if err := os.WriteFile(p, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		volumes = append(volumes, p)
+	}
+	missing := filepath.Join(dir, "a.7z.003")
+
+	tests := []struct {
+		name    string
+		archive utils.Archive
+		want    int64
+	}{
+		{"单个文件", utils.Archive{Volumes: volumes[:1]}, 100},
+		{"分卷", utils.Archive{Volumes: volumes}, 150},
+		{"缺少的分卷不计入", utils.Archive{Volumes: append(volumes[:2:2], missing)}, 150},
+		{"自解压程序", utils.Archive{Volumes: volumes[:1], Offset: 40}, 60},
+		{"偏移超出文件大小", utils.Archive{Volumes: volumes[:1], Offset: 200}, 0},
+	}
+	for _, tt := range tests {
+		if got := archiveSize(tt.archive); got != tt.want {
+			t.Errorf("%s: archiveSize() = %d，应为 %d", tt.name, got, tt.want)
+		}
+	}
+}
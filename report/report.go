@@ -0,0 +1,151 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format 是结果文件的格式
+type Format string
+
+const (
+	Text  Format = "text"  // 便于阅读的文本，只记录找到的密码
+	JSONL Format = "jsonl" // 每行一个 JSON 对象
+	CSV   Format = "csv"   // 第一行为列名
+)
+
+// ParseFormat 解析结果文件格式的名称，不区分大小写
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case Text, JSONL, CSV:
+		return f, nil
+	}
+	return "", fmt.Errorf("不支持的结果格式 '%s'，可选 text、jsonl 或 csv", name)
+}
+
+// Ext 返回该格式结果文件的扩展名
+func (f Format) Ext() string {
+	if f == Text {
+		return ".txt"
+	}
+	return "." + string(f)
+}
+
+// Status 是单个压缩包的处理结果
+type Status string
+
+const (
+	Found        Status = "found"         // 找到了密码 (解压时还表示解压成功)
+	NotEncrypted Status = "not-encrypted" // 压缩包未加密
+	NotFound     Status = "not-found"     // 候选密码中没有正确的密码
+	Error        Status = "error"         // 处理过程中出错，例如缺少分卷、解压失败
+)
+
+// Record 是结果文件中的一条记录，对应一个压缩包
+type Record struct {
+	Path     string
+	Format   string // 压缩包格式的显示名称
+	Status   Status
+	Password string
+	Elapsed  time.Duration
+	Tried    int // 本次实际尝试过的候选密码数量，使用缓存中的密码时为 0
+	Error    string
+}
+
+// jsonRecord 是 JSON Lines 中每一行的内容，耗时以秒为单位，精确到毫秒
+type jsonRecord struct {
+	Path     string  `json:"path"`
+	Format   string  `json:"format"`
+	Status   Status  `json:"status"`
+	Password string  `json:"password"`
+	Elapsed  float64 `json:"elapsed"`
+	Tried    int     `json:"tried"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// csvHeader 是 CSV 文件的列名，与 jsonRecord 的字段一致
+var csvHeader = []string{"path", "format", "status", "password", "elapsed", "tried", "error"}
+
+// Writer 把处理结果逐条写入结果文件，每条记录都立即写入文件，任务中断时不会丢失已有的结果。
+// 不能被多个协程同时使用
+type Writer struct {
+	file   *os.File
+	format Format
+	csv    *csv.Writer
+}
+
+// Open 打开结果文件，文件已存在时在末尾追加记录。CSV 文件只在新建 (为空) 时写入列名
+func Open(path string, format Format) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	w := &Writer{file: f, format: format}
+	if format == CSV {
+		w.csv = csv.NewWriter(f)
+		info, err := f.Stat()
+		if err == nil && info.Size() == 0 {
+			w.csv.Write(csvHeader)
+			w.csv.Flush()
+			err = w.csv.Error()
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Name 返回结果文件的路径
+func (w *Writer) Name() string { return w.file.Name() }
+
+// Write 写入一条记录。文本格式只记录找到的密码，与之前的结果文件保持一致
+func (w *Writer) Write(r Record) error {
+	switch w.format {
+	case JSONL:
+		data, err := json.Marshal(jsonRecord{
+			Path:     r.Path,
+			Format:   r.Format,
+			Status:   r.Status,
+			Password: r.Password,
+			Elapsed:  math.Round(r.Elapsed.Seconds()*1000) / 1000,
+			Tried:    r.Tried,
+			Error:    r.Error,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = w.file.Write(append(data, '\n'))
+		return err
+	case CSV:
+		w.csv.Write([]string{
+			r.Path,
+			r.Format,
+			string(r.Status),
+			r.Password,
+			strconv.FormatFloat(r.Elapsed.Seconds(), 'f', 3, 64),
+			strconv.Itoa(r.Tried),
+			r.Error,
+		})
+		w.csv.Flush()
+		return w.csv.Error()
+	default:
+		if r.Status != Found {
+			return nil
+		}
+		_, err := fmt.Fprintf(w.file, "文件: %s\n密码: %s\n%s\n", r.Path, r.Password, strings.Repeat("-", 20))
+		return err
+	}
+}
+
+// Close 关闭结果文件
+func (w *Writer) Close() error {
+	return w.file.Close()
+}
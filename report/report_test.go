@@ -0,0 +1,92 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testRecords = []Record{
+	{Path: `E:\下载\a.zip`, Format: "ZIP", Status: Found, Password: "p,w\"d", Elapsed: 1234567 * time.Microsecond, Tried: 42},
+	{Path: "/data/b.7z", Format: "7z", Status: NotFound, Elapsed: 1500 * time.Microsecond, Tried: 1000},
+	{Path: "/data/c.rar", Format: "RAR", Status: Error, Error: "缺少分卷: c.part2.rar"},
+}
+
+// writeRecords 打开 path 写入 records 后关闭，返回文件的全部内容
+func writeRecords(t *testing.T, path string, format Format, records []Record) string {
+	t.Helper()
+	w, err := Open(path, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestParseFormat(t *testing.T) {
+	for name, want := range map[string]Format{"text": Text, "JSONL": JSONL, "Csv": CSV} {
+		if got, err := ParseFormat(name); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v，应为 %q", name, got, err, want)
+		}
+	}
+	for _, name := range []string{"", "json", "txt", "xml"} {
+		if _, err := ParseFormat(name); err == nil {
+			t.Errorf("ParseFormat(%q) 应当返回错误", name)
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "result.csv")
+	writeRecords(t, path, CSV, testRecords[:1])
+	// 继续任务时追加记录，不再写入列名
+	got := writeRecords(t, path, CSV, testRecords[1:])
+	want := "path,format,status,password,elapsed,tried,error\n" +
+		`E:\下载\a.zip,ZIP,found,"p,w""d",1.235,42,` + "\n" +
+		"/data/b.7z,7z,not-found,,0.002,1000,\n" +
+		"/data/c.rar,RAR,error,,0.000,0,缺少分卷: c.part2.rar\n"
+	if got != want {
+		t.Errorf("CSV 文件的内容为\n%s\n应为\n%s", got, want)
+	}
+}
+
+func TestWriteJSONL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "result.jsonl")
+	got := writeRecords(t, path, JSONL, testRecords)
+	want := `{"path":"E:\\下载\\a.zip","format":"ZIP","status":"found","password":"p,w\"d","elapsed":1.235,"tried":42}` + "\n" +
+		`{"path":"/data/b.7z","format":"7z","status":"not-found","password":"","elapsed":0.002,"tried":1000}` + "\n" +
+		`{"path":"/data/c.rar","format":"RAR","status":"error","password":"","elapsed":0,"tried":0,"error":"缺少分卷: c.part2.rar"}` + "\n"
+	if got != want {
+		t.Errorf("JSON Lines 文件的内容为\n%s\n应为\n%s", got, want)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "result.txt")
+	got := writeRecords(t, path, Text, testRecords)
+	want := "文件: E:\\下载\\a.zip\n密码: p,w\"d\n" + strings.Repeat("-", 20) + "\n"
+	if got != want {
+		t.Errorf("文本文件的内容为\n%s\n应为\n%s", got, want)
+	}
+}
+
+func TestFormatExt(t *testing.T) {
+	for f, want := range map[Format]string{Text: ".txt", JSONL: ".jsonl", CSV: ".csv"} {
+		if got := f.Ext(); got != want {
+			t.Errorf("%s.Ext() = %q，应为 %q", f, got, want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"ArchiveTools/display"
+	"ArchiveTools/format"
+	"ArchiveTools/store"
+	"ArchiveTools/utils"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// defaultSessionFile 保存任务进度，任务全部完成后删除
+const defaultSessionFile = "session.json"
+
+// checkpoint 把单个压缩包的查找进度写入会话，session 为 nil 时不记录进度
+type checkpoint struct {
+	session *store.Session
+	index   int
+}
+
+// start 返回排在密码本之前的密码和排在密码本最前面的密码：恢复任务时使用上次记录的结果，
+// 否则调用 lead 和 rank 并记录结果，保证记录的进度仍然对应同样的候选密码序列
+func (c checkpoint) start(lead, rank func() []string) (leading, first []string) {
+	if c.session == nil {
+		return lead(), rank()
+	}
+	if a := c.session.Archive(c.index); a.Started {
+		return a.Leading, a.First
+	}
+	leading, first = lead(), rank()
+	c.session.Start(c.index, leading, first)
+	return leading, first
+}
+
+// next 返回上次中断时候选密码序列中下一个要尝试的序号
+func (c checkpoint) next() int {
+	if c.session == nil {
+		return 0
+	}
+	return c.session.Archive(c.index).Next
+}
+
+// save 记录查找进度，可以直接作为 cracker.SearchOptions 的 OnCheckpoint
+func (c checkpoint) save(next int) {
+	if c.session != nil {
+		c.session.Checkpoint(c.index, next)
+	}
+}
+
+// finish 记录压缩包的处理结果，写入失败时只给出警告，需要在输出结果的位置调用
+func (c checkpoint) finish(result store.SessionArchive) {
+	if c.session == nil {
+		return
+	}
+	if err := c.session.Finish(c.index, result); err != nil {
+		display.PrintWarning(fmt.Sprintf("无法保存任务进度: %v", err))
+	}
+}
+
+// startSession 为新的任务创建会话文件，opts.SessionFile 为空时不记录进度
+// 会话中的路径都转换为绝对路径，以便在其他目录下恢复任务
+func startSession(opts taskOptions, command, fingerprint, resultFile string, archives []utils.Archive) (*store.Session, error) {
+	if opts.SessionFile == "" {
+		return nil, nil
+	}
+	if _, err := os.Stat(opts.RulesFile); err != nil {
+		// 默认的规则文件不存在时没有使用规则
+		opts.RulesFile = ""
+	}
+	for _, path := range []*string{&opts.TargetPath, &opts.PasswordsFile, &opts.RulesFile, &opts.ResultDir, &opts.StatsFile, &opts.CacheFile, &opts.Cleanup.DoneDir, &opts.Cleanup.FailedDir} {
+		if *path != "" {
+			*path = absPath(*path)
+		}
+	}
+	if resultFile != "" {
+		resultFile = absPath(resultFile)
+	}
+	saved := make([]store.SessionArchive, len(archives))
+	for i, a := range archives {
+		saved[i] = store.SessionArchive{
+			Path:    absPath(a.Path),
+			Format:  a.Format.Name,
+			Name:    a.Name,
+			Missing: a.Missing,
+			Offset:  a.Offset,
+		}
+		for _, v := range a.Volumes {
+			saved[i].Volumes = append(saved[i].Volumes, absPath(v))
+		}
+	}
+	return store.CreateSession(opts.SessionFile, command, opts, fingerprint, resultFile, saved)
+}
+
+// restoreArchives 从会话中恢复压缩包列表
+func restoreArchives(session *store.Session) ([]utils.Archive, error) {
+	saved := session.Archives()
+	archives := make([]utils.Archive, len(saved))
+	for i, a := range saved {
+		f := format.ByName(a.Format)
+		if f == nil {
+			return nil, fmt.Errorf("会话中的压缩包 '%s' 格式未知: %s", a.Path, a.Format)
+		}
+		archives[i] = utils.Archive{
+			Path:    a.Path,
+			Format:  f,
+			Name:    a.Name,
+			Volumes: a.Volumes,
+			Missing: a.Missing,
+			Offset:  a.Offset,
+		}
+	}
+	return archives, nil
+}
+
+// pendingArchives 返回还没有处理完的压缩包序号，session 为 nil 时返回全部序号
+func pendingArchives(session *store.Session, total int) []int {
+	var pending []int
+	for i := range total {
+		if session == nil || !session.Archive(i).Done {
+			pending = append(pending, i)
+		}
+	}
+	return pending
+}
+
+// finishSession 在任务全部完成后删除会话文件
+func finishSession(session *store.Session) {
+	if session == nil {
+		return
+	}
+	if err := session.Remove(); err != nil {
+		display.PrintWarning(fmt.Sprintf("无法删除任务进度文件: %v", err))
+	}
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
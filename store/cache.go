@@ -0,0 +1,169 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// hashSampleSize 是计算缓存键时从每个分卷开头和结尾读取的字节数
+const hashSampleSize = 64 << 10
+
+// CacheEntry 是一个压缩包的缓存记录
+type CacheEntry struct {
+	Password string    `json:"password,omitempty"` // 找到的密码，还没有找到时为空
+	Tried    []Attempt `json:"tried,omitempty"`    // 尝试过但没有找到密码的候选密码
+	Path     string    `json:"path"`               // 记录时压缩包的位置，仅供查看
+	Time     time.Time `json:"time"`
+}
+
+// Attempt 记录一组没有找到密码的候选密码
+type Attempt struct {
+	Fingerprint string    `json:"fingerprint"` // 候选密码的指纹，内容相同的密码本指纹相同
+	Label       string    `json:"label"`       // 供查看的说明，例如密码本的文件名和密码数量
+	Time        time.Time `json:"time"`
+}
+
+// Cache 是以压缩包内容为键的密码缓存，压缩包被移动或重命名后仍然可以找到记录。
+// 可以被多个协程同时使用。
+type Cache struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]*CacheEntry
+	dirty   bool
+}
+
+// cacheFile 是缓存文件的内容
+type cacheFile struct {
+	Archives map[string]*CacheEntry `json:"archives"`
+}
+
+// OpenCache 读取缓存文件，文件不存在时返回一个空的缓存，保存时再创建文件
+func OpenCache(path string) (*Cache, error) {
+	var f cacheFile
+	if err := readJSON(path, &f); err != nil {
+		return nil, err
+	}
+	if f.Archives == nil {
+		f.Archives = make(map[string]*CacheEntry)
+	}
+	return &Cache{path: path, entries: f.Archives}, nil
+}
+
+// Key 根据压缩包全部分卷的大小以及开头和结尾各 64KB 的内容计算缓存键，不需要读取整个文件
+func Key(volumes []string) (string, error) {
+	h := sha256.New()
+	buf := make([]byte, hashSampleSize)
+	var total int64
+	for _, path := range volumes {
+		size, err := hashSample(h, path, buf)
+		if err != nil {
+			return "", err
+		}
+		total += size
+	}
+	return fmt.Sprintf("%d-%x", total, h.Sum(nil)[:16]), nil
+}
+
+// hashSample 把文件的大小以及开头和结尾的内容写入 h，返回文件大小
+func hashSample(h io.Writer, path string, buf []byte) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+	binary.Write(h, binary.LittleEndian, size)
+
+	n, err := f.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	h.Write(buf[:n])
+	if size > int64(len(buf)) {
+		tail := max(int64(len(buf)), size-int64(len(buf)))
+		n, err := f.ReadAt(buf[:size-tail], tail)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		h.Write(buf[:n])
+	}
+	return size, nil
+}
+
+// Lookup 返回缓存键对应的记录
+func (c *Cache) Lookup(key string) (CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return CacheEntry{}, false
+	}
+	entry := *e
+	entry.Tried = slices.Clone(e.Tried)
+	return entry, true
+}
+
+// SetPassword 记录压缩包的密码
+func (c *Cache) SetPassword(key, archivePath, password string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.entry(key, archivePath)
+	e.Password = password
+	e.Tried = nil
+}
+
+// AddAttempt 记录用指纹为 fingerprint 的候选密码尝试过压缩包但没有找到密码
+func (c *Cache) AddAttempt(key, archivePath, fingerprint, label string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.entry(key, archivePath)
+	e.Tried = slices.DeleteFunc(e.Tried, func(a Attempt) bool { return a.Fingerprint == fingerprint })
+	e.Tried = append(e.Tried, Attempt{Fingerprint: fingerprint, Label: label, Time: time.Now()})
+}
+
+// entry 返回 key 对应的记录并更新它的位置和时间，调用方需持有锁
+func (c *Cache) entry(key, archivePath string) *CacheEntry {
+	e, ok := c.entries[key]
+	if !ok {
+		e = &CacheEntry{}
+		c.entries[key] = e
+	}
+	if abs, err := filepath.Abs(archivePath); err == nil {
+		archivePath = abs
+	}
+	e.Path = archivePath
+	e.Time = time.Now()
+	c.dirty = true
+	return e
+}
+
+// HasTried 判断是否已经用指纹为 fingerprint 的候选密码尝试过
+func (e CacheEntry) HasTried(fingerprint string) bool {
+	return slices.ContainsFunc(e.Tried, func(a Attempt) bool { return a.Fingerprint == fingerprint })
+}
+
+// Save 在有新记录时写回缓存文件
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	if err := writeJSON(c.path, cacheFile{Archives: c.entries}); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testFile 创建 size 字节、内容不重复的文件
+func testFile(t *testing.T, dir, name string, size int) string {
+	t.Helper()
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i*31 + i>>9)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func mustKey(t *testing.T, volumes ...string) string {
+	t.Helper()
+	key, err := Key(volumes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// modify 修改文件中 offset 处的一个字节
+func modify(t *testing.T, path string, offset int64) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b := make([]byte, 1)
+	f.ReadAt(b, offset)
+	b[0] ^= 0xFF
+	if _, err := f.WriteAt(b, offset); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeyStableAcrossRename(t *testing.T) {
+	dir := t.TempDir()
+	path := testFile(t, dir, "a.zip", 300<<10)
+	key := mustKey(t, path)
+
+	renamed := filepath.Join(dir, "sub", "重命名.zip")
+	os.MkdirAll(filepath.Dir(renamed), 0755)
+	if err := os.Rename(path, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustKey(t, renamed); got != key {
+		t.Errorf("重命名后缓存键为 %s，应为 %s", got, key)
+	}
+}
+
+func TestKeyChanges(t *testing.T) {
+	const size = 300 << 10
+	tests := []struct {
+		name    string
+		size    int
+		offset  int64 // 修改的位置，为 -1 时在末尾追加一个字节
+		changed bool
+	}{
+		{"追加内容", size, -1, true},
+		{"修改开头", size, 0, true},
+		{"修改开头 64KB 的最后一个字节", size, hashSampleSize - 1, true},
+		{"修改结尾 64KB 的第一个字节", size, size - hashSampleSize, true},
+		{"修改最后一个字节", size, size - 1, true},
+		// 只读取开头和结尾各 64KB，中间的内容不参与计算
+		{"修改中间", size, size / 2, false},
+		{"小于 128KB 的文件修改中间", 100 << 10, 50 << 10, true},
+		{"小于 64KB 的文件修改结尾", 1000, 999, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := testFile(t, t.TempDir(), "a.rar", tt.size)
+			before := mustKey(t, path)
+			if tt.offset < 0 {
+				f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+				f.Write([]byte{0})
+				f.Close()
+			} else {
+				modify(t, path, tt.offset)
+			}
+			if after := mustKey(t, path); (after != before) != tt.changed {
+				t.Errorf("修改前后的缓存键为 %s 和 %s，应当 changed=%v", before, after, tt.changed)
+			}
+		})
+	}
+}
+
+func TestKeyVolumes(t *testing.T) {
+	dir := t.TempDir()
+	part1 := testFile(t, dir, "a.part1.rar", 200<<10)
+	part2 := testFile(t, dir, "a.part2.rar", 10<<10)
+	modify(t, part2, 0) // 与 part1 开头的内容不同
+
+	key := mustKey(t, part1, part2)
+	if key == mustKey(t, part2, part1) {
+		t.Error("分卷的顺序不同时缓存键应当不同")
+	}
+	if key == mustKey(t, part1) {
+		t.Error("缺少分卷时缓存键应当不同")
+	}
+	if want := "215040-"; key[:len(want)] != want {
+		t.Errorf("缓存键 %s 应以全部分卷的总大小开头", key)
+	}
+
+	empty := testFile(t, dir, "empty.zip", 0)
+	if key := mustKey(t, empty); key[:2] != "0-" {
+		t.Errorf("空文件的缓存键为 %s", key)
+	}
+	if _, err := Key([]string{part1, filepath.Join(dir, "a.part3.rar")}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("分卷不存在时 Key() 返回 %v", err)
+	}
+}
+
+func TestCacheSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	c, err := OpenCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Error("没有新记录时不应创建缓存文件")
+	}
+
+	c.AddAttempt("k1", "a.zip", "fp1", "passwords.txt (100 个)")
+	c.AddAttempt("k1", "a.zip", "fp2", "other.txt (5 个)")
+	c.AddAttempt("k1", "moved/a.zip", "fp1", "passwords.txt (100 个)") // 同一个指纹只记录一次
+	c.AddAttempt("k2", "b.zip", "fp1", "passwords.txt (100 个)")
+	c.SetPassword("k2", "b.zip", "secret") // 找到密码后不再需要尝试记录
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = OpenCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, ok := c.Lookup("k1")
+	if !ok || len(e.Tried) != 2 || !e.HasTried("fp1") || !e.HasTried("fp2") || e.HasTried("fp3") || e.Password != "" {
+		t.Errorf("k1 的记录为 %+v", e)
+	}
+	if abs, _ := filepath.Abs("moved/a.zip"); e.Path != abs {
+		t.Errorf("k1 的位置为 %s，应为最后一次记录的 %s", e.Path, abs)
+	}
+	if e, ok := c.Lookup("k2"); !ok || e.Password != "secret" || e.HasTried("fp1") {
+		t.Errorf("k2 的记录为 %+v", e)
+	}
+	if _, ok := c.Lookup("k3"); ok {
+		t.Error("不存在的缓存键返回了记录")
+	}
+
+	// Lookup 返回的是副本，修改它不影响缓存
+	e.Tried[0].Fingerprint = "changed"
+	if e, _ := c.Lookup("k1"); !e.HasTried("fp1") {
+		t.Error("修改 Lookup 的结果影响了缓存中的记录")
+	}
+}
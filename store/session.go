@@ -0,0 +1,188 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// sessionSaveInterval 是尝试密码过程中写回会话文件的最短间隔，压缩包处理完时总是立即写回
+const sessionSaveInterval = 5 * time.Second
+
+// SessionArchive 是任务中的一个压缩包及其进度
+type SessionArchive struct {
+	Path    string   `json:"path"`
+	Format  string   `json:"format"` // 格式的显示名称
+	Name    string   `json:"name"`
+	Volumes []string `json:"volumes"`
+	Missing []string `json:"missing,omitempty"`
+	Offset  int64    `json:"offset,omitempty"`
+
+	// Next 是候选密码序列中下一个要尝试的序号，之前的候选密码都已确认不正确
+	Next int `json:"next,omitempty"`
+	// First 是开始查找时排在密码本最前面的历史命中密码，恢复时按同样的顺序排列候选密码
+	First []string `json:"first,omitempty"`
+	// Leading 是开始查找时排在密码本之前的密码 (上一层压缩包的密码和从上下文中提取的密码)，
+	// 恢复时直接使用，不再重新提取，说明文件或注释改变后 Next 仍然对应同样的候选密码序列
+	Leading []string `json:"leading,omitempty"`
+	// Started 表示已经开始查找密码，First、Leading 和 Next 有效
+	Started bool `json:"started,omitempty"`
+
+	// 以下字段在压缩包处理完后写入
+	Done      bool   `json:"done,omitempty"`
+	Success   bool   `json:"success,omitempty"`   // 找到密码、无需密码或解压成功
+	Encrypted bool   `json:"encrypted,omitempty"` // 压缩包是否需要密码
+	Password  string `json:"password,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Skipped   int    `json:"skipped,omitempty"` // 因为目标位置存在同名文件而没有解压的条目数量
+}
+
+// Session 记录一次任务的参数和每个压缩包的进度，任务中断后可以从记录的位置继续。
+// 可以被多个协程同时使用。
+type Session struct {
+	path string
+
+	mu    sync.Mutex
+	data  sessionFile
+	saved time.Time
+}
+
+// sessionFile 是会话文件的内容
+type sessionFile struct {
+	Command     string           `json:"command"`     // 子命令，match 或 extract
+	Options     json.RawMessage  `json:"options"`     // 任务参数
+	Fingerprint string           `json:"fingerprint"` // 候选密码的指纹，恢复时用来确认密码本、规则和掩码没有改变
+	ResultFile  string           `json:"result_file,omitempty"`
+	Created     time.Time        `json:"created"`
+	Archives    []SessionArchive `json:"archives"`
+}
+
+// CreateSession 创建新的会话并立即写入文件，已有的同名文件会被覆盖
+func CreateSession(path, command string, options any, fingerprint, resultFile string, archives []SessionArchive) (*Session, error) {
+	raw, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{path: path, data: sessionFile{
+		Command:     command,
+		Options:     raw,
+		Fingerprint: fingerprint,
+		ResultFile:  resultFile,
+		Created:     time.Now(),
+		Archives:    archives,
+	}}
+	if err := s.Save(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// OpenSession 读取会话文件，文件不存在时返回的错误满足 errors.Is(err, os.ErrNotExist)
+func OpenSession(path string) (*Session, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	s := &Session{path: path}
+	if err := readJSON(path, &s.data); err != nil {
+		return nil, err
+	}
+	if s.data.Command == "" {
+		return nil, fmt.Errorf("会话文件 '%s' 缺少任务信息", path)
+	}
+	return s, nil
+}
+
+// SessionExists 判断会话文件是否存在
+func SessionExists(path string) bool {
+	_, err := os.Stat(path)
+	return !errors.Is(err, os.ErrNotExist)
+}
+
+// Command 返回创建会话的子命令
+func (s *Session) Command() string { return s.data.Command }
+
+// Options 把任务参数解析到 v 中
+func (s *Session) Options(v any) error {
+	return json.Unmarshal(s.data.Options, v)
+}
+
+// Fingerprint 返回创建会话时候选密码的指纹
+func (s *Session) Fingerprint() string { return s.data.Fingerprint }
+
+// ResultFile 返回任务的结果文件
+func (s *Session) ResultFile() string { return s.data.ResultFile }
+
+// Path 返回会话文件的路径
+func (s *Session) Path() string { return s.path }
+
+// Archives 返回全部压缩包的副本
+func (s *Session) Archives() []SessionArchive {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	archives := make([]SessionArchive, len(s.data.Archives))
+	copy(archives, s.data.Archives)
+	return archives
+}
+
+// Archive 返回第 i 个压缩包的副本
+func (s *Session) Archive(i int) SessionArchive {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.Archives[i]
+}
+
+// Start 记录第 i 个压缩包开始查找密码时排在密码本之前的密码和排在密码本最前面的密码
+func (s *Session) Start(i int, leading, first []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := &s.data.Archives[i]
+	a.Started, a.Leading, a.First, a.Next = true, leading, first, 0
+}
+
+// Checkpoint 记录第 i 个压缩包的查找进度，距离上次写回超过一定时间时写回文件
+func (s *Session) Checkpoint(i, next int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Archives[i].Next = next
+	if time.Since(s.saved) >= sessionSaveInterval {
+		// 写回失败时等到下一次再试，压缩包处理完时会报告错误
+		s.save()
+	}
+}
+
+// Finish 记录第 i 个压缩包的处理结果并立即写回文件
+func (s *Session) Finish(i int, result SessionArchive) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := &s.data.Archives[i]
+	a.Done = true
+	a.Success, a.Encrypted = result.Success, result.Encrypted
+	a.Password, a.Error = result.Password, result.Error
+	a.Skipped = result.Skipped
+	return s.save()
+}
+
+// Save 立即写回会话文件
+func (s *Session) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save()
+}
+
+func (s *Session) save() error {
+	if err := writeJSON(s.path, s.data); err != nil {
+		return err
+	}
+	s.saved = time.Now()
+	return nil
+}
+
+// Remove 在任务全部完成后删除会话文件
+func (s *Session) Remove() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return os.Remove(s.path)
+}
@@ -0,0 +1,195 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Record 记录某个压缩包是被哪个密码打开的
+type Record struct {
+	Password string    `json:"password"`
+	Time     time.Time `json:"time"`
+}
+
+// Store 是保存在本地的密码命中统计，记录每个压缩包的正确密码，
+// 并据此统计每个密码打开过多少个压缩包，用于把常用的密码排在前面尝试。
+// 同一个压缩包重复匹配只保留最新的一条记录，不会重复计数。
+// 可以被多个协程同时使用。
+type Store struct {
+	path string
+
+	mu       sync.Mutex
+	archives map[string]Record // 以压缩包的绝对路径为键
+	dirty    bool
+}
+
+// file 是统计文件的内容
+type file struct {
+	Archives map[string]Record `json:"archives"`
+}
+
+// Open 读取统计文件，文件不存在时返回一个空的统计，保存时再创建文件
+func Open(path string) (*Store, error) {
+	var f file
+	if err := readJSON(path, &f); err != nil {
+		return nil, err
+	}
+	if f.Archives == nil {
+		f.Archives = make(map[string]Record)
+	}
+	return &Store{path: path, archives: f.Archives}, nil
+}
+
+// Add 记录压缩包 archivePath 被 password 打开
+func (s *Store) Add(archivePath, password string) {
+	if abs, err := filepath.Abs(archivePath); err == nil {
+		archivePath = abs
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.archives[archivePath] = Record{Password: password, Time: time.Now()}
+	s.dirty = true
+}
+
+// Save 在有新记录时写回统计文件
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	if err := writeJSON(s.path, file{Archives: s.archives}); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// readJSON 读取 JSON 文件到 v 中，文件不存在时保持 v 不变
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("文件 '%s' 格式错误: %w", path, err)
+	}
+	return nil
+}
+
+// writeJSON 把 v 写入 JSON 文件。先写入临时文件再替换，避免中途退出时损坏原有的内容
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// passwordStats 是一个密码的命中情况
+type passwordStats struct {
+	password string
+	hits     int       // 打开过的压缩包数量
+	local    int       // 其中位于指定文件夹中的数量
+	lastHit  time.Time // 最近一次命中的时间
+}
+
+// Hits 返回打开过压缩包的密码数量
+func (s *Store) Hits() int {
+	return len(s.stats(""))
+}
+
+// Ranked 返回命中过的密码，依次按在 folder 中的命中次数 (folder 为空时不考虑)、
+// 总命中次数和最近命中时间排序
+func (s *Store) Ranked(folder string) []string {
+	if folder != "" {
+		if abs, err := filepath.Abs(folder); err == nil {
+			folder = abs
+		}
+	}
+	stats := s.stats(folder)
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if a.local != b.local {
+			return a.local > b.local
+		}
+		if a.hits != b.hits {
+			return a.hits > b.hits
+		}
+		if !a.lastHit.Equal(b.lastHit) {
+			return a.lastHit.After(b.lastHit)
+		}
+		return a.password < b.password
+	})
+	ranked := make([]string, len(stats))
+	for i, p := range stats {
+		ranked[i] = p.password
+	}
+	return ranked
+}
+
+// Prioritize 把 first 中的密码依次排在最前面，其余密码保持原来的顺序。
+// first 中不在 passwords 里的密码也会加入列表
+func Prioritize(passwords, first []string) []string {
+	if len(first) == 0 {
+		return passwords
+	}
+	ordered := make([]string, 0, len(passwords)+len(first))
+	seen := make(map[string]bool, len(first))
+	for _, p := range first {
+		ordered = append(ordered, p)
+		seen[p] = true
+	}
+	for _, p := range passwords {
+		if !seen[p] {
+			ordered = append(ordered, p)
+		}
+	}
+	return ordered
+}
+
+// stats 汇总每个密码的命中情况，folder 不为空时同时统计位于该文件夹中的压缩包
+func (s *Store) stats(folder string) []*passwordStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	byPassword := make(map[string]*passwordStats)
+	var result []*passwordStats
+	for path, r := range s.archives {
+		p := byPassword[r.Password]
+		if p == nil {
+			p = &passwordStats{password: r.Password}
+			byPassword[r.Password] = p
+			result = append(result, p)
+		}
+		p.hits++
+		if folder != "" && filepath.Dir(path) == folder {
+			p.local++
+		}
+		if r.Time.After(p.lastHit) {
+			p.lastHit = r.Time
+		}
+	}
+	return result
+}
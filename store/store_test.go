@@ -0,0 +1,103 @@
+package store
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRanked(t *testing.T) {
+	root := t.TempDir()
+	folder := filepath.Join(root, "folder")
+	other := filepath.Join(root, "other")
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s, err := Open(filepath.Join(root, "stats.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.archives = map[string]Record{
+		// often 打开的压缩包最多，但都不在 folder 中
+		filepath.Join(other, "1.zip"):  {Password: "often", Time: base},
+		filepath.Join(other, "2.zip"):  {Password: "often", Time: base},
+		filepath.Join(other, "3.zip"):  {Password: "often", Time: base},
+		filepath.Join(folder, "a.zip"): {Password: "local", Time: base},
+		filepath.Join(other, "4.zip"):  {Password: "local", Time: base},
+		// 命中次数相同时最近命中的排在前面，时间也相同时按密码排序
+		filepath.Join(other, "5.zip"): {Password: "recent", Time: base.Add(time.Hour)},
+		filepath.Join(other, "6.zip"): {Password: "b-old", Time: base},
+		filepath.Join(other, "7.zip"): {Password: "a-old", Time: base},
+	}
+
+	tests := []struct {
+		folder string
+		want   []string
+	}{
+		{"", []string{"often", "local", "recent", "a-old", "b-old"}},
+		{folder, []string{"local", "often", "recent", "a-old", "b-old"}},
+		{filepath.Join(root, "empty"), []string{"often", "local", "recent", "a-old", "b-old"}},
+	}
+	for _, tt := range tests {
+		if got := s.Ranked(tt.folder); !slices.Equal(got, tt.want) {
+			t.Errorf("Ranked(%q) = %q，应为 %q", filepath.Base(tt.folder), got, tt.want)
+		}
+	}
+	if got := s.Hits(); got != 5 {
+		t.Errorf("Hits() = %d，应为 5", got)
+	}
+}
+
+// 同一个压缩包重复匹配只保留最新的一条记录
+func TestAddSameArchive(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "stats.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(dir, "a.zip")
+	s.Add(archive, "old")
+	s.Add(archive, "new")
+	s.Add(filepath.Join(dir, "b.zip"), "old")
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hits := make(map[string]int)
+	for _, p := range s.stats("") {
+		hits[p.password] = p.hits
+	}
+	if hits["old"] != 1 || hits["new"] != 1 {
+		t.Errorf("命中次数为 %v，old 和 new 应当各命中 1 次", hits)
+	}
+	if got := s.archives[archive].Password; got != "new" {
+		t.Errorf("%s 的密码为 %q，应为 new", archive, got)
+	}
+}
+
+func TestPrioritize(t *testing.T) {
+	words := []string{"a", "b", "c", "d"}
+	tests := []struct {
+		name  string
+		first []string
+		want  []string
+	}{
+		{"没有命中过的密码", nil, []string{"a", "b", "c", "d"}},
+		{"命中的密码在密码本中", []string{"c", "a"}, []string{"c", "a", "b", "d"}},
+		{"命中的密码不在密码本中", []string{"x"}, []string{"x", "a", "b", "c", "d"}},
+		{"部分在密码本中", []string{"d", "y", "b"}, []string{"d", "y", "b", "a", "c"}},
+	}
+	for _, tt := range tests {
+		got := Prioritize(words, tt.first)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Prioritize(%q) = %q，应为 %q", tt.name, tt.first, got, tt.want)
+		}
+	}
+	if !slices.Equal(words, []string{"a", "b", "c", "d"}) {
+		t.Errorf("Prioritize() 修改了原来的密码本: %q", words)
+	}
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DiskSpace 返回 path 所在磁盘的标识和当前用户可用的剩余空间 (字节)。
+// path 尚不存在时 (例如还没有创建的解压目录) 检查最近的已存在的上级文件夹
+func DiskSpace(path string) (volume string, free uint64, err error) {
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return "", 0, err
+	}
+	for {
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			break
+		}
+		path = parent
+	}
+	return diskSpace(path)
+}
+
+// FormatSize 把字节数格式化为便于阅读的形式，例如 "1.5 GB"
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, suffix := float64(n)/unit, "KB"
+	for _, s := range []string{"MB", "GB", "TB", "PB"} {
+		// 保留一位小数后会显示为 "1024.0" 的值改用下一个单位
+		if value < unit-0.05 {
+			break
+		}
+		value, suffix = value/unit, s
+	}
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
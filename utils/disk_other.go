@@ -0,0 +1,24 @@
+//go:build !windows
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// diskSpace 使用 statfs 查询剩余空间，以设备号区分磁盘
+func diskSpace(path string) (string, uint64, error) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return "", 0, err
+	}
+	volume := path
+	if info, err := os.Stat(path); err == nil {
+		if st, ok := info.Sys().(*syscall.Stat_t); ok {
+			volume = fmt.Sprintf("dev-%d", uint64(st.Dev))
+		}
+	}
+	return volume, uint64(fs.Bavail) * uint64(fs.Bsize), nil
+}
@@ -0,0 +1,27 @@
+package utils
+
+import "testing"
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KB"},
+		{1536, "1.5 KB"},
+		{1<<20 - 1, "1.0 MB"},
+		{1023 << 10, "1023.0 KB"},
+		{1 << 20, "1.0 MB"},
+		{1 << 30, "1.0 GB"},
+		{3 << 39, "1.5 TB"},
+		{1 << 50, "1.0 PB"},
+		{1 << 60, "1024.0 PB"},
+	}
+	for _, tt := range tests {
+		if got := FormatSize(tt.n); got != tt.want {
+			t.Errorf("FormatSize(%d) = %q，应为 %q", tt.n, got, tt.want)
+		}
+	}
+}
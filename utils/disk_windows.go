@@ -0,0 +1,24 @@
+//go:build windows
+
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// diskSpace 使用 GetDiskFreeSpaceExW 查询剩余空间，以盘符或网络共享区分磁盘
+func diskSpace(path string) (string, uint64, error) {
+	getDiskFreeSpaceEx := syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return "", 0, err
+	}
+	var free uint64
+	if ret, _, err := getDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&free)), 0, 0); ret == 0 {
+		return "", 0, err
+	}
+	return strings.ToUpper(filepath.VolumeName(path)), free, nil
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"ArchiveTools/format"
+	"path/filepath"
+	"strings"
+)
+
+// zipBasedExtensions 是以 ZIP 为容器的文档和安装包，内容识别时不当作压缩包
+var zipBasedExtensions = map[string]bool{
+	".docx": true, ".xlsx": true, ".pptx": true,
+	".odt": true, ".ods": true, ".odp": true,
+	".epub": true, ".jar": true, ".war": true, ".ear": true, ".aar": true,
+	".apk": true, ".ipa": true, ".xpi": true, ".whl": true, ".nupkg": true, ".vsix": true,
+}
+
+// classifyFile 在 classifyVolume 的基础上，按扫描选项识别自解压程序和扩展名不符的压缩包
+func classifyFile(path string, opts ScanOptions) (volumeFile, bool) {
+	v, ok := classifyVolume(path)
+	switch {
+	case !ok && opts.SFX && strings.EqualFold(filepath.Ext(path), ".exe"):
+		return sfxVolume(v)
+	case opts.Sniff && (!ok || v.key.style == styleSingle):
+		return sniffVolume(v, ok)
+	}
+	return v, ok
+}
+
+// sfxVolume 在可执行文件中查找嵌入的压缩包，找到时按嵌入的格式处理
+func sfxVolume(v volumeFile) (volumeFile, bool) {
+	f, offset, err := format.DetectSFXFile(v.path)
+	if err != nil || f == nil {
+		return v, false
+	}
+	base := filepath.Base(v.path)
+	ext := filepath.Ext(base)
+	v.key = volumeKey{filepath.Dir(v.path), strings.TrimSuffix(base, ext), ext, styleSingle}
+	v.format = f
+	v.offset = offset
+	return v, true
+}
+
+// sniffVolume 按文件头识别普通压缩包的格式，known 表示扩展名是否已经识别
+// 扩展名能识别时只在内容属于另一种格式时修正格式；无法识别时收录能识别出格式的文件
+func sniffVolume(v volumeFile, known bool) (volumeFile, bool) {
+	base := filepath.Base(v.path)
+	ext := filepath.Ext(base)
+	if zipBasedExtensions[strings.ToLower(ext)] || splitPattern.MatchString(base) {
+		// 无法识别格式的分卷只有第一个分卷带有文件头，单独处理没有意义
+		return v, known
+	}
+	f, err := format.DetectFile(v.path)
+	if err != nil || f == nil {
+		return v, known
+	}
+
+	if known {
+		// 外层压缩格式相同时保留扩展名的判断，例如没有 ustar 标记的老式 .tar.gz，
+		// 只有识别出 tar 包时才把 .gz 等改为对应的 tar 格式
+		if f.Type != v.format.Type || f.Tar && !v.format.Tar {
+			v.format = f
+		}
+		return v, true
+	}
+
+	name := strings.TrimSuffix(base, ext)
+	if name == "" || ext == "" {
+		// 解压到同名文件夹时不能与压缩包本身重名
+		name = base + "_extracted"
+	}
+	v.key = volumeKey{filepath.Dir(v.path), name, ext, styleSingle}
+	v.format = f
+	return v, true
+}
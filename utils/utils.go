@@ -13,12 +13,8 @@ import (
 type ScanOptions struct {
 	Recursive     bool
 	ExcludePacked bool
-}
-
-var supportedExtensions = map[string]bool{
-	".zip": true,
-	".rar": true,
-	".7z":  true,
+	Sniff         bool // 按文件头识别格式，收录扩展名不符或缺失的压缩包
+	SFX           bool // 在 .exe 中查找嵌入的压缩包，收录自解压程序
 }
 
 // LoadPasswords 从指定文件中加载密码列表，并去除重复项。
@@ -51,9 +47,8 @@ func LoadPasswords(filePath string) ([]string, error) {
 	return passwords, nil
 }
 
-// ScanArchives 扫描指定路径下的所有支持的压缩文件。
-func ScanArchives(rootPath string, opts ScanOptions) ([]string, error) {
-	archives := []string{}
+// ScanArchives 扫描指定路径下的所有支持的压缩文件，分卷压缩包的各个分卷合并为一项。
+func ScanArchives(rootPath string, opts ScanOptions) ([]Archive, error) {
 	info, err := os.Stat(rootPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -63,13 +58,11 @@ func ScanArchives(rootPath string, opts ScanOptions) ([]string, error) {
 	}
 
 	if !info.IsDir() {
-		// 如果是单个文件
-		if supportedExtensions[filepath.Ext(rootPath)] {
-			archives = append(archives, rootPath)
-		}
-		return archives, nil
+		// 如果是单个文件，同时查找同一目录下属于同一组的其他分卷
+		return scanSingleFile(rootPath, opts)
 	}
 
+	files := []string{}
 	// 根据是否递归选择不同的扫描方式
 	if opts.Recursive {
 		err = filepath.WalkDir(rootPath, func(path string, d fs.DirEntry, err error) error {
@@ -78,7 +71,7 @@ func ScanArchives(rootPath string, opts ScanOptions) ([]string, error) {
 				return nil
 			}
 			if !d.IsDir() {
-				processFile(path, &archives, opts)
+				files = append(files, path)
 			}
 			return nil
 		})
@@ -89,8 +82,7 @@ func ScanArchives(rootPath string, opts ScanOptions) ([]string, error) {
 		}
 		for _, entry := range entries {
 			if !entry.IsDir() {
-				path := filepath.Join(rootPath, entry.Name())
-				processFile(path, &archives, opts)
+				files = append(files, filepath.Join(rootPath, entry.Name()))
 			}
 		}
 	}
@@ -98,26 +90,52 @@ func ScanArchives(rootPath string, opts ScanOptions) ([]string, error) {
 	if err != nil {
 		return nil, fmt.Errorf("扫描目录时出错: %w", err)
 	}
+	return ScanFiles(files, opts), nil
+}
 
-	return archives, nil
+// ScanFiles 从给定的文件中找出支持的压缩文件，规则与 ScanArchives 相同，只是不再读取目录。
+func ScanFiles(files []string, opts ScanOptions) []Archive {
+	archives := []Archive{}
+	for _, archive := range groupVolumes(files, opts) {
+		// 如果需要，跳过已存在同名文件夹的压缩包
+		if opts.ExcludePacked && isPacked(archive) {
+			continue
+		}
+		archives = append(archives, archive)
+	}
+	return archives
 }
 
-// processFile 是一个辅助函数，用于处理单个文件，检查是否符合条件
-func processFile(path string, archives *[]string, opts ScanOptions) {
-	// 1. 检查扩展名
-	if !supportedExtensions[filepath.Ext(path)] {
-		return
+// scanSingleFile 处理直接指定的单个文件，返回它所在的压缩包 (包括同组的其他分卷)
+func scanSingleFile(path string, opts ScanOptions) ([]Archive, error) {
+	archives := []Archive{}
+	if _, ok := classifyFile(path, opts); !ok {
+		return archives, nil
 	}
 
-	// 2. 如果需要，检查是否已解压
-	if opts.ExcludePacked {
-		ext := filepath.Ext(path)
-		dirPath := strings.TrimSuffix(path, ext)
-		if info, err := os.Stat(dirPath); err == nil && info.IsDir() {
-			return // 存在同名文件夹，跳过
+	dir := filepath.Dir(path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取目录 '%s': %w", dir, err)
+	}
+	siblings := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			siblings = append(siblings, filepath.Join(dir, entry.Name()))
+		}
+	}
+	for _, archive := range groupVolumes(siblings, opts) {
+		if archive.contains(path) {
+			archives = append(archives, archive)
+			break
 		}
 	}
+	return archives, nil
+}
 
-	// 3. 将符合条件的文件添加到列表
-	*archives = append(*archives, path)
-}
\ No newline at end of file
+// isPacked 检查压缩包旁边是否已经存在同名文件夹
+func isPacked(archive Archive) bool {
+	dirPath := filepath.Join(filepath.Dir(archive.Path), archive.Name)
+	info, err := os.Stat(dirPath)
+	return err == nil && info.IsDir()
+}
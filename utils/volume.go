@@ -0,0 +1,282 @@
+package utils
+
+import (
+	"ArchiveTools/format"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Archive 是一个待处理的压缩包，分卷压缩包的全部分卷合并为一项
+type Archive struct {
+	Path    string         // 打开压缩包时使用的文件：分卷压缩包为第一个分卷，ZIP 分卷为 .zip 文件
+	Format  *format.Format // 压缩格式，由扩展名判断，开启内容识别时以文件头为准
+	Name    string         // 去掉扩展名和分卷编号后的文件名，解压到同名文件夹时使用
+	Volumes []string       // 按顺序排列的已存在的分卷，普通压缩包只有 Path 一项
+	Missing []string       // 缺失的分卷文件名
+	Offset  int64          // 自解压程序中压缩包数据的起始位置，其他压缩包为 0
+}
+
+// IsMultiVolume 判断是否为分卷压缩包
+func (a Archive) IsMultiVolume() bool {
+	return len(a.Volumes)+len(a.Missing) > 1
+}
+
+// IsSFX 判断是否为自解压程序
+func (a Archive) IsSFX() bool {
+	return a.Offset > 0
+}
+
+// Sniffed 判断格式是否由文件头识别得出，即扩展名缺失或与内容不符 (包括自解压程序)
+// 分卷压缩包总是按文件名分组，不会出现这种情况
+func (a Archive) Sniffed() bool {
+	if a.IsMultiVolume() {
+		return false
+	}
+	f, _ := format.Lookup(filepath.Base(a.Path))
+	return f != a.Format
+}
+
+// volumeStyle 是分卷的命名方式
+type volumeStyle int
+
+const (
+	styleSingle   volumeStyle = iota // 普通压缩包
+	styleRarPart                     // x.part1.rar, x.part2.rar ...
+	styleRarOld                      // x.rar, x.r00, x.r01 ...
+	styleSplit                       // x.7z.001, x.tar.gz.002 ...
+	styleZipSplit                    // x.z01, x.z02 ... x.zip
+)
+
+var (
+	rarPartPattern  = regexp.MustCompile(`(?i)^(.+)\.part(\d+)\.rar$`)
+	rarOldPattern   = regexp.MustCompile(`(?i)^(.+)\.r(\d{2})$`)
+	splitPattern    = regexp.MustCompile(`^(.+)\.(\d{3})$`)
+	zipSplitPattern = regexp.MustCompile(`(?i)^(.+)\.z(\d{2})$`)
+)
+
+// volumeKey 标识同一组分卷
+type volumeKey struct {
+	dir   string
+	name  string
+	ext   string
+	style volumeStyle
+}
+
+// volumeFile 是一个分卷文件及其在组内的编号
+type volumeFile struct {
+	path   string
+	key    volumeKey
+	format *format.Format
+	offset int64 // 自解压程序中压缩包数据的起始位置
+	number int   // styleRarOld 的 .rar 为 0，styleZipSplit 的 .zip 为 -1 (排在最后)
+	width  int   // 编号的位数，用于拼出缺失分卷的文件名
+}
+
+// classifyVolume 根据文件名判断它是否为支持的压缩包或分卷
+func classifyVolume(path string) (volumeFile, bool) {
+	dir, base := filepath.Dir(path), filepath.Base(path)
+	v := volumeFile{path: path}
+
+	if m := rarPartPattern.FindStringSubmatch(base); m != nil {
+		v.key = volumeKey{dir, m[1], ".rar", styleRarPart}
+		v.format = format.RAR
+		v.number, _ = strconv.Atoi(m[2])
+		v.width = len(m[2])
+		return v, true
+	}
+	if m := splitPattern.FindStringSubmatch(base); m != nil {
+		// 去掉编号后剩下的部分必须是支持的格式
+		if f, ext := format.Lookup(m[1]); f != nil {
+			v.key = volumeKey{dir, strings.TrimSuffix(m[1], ext), ext, styleSplit}
+			v.format = f
+			v.number, _ = strconv.Atoi(m[2])
+			v.width = len(m[2])
+			return v, true
+		}
+	}
+	if m := rarOldPattern.FindStringSubmatch(base); m != nil {
+		v.key = volumeKey{dir, m[1], ".rar", styleRarOld}
+		v.format = format.RAR
+		n, _ := strconv.Atoi(m[2])
+		v.number = n + 1 // .r00 是第二个分卷
+		v.width = len(m[2])
+		return v, true
+	}
+	if m := zipSplitPattern.FindStringSubmatch(base); m != nil {
+		v.key = volumeKey{dir, m[1], ".zip", styleZipSplit}
+		v.format = format.ZIP
+		v.number, _ = strconv.Atoi(m[2])
+		v.width = len(m[2])
+		return v, true
+	}
+
+	f, ext := format.Lookup(base)
+	if f == nil {
+		return v, false
+	}
+	v.key = volumeKey{dir, strings.TrimSuffix(base, ext), ext, styleSingle}
+	v.format = f
+	return v, true
+}
+
+// groupVolumes 把文件按分卷分组，返回的顺序与每组第一个文件在 paths 中出现的顺序一致
+func groupVolumes(paths []string, opts ScanOptions) []Archive {
+	groups := make(map[volumeKey][]volumeFile)
+	var order []volumeKey
+	add := func(v volumeFile) {
+		if _, ok := groups[v.key]; !ok {
+			order = append(order, v.key)
+		}
+		groups[v.key] = append(groups[v.key], v)
+	}
+
+	var singles []volumeFile
+	for _, path := range paths {
+		v, ok := classifyFile(path, opts)
+		if !ok {
+			continue
+		}
+		if v.key.style == styleSingle {
+			singles = append(singles, v)
+			continue
+		}
+		add(v)
+	}
+
+	// 普通的 .rar / .zip 可能是旧式 RAR 分卷或 ZIP 分卷的一部分
+	for _, v := range singles {
+		switch {
+		case strings.EqualFold(v.key.ext, ".rar"):
+			key := v.key
+			key.ext, key.style = ".rar", styleRarOld
+			if _, ok := groups[key]; ok {
+				v.key, v.number = key, 0
+			}
+		case strings.EqualFold(v.key.ext, ".zip"):
+			key := v.key
+			key.ext, key.style = ".zip", styleZipSplit
+			if _, ok := groups[key]; ok {
+				v.key, v.number = key, -1
+			}
+		}
+		add(v)
+	}
+
+	// 按第一个文件在扫描结果中的位置排序，保持与扫描顺序一致
+	position := make(map[string]int, len(paths))
+	for i, path := range paths {
+		position[path] = i
+	}
+	first := func(key volumeKey) int {
+		best := len(paths)
+		for _, v := range groups[key] {
+			if p := position[v.path]; p < best {
+				best = p
+			}
+		}
+		return best
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return first(order[i]) < first(order[j])
+	})
+
+	archives := make([]Archive, 0, len(order))
+	for _, key := range order {
+		archives = append(archives, buildArchive(key, groups[key]))
+	}
+	return archives
+}
+
+// buildArchive 根据同一组的分卷生成 Archive，并找出缺失的分卷
+func buildArchive(key volumeKey, files []volumeFile) Archive {
+	a := Archive{Format: files[0].format, Name: key.name}
+	if key.style == styleSingle {
+		a.Path = files[0].path
+		a.Offset = files[0].offset
+		a.Volumes = []string{a.Path}
+		return a
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		// ZIP 分卷的 .zip 编号为 -1，但它是最后一个分卷
+		ni, nj := files[i].number, files[j].number
+		if ni < 0 || nj < 0 {
+			return nj < 0 && ni >= 0
+		}
+		return ni < nj
+	})
+
+	present := make(map[int]string, len(files))
+	maxNumber, width := 0, 0
+	for _, f := range files {
+		a.Volumes = append(a.Volumes, f.path)
+		present[f.number] = f.path
+		if f.number > maxNumber {
+			maxNumber = f.number
+		}
+		if f.width > width {
+			width = f.width
+		}
+	}
+
+	var first string
+	firstNumber := 1
+	switch key.style {
+	case styleRarPart:
+		first = fmt.Sprintf("%s.part%0*d.rar", key.name, width, 1)
+		for n := 1; n <= maxNumber; n++ {
+			if present[n] == "" {
+				a.Missing = append(a.Missing, fmt.Sprintf("%s.part%0*d.rar", key.name, width, n))
+			}
+		}
+	case styleSplit:
+		first = fmt.Sprintf("%s%s.%03d", key.name, key.ext, 1)
+		for n := 1; n <= maxNumber; n++ {
+			if present[n] == "" {
+				a.Missing = append(a.Missing, fmt.Sprintf("%s%s.%03d", key.name, key.ext, n))
+			}
+		}
+	case styleRarOld:
+		first, firstNumber = key.name+".rar", 0
+		if present[0] == "" {
+			a.Missing = append(a.Missing, first)
+		}
+		for n := 1; n <= maxNumber; n++ {
+			if present[n] == "" {
+				a.Missing = append(a.Missing, fmt.Sprintf("%s.r%02d", key.name, n-1))
+			}
+		}
+	case styleZipSplit:
+		// 7z 从 .zip 文件打开 ZIP 分卷
+		first, firstNumber = key.name+".zip", -1
+		for n := 1; n <= maxNumber; n++ {
+			if present[n] == "" {
+				a.Missing = append(a.Missing, fmt.Sprintf("%s.z%02d", key.name, n))
+			}
+		}
+		if present[-1] == "" {
+			a.Missing = append(a.Missing, first)
+		}
+	}
+	// 优先使用实际存在的文件名，编号的位数不一定统一
+	if path := present[firstNumber]; path != "" {
+		a.Path = path
+	} else {
+		a.Path = filepath.Join(key.dir, first)
+	}
+	return a
+}
+
+// contains 判断文件是否属于这个压缩包
+func (a Archive) contains(path string) bool {
+	for _, v := range a.Volumes {
+		if filepath.Clean(v) == filepath.Clean(path) {
+			return true
+		}
+	}
+	return false
+}
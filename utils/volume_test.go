@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"ArchiveTools/format"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestGroupVolumes(t *testing.T) {
+	p := func(names ...string) []string {
+		paths := make([]string, len(names))
+		for i, name := range names {
+			paths[i] = filepath.Join("dl", name)
+		}
+		return paths
+	}
+	tests := []struct {
+		name  string
+		files []string
+		want  []Archive
+	}{
+		{
+			name:  "普通压缩包",
+			files: p("a.zip", "notes.txt", "b.tar.gz"),
+			want: []Archive{
+				{Path: filepath.Join("dl", "a.zip"), Format: format.ZIP, Name: "a", Volumes: p("a.zip")},
+				{Path: filepath.Join("dl", "b.tar.gz"), Format: format.TarGz, Name: "b", Volumes: p("b.tar.gz")},
+			},
+		},
+		{
+			name:  ".partN.rar",
+			files: p("a.part2.rar", "a.part1.rar", "a.part3.rar"),
+			want: []Archive{
+				{Path: filepath.Join("dl", "a.part1.rar"), Format: format.RAR, Name: "a", Volumes: p("a.part1.rar", "a.part2.rar", "a.part3.rar")},
+			},
+		},
+		{
+			name:  ".rar 与 .r00",
+			files: p("a.r00", "a.r01", "a.rar"),
+			want: []Archive{
+				{Path: filepath.Join("dl", "a.rar"), Format: format.RAR, Name: "a", Volumes: p("a.rar", "a.r00", "a.r01")},
+			},
+		},
+		{
+			name:  ".7z.001",
+			files: p("a.7z.002", "a.7z.001", "b.tar.gz.001"),
+			want: []Archive{
+				{Path: filepath.Join("dl", "a.7z.001"), Format: format.SevenZip, Name: "a", Volumes: p("a.7z.001", "a.7z.002")},
+				{Path: filepath.Join("dl", "b.tar.gz.001"), Format: format.TarGz, Name: "b", Volumes: p("b.tar.gz.001")},
+			},
+		},
+		{
+			name:  "压缩的 tar 包分卷，扩展名大小写不同",
+			files: p("a.tar.zst.002", "a.tar.zst.001", "B.TAR.GZ.001", "B.TAR.GZ.002", "c.tgz.001"),
+			want: []Archive{
+				{Path: filepath.Join("dl", "a.tar.zst.001"), Format: format.TarZst, Name: "a", Volumes: p("a.tar.zst.001", "a.tar.zst.002")},
+				{Path: filepath.Join("dl", "B.TAR.GZ.001"), Format: format.TarGz, Name: "B", Volumes: p("B.TAR.GZ.001", "B.TAR.GZ.002")},
+				{Path: filepath.Join("dl", "c.tgz.001"), Format: format.TarGz, Name: "c", Volumes: p("c.tgz.001")},
+			},
+		},
+		{
+			name:  ".z01 与 .zip",
+			files: p("a.zip", "a.z02", "a.z01"),
+			want: []Archive{
+				{Path: filepath.Join("dl", "a.zip"), Format: format.ZIP, Name: "a", Volumes: p("a.z01", "a.z02", "a.zip")},
+			},
+		},
+		{
+			name:  "缺少中间的分卷",
+			files: p("a.part1.rar", "a.part3.rar", "b.rar", "b.r01", "c.7z.001", "c.7z.003", "d.z02", "d.zip"),
+			want: []Archive{
+				{Path: filepath.Join("dl", "a.part1.rar"), Format: format.RAR, Name: "a", Volumes: p("a.part1.rar", "a.part3.rar"), Missing: []string{"a.part2.rar"}},
+				{Path: filepath.Join("dl", "b.rar"), Format: format.RAR, Name: "b", Volumes: p("b.rar", "b.r01"), Missing: []string{"b.r00"}},
+				{Path: filepath.Join("dl", "c.7z.001"), Format: format.SevenZip, Name: "c", Volumes: p("c.7z.001", "c.7z.003"), Missing: []string{"c.7z.002"}},
+				{Path: filepath.Join("dl", "d.zip"), Format: format.ZIP, Name: "d", Volumes: p("d.z02", "d.zip"), Missing: []string{"d.z01"}},
+			},
+		},
+		{
+			name:  "单独的分卷",
+			files: p("a.part2.rar", "b.r00", "c.7z.002", "d.z01"),
+			want: []Archive{
+				{Path: filepath.Join("dl", "a.part1.rar"), Format: format.RAR, Name: "a", Volumes: p("a.part2.rar"), Missing: []string{"a.part1.rar"}},
+				{Path: filepath.Join("dl", "b.rar"), Format: format.RAR, Name: "b", Volumes: p("b.r00"), Missing: []string{"b.rar"}},
+				{Path: filepath.Join("dl", "c.7z.001"), Format: format.SevenZip, Name: "c", Volumes: p("c.7z.002"), Missing: []string{"c.7z.001"}},
+				{Path: filepath.Join("dl", "d.zip"), Format: format.ZIP, Name: "d", Volumes: p("d.z01"), Missing: []string{"d.zip"}},
+			},
+		},
+		{
+			name:  "编号位数不统一时使用实际存在的文件",
+			files: p("a.part01.rar", "a.part2.rar"),
+			want: []Archive{
+				{Path: filepath.Join("dl", "a.part01.rar"), Format: format.RAR, Name: "a", Volumes: p("a.part01.rar", "a.part2.rar")},
+			},
+		},
+		{
+			name:  "按每组第一个文件出现的顺序排列",
+			files: p("b.part2.rar", "a.zip", "b.part1.rar"),
+			want: []Archive{
+				{Path: filepath.Join("dl", "b.part1.rar"), Format: format.RAR, Name: "b", Volumes: p("b.part1.rar", "b.part2.rar")},
+				{Path: filepath.Join("dl", "a.zip"), Format: format.ZIP, Name: "a", Volumes: p("a.zip")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := groupVolumes(tt.files, ScanOptions{})
+			if len(got) != len(tt.want) {
+				t.Fatalf("得到 %d 个压缩包，应为 %d 个: %+v", len(got), len(tt.want), got)
+			}
+			for i, want := range tt.want {
+				a := got[i]
+				if a.Path != want.Path || a.Format != want.Format || a.Name != want.Name ||
+					!slices.Equal(a.Volumes, want.Volumes) || !slices.Equal(a.Missing, want.Missing) {
+					t.Errorf("第 %d 个压缩包为 %+v，应为 %+v", i, a, want)
+				}
+				if a.IsMultiVolume() != (len(want.Volumes)+len(want.Missing) > 1) {
+					t.Errorf("%s: IsMultiVolume() = %v", a.Path, a.IsMultiVolume())
+				}
+			}
+		})
+	}
+}